@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -25,14 +27,50 @@ var buildCmd = &cobra.Command{
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 		defer stop()
 
+		// several hash algorithms can be specified, the tree is then built with all of them in a single pass
+		_hashes := viper.GetStringSlice(projectName + ".hash")
+
 		// create conf
-		hash := pkg.Hash(viper.GetString(projectName + ".hash"))
-		if !hash.IsValid() {
+		// the order of sorted leaves would depend on the algorithm, only the pairs are then sorted by default
+		sortMode := viper.GetString(projectName + ".sort")
+		if len(_hashes) > 1 && !viper.IsSet(projectName+".sort") {
+			sortMode = sortModePairs
+		}
+		isSortLeaves, isSortPairs, err := parseSortMode(sortMode)
+		if err != nil {
+			return err
+		}
+		if isSortLeaves && len(_hashes) > 1 {
+			return fmt.Errorf("sort mode<%s>: %w, use --sort %s", sortMode, pkg.ErrMerkleTreeConfigSortLeavesIsWrong, sortModePairs)
+		}
+		hashers := make([]*pkg.Hasher, len(_hashes))
+		for i, h := range _hashes {
+			hash := pkg.Hash(h)
+			if !hash.IsValid() {
+				return fmt.Errorf(pkg.ErrHashNotAllowed.Error(), hash)
+			}
+			var hashPool *pkg.HashPool
+			if viper.GetBool(projectName + ".performance.reuse-buffer-allocation") {
+				hashPool = hash.NewPool()
+			}
+			hashers[i] = &pkg.Hasher{
+				IsSortLeaves: isSortLeaves,
+				IsSortPairs:  isSortPairs,
+				Hash:         hash,
+				Pool:         hashPool,
+			}
+		}
+
+		// odd node strategy and the hex encoded empty leaf hash per algorithm used to pad the tree
+		oddNodeStrategy := pkg.OddNodeStrategy(viper.GetString(projectName + ".odd-node-strategy"))
+		if err = oddNodeStrategy.Validate(); err != nil {
 			return err
 		}
-		var hashPool *pkg.HashPool
-		if viper.GetBool(projectName + ".performance.reuse-buffer-allocation") {
-			hashPool = pkg.NewHashPool(hash.Hash())
+		emptyLeafHashes := map[pkg.Hash][]byte{}
+		for h, v := range viper.GetStringMapString(projectName + ".empty-leaf-hash") {
+			if emptyLeafHashes[pkg.Hash(h)], err = hex.DecodeString(v); err != nil {
+				return fmt.Errorf("hex.DecodeString(%s): %w", v, err)
+			}
 		}
 
 		// fetch tree data
@@ -45,19 +83,34 @@ var buildCmd = &cobra.Command{
 		}
 
 		// use tree builder and build the tree
-		if mt, err = pkg.NewMerkleTreeBuilder().
-			WithHasher(&pkg.Hasher{
-				IsSort: viper.GetBool(projectName + ".sort"),
-				Hash:   hash,
-				Pool:   hashPool,
-			}).
+		builder := pkg.NewMerkleTreeBuilder()
+		for h, emptyLeafHash := range emptyLeafHashes {
+			builder.WithEmptyLeafHash(h, emptyLeafHash)
+		}
+		if mt, err = builder.
+			WithHashers(hashers...).
 			WithMaxGoroutine(viper.GetUint32(projectName+".performance.max-goroutine")).
+			WithDigestLength(viper.GetUint32(projectName+".digest-length")).
+			WithOddNodeStrategy(oddNodeStrategy).
+			WithArity(viper.GetUint32(projectName+".arity")).
+			WithDeduplication(pkg.DeduplicationMode(viper.GetString(projectName+".deduplication"))).
 			Build(ctx, data); err != nil {
 			return err
 		}
 
-		// display merkle tree root
-		log.Infof("merkle root hash: %x", mt.Root.Hash)
+		// display the leaves removed by the deduplication
+		for _, d := range mt.DroppedLeaves {
+			log.Infof("dropped duplicated leaf: index<%d> kept index<%d> val<%s>", d.Index, d.KeptIndex, d.Data)
+		}
+
+		// display merkle tree roots
+		for _, h := range hashers {
+			root, err := mt.RootHash(h.Hash)
+			if err != nil {
+				return err
+			}
+			log.Infof("merkle root hash<%s>: %x", h.Hash, root)
+		}
 
 		return nil
 	},
@@ -69,13 +122,47 @@ func init() {
 	buildCmd.Flags().StringSlice("data", []string{}, "data to insert into the merkle tree")
 	_ = viper.BindPFlag(projectName+".data", buildCmd.Flag("data"))
 
+	buildCmd.Flags().Uint32("digest-length", 0, "truncate the parent node hashes to this length in bytes (0: no truncation)")
+	_ = viper.BindPFlag(projectName+".digest-length", buildCmd.Flag("digest-length"))
+
+	buildCmd.Flags().String("odd-node-strategy", string(pkg.DUPLICATE), "how unpaired nodes are handled: duplicate, promote or pad")
+	_ = viper.BindPFlag(projectName+".odd-node-strategy", buildCmd.Flag("odd-node-strategy"))
+
 	rootCmd.Flags().Uint("max-goroutine", 1000, "max goroutine")
 	_ = viper.BindPFlag(projectName+".performance.max-goroutine", rootCmd.Flag("max goroutine"))
 
 	rootCmd.Flags().Bool("reuse-buffer-allocation", true, "reuse buffer allocation")
 	_ = viper.BindPFlag(projectName+".performance.reuse-buffer-allocation", rootCmd.Flag("reuse buffer allocation"))
 
-	rootCmd.Flags().Bool("sort", true, "sort")
-	_ = viper.BindPFlag(projectName+".sort", rootCmd.Flag("sort"))
+	buildCmd.Flags().Uint32("arity", pkg.DefaultArity, "nb of children per parent node (between 2 and 16)")
+	_ = viper.BindPFlag(projectName+".arity", buildCmd.Flag("arity"))
+
+	buildCmd.Flags().String("deduplication", string(pkg.NODEDUPLICATION), "duplicated leaves handling: none, keep-first or count")
+	_ = viper.BindPFlag(projectName+".deduplication", buildCmd.Flag("deduplication"))
+
+	buildCmd.Flags().String("sort", sortModeAll, "sort mode: none, leaves, pairs or all (pairs by default with several hashes)")
+	_ = viper.BindPFlag(projectName+".sort", buildCmd.Flag("sort"))
+}
+
+const (
+	sortModeNone   = "none"
+	sortModeLeaves = "leaves"
+	sortModePairs  = "pairs"
+	sortModeAll    = "all"
+)
 
+// parseSortMode returns whether the leaves and the pairs have to be sorted
+// the boolean values are still accepted for backward compatibility, true sorting both leaves and pairs
+func parseSortMode(mode string) (isSortLeaves bool, isSortPairs bool, err error) {
+	switch mode {
+	case sortModeNone, "false":
+		return false, false, nil
+	case sortModeLeaves:
+		return true, false, nil
+	case sortModePairs:
+		return false, true, nil
+	case sortModeAll, "true":
+		return true, true, nil
+	}
+	return false, false, fmt.Errorf("sort mode<%s> is not recognized", mode)
 }
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/v4lproik/merkle-tree/pkg"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var hashDirCmd = &cobra.Command{
+	Use:          "hash-dir [directory]",
+	Short:        "hash a directory as git does, the root hash being the one of git write-tree",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// initiate context
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		// the object format of the git repository, sha1 or sha256
+		hash := pkg.Hash(viper.GetString(projectName + ".hash-dir.object-format"))
+		if !hash.IsValid() {
+			return fmt.Errorf(pkg.ErrHashNotAllowed.Error(), hash)
+		}
+		var hashPool *pkg.HashPool
+		if viper.GetBool(projectName + ".performance.reuse-buffer-allocation") {
+			hashPool = hash.NewPool()
+		}
+
+		// use tree builder and hash the directory
+		tree, err := pkg.NewGitTreeBuilder().
+			WithHasher(&pkg.Hasher{Hash: hash, Pool: hashPool}).
+			WithMaxGoroutine(viper.GetUint32(projectName+".performance.max-goroutine")).
+			Build(ctx, dir)
+		if err != nil {
+			return err
+		}
+
+		// display the entries of the root tree then its hash
+		for _, e := range tree.Root.Entries {
+			log.Debugf("%06s %x %s", e.Mode, e.Hash, e.Name)
+		}
+		log.Infof("git tree hash<%s>: %x", hash, tree.Root.Hash)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hashDirCmd)
+
+	hashDirCmd.Flags().String("object-format", string(pkg.SHA1), "object format of the git repository: sha1 or sha256")
+	_ = viper.BindPFlag(projectName+".hash-dir.object-format", hashDirCmd.Flag("object-format"))
+}
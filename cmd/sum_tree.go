@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/v4lproik/merkle-tree/pkg"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sumTreeRoot is the content of the root file, the root hash and the total being the values to publish
+type sumTreeRoot struct {
+	Hash         pkg.Hash
+	DigestLength uint32
+	Root         []byte
+	Total        uint64
+	NbAccounts   int
+}
+
+var sumTreeCmd = &cobra.Command{
+	Use:          "sum-tree",
+	Short:        "build a merkle sum tree from a csv of balances and write the proof of each account",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// initiate context
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		// a sum tree is built with a single hash algorithm, the first one configured
+		hash := pkg.SHA256
+		if hashes := viper.GetStringSlice(projectName + ".hash"); len(hashes) > 0 {
+			hash = pkg.Hash(hashes[0])
+		}
+		if !hash.IsValid() {
+			return fmt.Errorf(pkg.ErrHashNotAllowed.Error(), hash)
+		}
+		var hashPool *pkg.HashPool
+		if viper.GetBool(projectName + ".performance.reuse-buffer-allocation") {
+			hashPool = hash.NewPool()
+		}
+		hasher := &pkg.Hasher{
+			Hash:         hash,
+			Pool:         hashPool,
+			DigestLength: viper.GetUint32(projectName + ".digest-length"),
+		}
+
+		// fetch the balances
+		leaves, err := readSumTreeCSV(viper.GetString(projectName + ".sum-tree.csv"))
+		if err != nil {
+			return err
+		}
+
+		// use tree builder and build the tree
+		mst, err := pkg.NewMerkleSumTreeBuilder().
+			WithHasher(hasher).
+			WithMaxGoroutine(viper.GetUint32(projectName+".performance.max-goroutine")).
+			Build(ctx, leaves)
+		if err != nil {
+			return err
+		}
+
+		// write the proof of each account then the root to publish
+		output := viper.GetString(projectName + ".sum-tree.output")
+		proofDir := filepath.Join(output, "proofs")
+		if err = os.MkdirAll(proofDir, 0o755); err != nil {
+			return fmt.Errorf("os.MkdirAll(%s): %w", proofDir, err)
+		}
+		for _, l := range leaves {
+			proof, err := mst.Proof(l.Account)
+			if err != nil {
+				return err
+			}
+			// the account is escaped so that it cannot escape the proof directory
+			if err = writeJSONFile(filepath.Join(proofDir, url.PathEscape(l.Account)+".json"), proof); err != nil {
+				return err
+			}
+		}
+
+		root := mst.Root()
+		if err = writeJSONFile(filepath.Join(output, "root.json"), &sumTreeRoot{
+			Hash:         hasher.Hash,
+			DigestLength: hasher.DigestLength,
+			Root:         root.Hash,
+			Total:        root.Sum,
+			NbAccounts:   len(leaves),
+		}); err != nil {
+			return err
+		}
+
+		log.Infof("merkle sum root hash<%s>: %x total<%d> accounts<%d>", hasher.Hash, root.Hash, root.Sum, len(leaves))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sumTreeCmd)
+
+	sumTreeCmd.Flags().String("csv", "", "csv file of account,balance rows, a header row being skipped")
+	_ = viper.BindPFlag(projectName+".sum-tree.csv", sumTreeCmd.Flag("csv"))
+
+	sumTreeCmd.Flags().String("output", ".", "directory the root file and the proof files are written to")
+	_ = viper.BindPFlag(projectName+".sum-tree.output", sumTreeCmd.Flag("output"))
+}
+
+// readSumTreeCSV reads the account,balance rows of the csv file, the first row being skipped if its balance is not a
+// number
+func readSumTreeCSV(path string) ([]pkg.MerkleSumLeaf, error) {
+	if path == "" {
+		return nil, errors.New("the csv file cannot be empty")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%s): %w", path, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+
+	var leaves []pkg.MerkleSumLeaf
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("r.Read(): %w", err)
+		}
+
+		balance, err := strconv.ParseUint(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line<%d> balance<%s>: %w", line, record[1], err)
+		}
+		leaves = append(leaves, pkg.MerkleSumLeaf{Account: strings.TrimSpace(record[0]), Balance: balance})
+	}
+	return leaves, nil
+}
+
+// writeJSONFile writes the indented json encoding of the value passed in parameter
+func writeJSONFile(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent(): %w", err)
+	}
+	if err = os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("os.WriteFile(%s): %w", path, err)
+	}
+	return nil
+}
@@ -1,14 +1,17 @@
 package pkg
 
 import (
+	"crypto/sha512"
 	"sync"
 )
 
-// TODO: AI(Joel): should size the array depending on the algo size in order to support more algo
+// maxConcatBufferSize is the size of the pooled buffers
+// max arity times the largest supported digest as it's only used to concat the children hashes together
+const maxConcatBufferSize = MaxArity * sha512.Size
+
 var buffers = sync.Pool{
 	New: func() interface{} {
-		// 2 times 256 as it's only used to concat two hashes of 256 bits together
-		b := make([]byte, 256+256)
+		b := make([]byte, maxConcatBufferSize)
 		return &BuffCloser{arr: b}
 	},
 }
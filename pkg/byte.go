@@ -2,29 +2,52 @@ package pkg
 
 import (
 	"bytes"
+	"io"
+	"sort"
 )
 
-func concat(isReuseBuffAllocation bool, isSort bool, b1, b2 []byte) []byte {
-	var b []byte
-	if isReuseBuffAllocation {
+// writeConcat writes the concatenation of the children hashes into w
+// the buffer is only given back to the pool once it has been written so that no other goroutine can reuse it meanwhile
+func writeConcat(w io.Writer, isReuseBuffAllocation bool, isSortPairs bool, children ...[]byte) error {
+	var (
+		b []byte
+		n int
+	)
+	for _, child := range children {
+		n += len(child)
+	}
+	if isReuseBuffAllocation && n <= maxConcatBufferSize {
 		cb := GetConcatBuffers()
 		defer cb.Close()
-		b = cb.arr
+		b = cb.arr[:n]
 	} else {
-		// TODO: AI(Joel): ditto remove harcoded values when supporting more algorithms
-		b = make([]byte, 256+256)
+		b = make([]byte, n)
 	}
-	if isSort && bytes.Compare(b1, b2) == 1 {
-		swap := b1
-		b1 = b2
-		b2 = swap
+	if isSortPairs {
+		children = sortHashes(children)
 	}
 	i := 0
-	for i = 0; i < len(b1); i++ {
-		b[i] = b1[i]
+	for _, child := range children {
+		i += copy(b[i:], child)
 	}
-	for j := i; j < len(b2); j++ {
-		b[j] = b1[i-len(b1)]
+
+	_, err := w.Write(b)
+	return err
+}
+
+// sortHashes orders the hashes without modifying the slice passed in parameter
+// perf: pairs are the most common case and are ordered without any allocation
+func sortHashes(hashes [][]byte) [][]byte {
+	if len(hashes) == 2 {
+		if bytes.Compare(hashes[0], hashes[1]) == 1 {
+			return [][]byte{hashes[1], hashes[0]}
+		}
+		return hashes
 	}
-	return b
+	sorted := make([][]byte, len(hashes))
+	copy(sorted, hashes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) == -1
+	})
+	return sorted
 }
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CID is a version 1 content identifier: the version, the codec of the content and the multihash of the content, i.e.
+// the code of the hash algorithm, the digest length and the digest, each number being encoded as an unsigned varint
+type CID []byte
+
+const (
+	// RawCodec is the codec of a content made of raw bytes
+	RawCodec = 0x55
+	// DAGCBORCodec is the codec of a content serialized with DAG-CBOR
+	DAGCBORCodec = 0x71
+)
+
+var (
+	ErrCIDIsWrong = errors.New("the cid is not a valid version 1 cid")
+
+	// cidEncoding is the base32 lower case encoding without padding of the multibase prefix 'b'
+	cidEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+)
+
+// NewCID hashes the content passed in parameter and returns its cid
+func NewCID(codec uint64, h *Hasher, content []byte) (CID, error) {
+	digest, err := h.hashData(content)
+	if err != nil {
+		return nil, fmt.Errorf("h.hashData(): %w", err)
+	}
+
+	c := binary.AppendUvarint([]byte{1}, codec)
+	c = binary.AppendUvarint(c, h.Hash.MultihashCode())
+	c = binary.AppendUvarint(c, uint64(len(digest)))
+	return append(c, digest...), nil
+}
+
+// ParseCID decodes the string representation of a version 1 cid, i.e. its base32 encoding prefixed with 'b'
+func ParseCID(s string) (CID, error) {
+	if !strings.HasPrefix(s, "b") {
+		return nil, fmt.Errorf("cid<%s>: %w", s, ErrCIDIsWrong)
+	}
+
+	c, err := cidEncoding.DecodeString(s[1:])
+	if err != nil {
+		return nil, fmt.Errorf("cid<%s>: %w", s, ErrCIDIsWrong)
+	}
+	if err = CID(c).validate(); err != nil {
+		return nil, fmt.Errorf("cid<%s>: %w", s, err)
+	}
+	return c, nil
+}
+
+// String returns the base32 representation of the cid
+func (c CID) String() string {
+	return "b" + cidEncoding.EncodeToString(c)
+}
+
+// validate checks the version of the cid and the length of its digest
+func (c CID) validate() error {
+	if len(c) == 0 || c[0] != 1 {
+		return ErrCIDIsWrong
+	}
+
+	b := c[1:]
+	for i := 0; i < 3; i++ {
+		u, n := binary.Uvarint(b)
+		if n <= 0 {
+			return ErrCIDIsWrong
+		}
+		b = b[n:]
+		// the last number is the digest length
+		if i == 2 && u != uint64(len(b)) {
+			return ErrCIDIsWrong
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+)
+
+// ConcurrentMerkleTree is a merkle tree that can be read by many goroutines while another one updates it
+// reads share a read lock and updates are serialized under the write lock, a snapshot can be taken to serve several
+// proofs and the root they are verified against from a same version of the tree without holding any lock
+type ConcurrentMerkleTree struct {
+	mu   sync.RWMutex
+	tree *MerkleTree
+}
+
+// NewConcurrentMerkleTree wraps the tree passed in parameter, the tree must not be used directly afterwards
+func NewConcurrentMerkleTree(mt *MerkleTree) *ConcurrentMerkleTree {
+	return &ConcurrentMerkleTree{tree: mt}
+}
+
+// Snapshot returns a read-only version of the current tree, it can be read without any lock
+func (c *ConcurrentMerkleTree) Snapshot() *MerkleTreeSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tree.Snapshot()
+}
+
+// RootHash returns the current merkle root computed with the hash algorithm passed in parameter
+func (c *ConcurrentMerkleTree) RootHash(hash Hash) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tree.RootHash(hash)
+}
+
+// Proof generates the inclusion proof of the leaf containing the data passed in parameter against the current root
+// the root is returned along with the proof as the tree might be updated right after the lock is released
+func (c *ConcurrentMerkleTree) Proof(data Data, hash Hash) (*Proof, []byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	root, err := c.tree.RootHash(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := c.tree.Proof(data, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, root, nil
+}
+
+// Verify verifies if a leaf containing the data passed in parameter is present in the current tree
+func (c *ConcurrentMerkleTree) Verify(ctx context.Context, data Data) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tree.Verify(ctx, data)
+}
+
+// Update replaces the data of the leaf at the index passed in parameter
+func (c *ConcurrentMerkleTree) Update(index int, data Data) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tree.Update(index, data)
+}
+
+// ApplyBatch replaces the data of the leaves at the indices passed in parameter in a single pass
+func (c *ConcurrentMerkleTree) ApplyBatch(ctx context.Context, updates map[int]Data) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tree.ApplyBatch(ctx, updates)
+}
+
+// Append appends the data as new leaves at the end of the tree
+func (c *ConcurrentMerkleTree) Append(ctx context.Context, data ...Data) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tree.Append(ctx, data...)
+}
+
+// Delete deletes the leaves at the indices passed in parameter according to the deletion mode of the tree
+func (c *ConcurrentMerkleTree) Delete(ctx context.Context, indices ...int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tree.Delete(ctx, indices...)
+}
+
+// Subscribe registers a callback called after each change of the root of the tree
+// callbacks are called under the write lock so they must not call the tree back
+func (c *ConcurrentMerkleTree) Subscribe(callback func(RootChange)) (unsubscribe func()) {
+	return c.tree.Subscribe(callback)
+}
+
+// Watch returns a channel receiving the changes of the root of the tree, size being the size of its buffer
+// the changes are sent by a goroutine of the watch without blocking the updates, so that the receiver can read the
+// tree on each change
+func (c *ConcurrentMerkleTree) Watch(size int) (<-chan RootChange, func()) {
+	return c.tree.Watch(size)
+}
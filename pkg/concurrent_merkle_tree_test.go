@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentMerkleTree(t *testing.T) {
+	const (
+		nbLeaves  = 64
+		nbReaders = 8
+		nbUpdates = 50
+	)
+
+	data := make([]Data, nbLeaves)
+	for i := range data {
+		data[i] = StringData{Value: fmt.Sprintf("value%d", i)}
+	}
+	tree := NewConcurrentMerkleTree(buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, data))
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+
+	// the writer updates and appends leaves while the readers generate and verify proofs
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < nbUpdates; i++ {
+			assert.NoError(t, tree.ApplyBatch(ctx, map[int]Data{i % nbLeaves: StringData{Value: fmt.Sprintf("updated%d", i)}}))
+			assert.NoError(t, tree.Append(ctx, StringData{Value: fmt.Sprintf("appended%d", i)}))
+		}
+	}()
+
+	for r := 0; r < nbReaders; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := r; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// the last leaves are never updated so that they are always present
+				d := data[nbLeaves-1-i%(nbLeaves-nbUpdates)]
+
+				p, root, err := tree.Proof(d, SHA256)
+				assert.NoError(t, err)
+				isVerified, err := p.Verify(root)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+
+				isVerified, err = tree.Verify(ctx, d)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+
+				// a snapshot serves proofs and their root from a same version without any lock
+				snapshot := tree.Snapshot()
+				root, err = snapshot.RootHash(SHA256)
+				assert.NoError(t, err)
+				p, err = snapshot.Proof(d, SHA256)
+				assert.NoError(t, err)
+				isVerified, err = p.Verify(root)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	root, err := tree.RootHash(SHA256)
+	assert.NoError(t, err)
+
+	// the final tree should be the one built from the final data
+	expected := make([]Data, 0, nbLeaves+nbUpdates)
+	expected = append(expected, data...)
+	for i := 0; i < nbUpdates; i++ {
+		expected[i%nbLeaves] = StringData{Value: fmt.Sprintf("updated%d", i)}
+	}
+	for i := 0; i < nbUpdates; i++ {
+		expected = append(expected, StringData{Value: fmt.Sprintf("appended%d", i)})
+	}
+	assert.Equal(t, buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, expected).Root.Hash, root)
+}
+
+func TestConcurrentMerkleTree_Watch(t *testing.T) {
+	const nbUpdates = 50
+
+	tree := NewConcurrentMerkleTree(buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, dataUnEvenNbNodes))
+	changes, stop := tree.Watch(0)
+	defer stop()
+
+	// the consumer reads the tree on each change, e.g. to refresh a cache, while the writer keeps updating it
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for i := 0; i < nbUpdates; i++ {
+			change := <-changes
+			assert.Len(t, change.Indices, 1)
+			_, err := tree.RootHash(SHA256)
+			assert.NoError(t, err)
+			_, _, err = tree.Proof(dataUnEvenNbNodes[4], SHA256)
+			assert.NoError(t, err)
+		}
+	}()
+
+	for i := 0; i < nbUpdates; i++ {
+		assert.NoError(t, tree.Update(i%4, StringData{Value: fmt.Sprintf("updated%d", i)}))
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the consumer reading the tree on each change is deadlocked")
+	}
+}
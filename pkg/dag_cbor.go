@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DAG-CBOR is the deterministic subset of CBOR used by IPLD and AT Protocol: integers and lengths use their shortest
+// encoding, map keys are strings sorted by length then bytewise, floats are not allowed, and links are CIDs tagged
+// with 42
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+
+	cborFalse = 20
+	cborTrue  = 21
+	cborNull  = 22
+
+	cborCIDTag = 42
+)
+
+var (
+	ErrDAGCBORTypeNotAllowed = errors.New("the dag-cbor encoding does not support the type")
+)
+
+// EncodeDAGCBOR serializes the value passed in parameter with DAG-CBOR
+// nil, bool, int, int64, uint64, []byte, string, CID, []interface{} and map[string]interface{} are supported
+func EncodeDAGCBOR(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeDAGCBOR(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeDAGCBOR(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteByte(cborSimple<<5 | cborNull)
+	case bool:
+		if value {
+			buf.WriteByte(cborSimple<<5 | cborTrue)
+		} else {
+			buf.WriteByte(cborSimple<<5 | cborFalse)
+		}
+	case int:
+		return encodeDAGCBOR(buf, int64(value))
+	case int64:
+		if value < 0 {
+			cborHeader(buf, cborNegInt, uint64(-(value + 1)))
+		} else {
+			cborHeader(buf, cborUint, uint64(value))
+		}
+	case uint64:
+		cborHeader(buf, cborUint, value)
+	case []byte:
+		cborHeader(buf, cborBytes, uint64(len(value)))
+		buf.Write(value)
+	case string:
+		cborHeader(buf, cborText, uint64(len(value)))
+		buf.WriteString(value)
+	case CID:
+		// the cid bytes are prefixed with the identity multibase 0x00
+		cborHeader(buf, cborTag, cborCIDTag)
+		cborHeader(buf, cborBytes, uint64(len(value)+1))
+		buf.WriteByte(0)
+		buf.Write(value)
+	case []interface{}:
+		cborHeader(buf, cborArray, uint64(len(value)))
+		for i, item := range value {
+			if err := encodeDAGCBOR(buf, item); err != nil {
+				return fmt.Errorf("item<%d>: %w", i, err)
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+
+		cborHeader(buf, cborMap, uint64(len(value)))
+		for _, key := range keys {
+			cborHeader(buf, cborText, uint64(len(key)))
+			buf.WriteString(key)
+			if err := encodeDAGCBOR(buf, value[key]); err != nil {
+				return fmt.Errorf("key<%s>: %w", key, err)
+			}
+		}
+	default:
+		return fmt.Errorf("type<%T>: %w", v, ErrDAGCBORTypeNotAllowed)
+	}
+	return nil
+}
+
+// cborHeader writes the major type and the argument passed in parameter with the shortest encoding
+func cborHeader(buf *bytes.Buffer, major byte, argument uint64) {
+	switch {
+	case argument < 24:
+		buf.WriteByte(major<<5 | byte(argument))
+	case argument <= math.MaxUint8:
+		buf.Write([]byte{major<<5 | 24, byte(argument)})
+	case argument <= math.MaxUint16:
+		buf.Write([]byte{major<<5 | 25, byte(argument >> 8), byte(argument)})
+	case argument <= math.MaxUint32:
+		buf.Write([]byte{major<<5 | 26, byte(argument >> 24), byte(argument >> 16), byte(argument >> 8), byte(argument)})
+	default:
+		buf.WriteByte(major<<5 | 27)
+		for i := 7; i >= 0; i-- {
+			buf.WriteByte(byte(argument >> (8 * i)))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEncodeDAGCBOR(t *testing.T) {
+	cid, err := ParseCID("bafyreie5cvv4h45feadgeuwhbcutmh6t2ceseocckahdoe6uat64zmz454")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+		err      error
+	}{
+		{
+			name:     "encode null should return the simple value 22",
+			value:    nil,
+			expected: "f6",
+		},
+		{
+			name:     "encode booleans should return the simple values 21 and 20",
+			value:    []interface{}{true, false},
+			expected: "82f5f4",
+		},
+		{
+			name:     "encode integers should return their shortest encoding",
+			value:    []interface{}{0, 23, 24, 255, 256, 65536, uint64(1) << 32, -1, -25},
+			expected: "890017181818ff1901001a000100001b0000000100000000203818",
+		},
+		{
+			name:     "encode a text and a byte string should return their length and their bytes",
+			value:    []interface{}{"dog", []byte{1, 2}},
+			expected: "8263646f67420102",
+		},
+		{
+			name:     "encode a map should sort its keys by length then bytewise",
+			value:    map[string]interface{}{"bb": 1, "a": 2, "c": 3},
+			expected: "a361610261630362626201",
+		},
+		{
+			name:     "encode a cid should return a tagged byte string prefixed with 0x00",
+			value:    cid,
+			expected: "d82a582500" + hex.EncodeToString(cid),
+		},
+		{
+			name:  "encode a float should return error",
+			value: 1.5,
+			err:   ErrDAGCBORTypeNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := EncodeDAGCBOR(tt.value)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("EncodeDAGCBOR() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.expected, hex.EncodeToString(b))
+		})
+	}
+}
+
+func TestParseCID(t *testing.T) {
+	tests := []struct {
+		name string
+		cid  string
+		err  error
+	}{
+		{
+			name: "parse a cid without multibase prefix should return error",
+			cid:  "afyreie5cvv4h45feadgeuwhbcutmh6t2ceseocckahdoe6uat64zmz454",
+			err:  ErrCIDIsWrong,
+		},
+		{
+			name: "parse a cid that is not base32 should return error",
+			cid:  "b0000",
+			err:  ErrCIDIsWrong,
+		},
+		{
+			name: "parse a truncated cid should return error",
+			cid:  "bafyreie5cvv4h45feadgeuwhbcutmh6t2ceseocckahdoe6uat64zmz4",
+			err:  ErrCIDIsWrong,
+		},
+		{
+			name: "parse a cid should return it",
+			cid:  "bafyreie5cvv4h45feadgeuwhbcutmh6t2ceseocckahdoe6uat64zmz454",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cid, err := ParseCID(tt.cid)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ParseCID() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.cid, cid.String())
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"encoding/binary"
 	"fmt"
 )
 
@@ -38,3 +39,44 @@ func (s StringData) Hash(h *Hasher) ([]byte, error) {
 func (s StringData) String() string {
 	return s.Value
 }
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// HashedData represents a data that has already been hashed, e.g. the hash tree root of an Ethereum deposit
+// its hash is its value so that it is used as is as a leaf
+type HashedData struct {
+	Value []byte
+}
+
+func (s HashedData) Hash(_ *Hasher) ([]byte, error) {
+	return s.Value, nil
+}
+
+func (s HashedData) String() string {
+	return fmt.Sprintf("%x", s.Value)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// CountedData represents a data along with its nb of occurrences, e.g. a leaf of a tree counting its duplicates
+// its hash is H(H(data) || occurrences), the occurrences being on 4 bytes big endian, so that the count is committed
+type CountedData struct {
+	Data        Data
+	Occurrences uint32
+}
+
+func (s CountedData) Hash(h *Hasher) ([]byte, error) {
+	dataHash, err := s.Data.Hash(h)
+	if err != nil {
+		return nil, fmt.Errorf("s.Data.Hash(): %w", err)
+	}
+
+	b := make([]byte, len(dataHash)+4)
+	copy(b, dataHash)
+	binary.BigEndian.PutUint32(b[len(dataHash):], s.Occurrences)
+	return h.hashData(b)
+}
+
+func (s CountedData) String() string {
+	return fmt.Sprintf("%s x%d", s.Data, s.Occurrences)
+}
@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+)
+
+// DeduplicationMode is an enum representing how the leaves with the same hash are handled at build time
+type DeduplicationMode string
+
+const (
+	// UNKNOWNDEDUPLICATION is the default value returned for non-supported modes
+	UNKNOWNDEDUPLICATION DeduplicationMode = "unknown"
+	// NODEDUPLICATION keeps all the leaves, duplicated ones included
+	NODEDUPLICATION DeduplicationMode = "none"
+	// KEEPFIRST keeps the first occurrence of a leaf and drops the other ones
+	KEEPFIRST DeduplicationMode = "keep-first"
+	// COUNT keeps the first occurrence of a leaf whose data becomes a CountedData so that its hash commits the count
+	COUNT DeduplicationMode = "count"
+)
+
+var ErrDeduplicationModeNotAllowed = errors.New("the deduplication mode is not recognized")
+
+// IsValid checks if a mode is valid
+func (s DeduplicationMode) IsValid() bool {
+	switch s {
+	case NODEDUPLICATION, KEEPFIRST, COUNT:
+		return true
+	case UNKNOWNDEDUPLICATION:
+		return false
+	}
+	return false
+}
+
+// Validate returns an error if the mode is not supported
+func (s DeduplicationMode) Validate() error {
+	if !s.IsValid() {
+		return fmt.Errorf("DeduplicationMode<%s>: %w", s, ErrDeduplicationModeNotAllowed)
+	}
+	return nil
+}
+
+// DroppedLeaf reports a leaf that has been removed from the tree as it has the same hash as a previous one
+// Index is the position of its data within the data passed to the builder, KeptIndex the one of the kept occurrence
+type DroppedLeaf struct {
+	Index     int
+	KeptIndex int
+	Data      Data
+}
+
+// deduplicateLeaves removes the leaves with the same hash as a previous leaf, the leaves being compacted in place
+// the leaves are walked in the order of the data so that the kept occurrence is always the first one
+// the nb of occurrences of each kept leaf is returned along with it
+func deduplicateLeaves(leaves []*Node) ([]*Node, []DroppedLeaf, []uint32) {
+	var (
+		dropped     []DroppedLeaf
+		occurrences = make([]uint32, 0, len(leaves))
+		// index of the first occurrence of a hash within the data and within the deduplicated leaves
+		firstIndexes = make(map[string][2]int, len(leaves))
+		n            int
+	)
+
+	for i, leaf := range leaves {
+		if first, ok := firstIndexes[string(leaf.Hash)]; ok {
+			dropped = append(dropped, DroppedLeaf{Index: i, KeptIndex: first[0], Data: leaf.Data})
+			occurrences[first[1]]++
+			continue
+		}
+		firstIndexes[string(leaf.Hash)] = [2]int{i, n}
+		occurrences = append(occurrences, 1)
+		leaves[n] = leaf
+		n++
+	}
+
+	return leaves[:n], dropped, occurrences
+}
+
+// countLeaves replaces each leaf by a leaf of its data along with its nb of occurrences
+// the count being part of the hash of the leaf, it is committed by the root and covered by the proofs
+func (mt *MerkleTree) countLeaves(ctx context.Context, leaves []*Node, occurrences []uint32) error {
+	errs, _ := errgroup.WithContext(ctx)
+	errs.SetLimit(int(mt.MerkleTreeConfig.MaxGoroutine))
+	for _i := 0; _i < len(leaves); _i++ {
+		// i can change in the below go routine, allocates a local scope via i
+		i := _i
+
+		errs.Go(func() error {
+			leaf, err := newLeaf(mt.hashers(), CountedData{Data: leaves[i].Data, Occurrences: occurrences[i]}, false)
+			if err != nil {
+				return fmt.Errorf("newLeaf(leaves[%d]): %w", i, err)
+			}
+			leaves[i] = leaf
+			return nil
+		})
+	}
+	return errs.Wait()
+}
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMerkleTreeBuilder_WithDeduplication(t *testing.T) {
+	data := []Data{
+		StringData{Value: "value1"},
+		StringData{Value: "value2"},
+		StringData{Value: "value1"},
+		StringData{Value: "value3"},
+		StringData{Value: "value2"},
+		StringData{Value: "value1"},
+	}
+	mtWithoutDuplicates, err := NewMerkleTreeBuilder().
+		WithHasher(configWithHashPool.Hasher).
+		WithMaxGoroutine(configWithHashPool.MaxGoroutine).
+		Build(ctx, []Data{data[0], data[1], data[3]})
+	assert.NoError(t, err)
+	counted := []Data{
+		CountedData{Data: data[0], Occurrences: 3},
+		CountedData{Data: data[1], Occurrences: 2},
+		CountedData{Data: data[3], Occurrences: 1},
+	}
+	mtCounted, err := NewMerkleTreeBuilder().
+		WithHasher(configWithHashPool.Hasher).
+		WithMaxGoroutine(configWithHashPool.MaxGoroutine).
+		Build(ctx, counted)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		mode         DeduplicationMode
+		wantRoot     []byte
+		wantDropped  []DroppedLeaf
+		wantNbLeaves int
+		err          error
+	}{
+		{
+			name: "build merkle tree with an unknown deduplication mode should return error",
+			mode: DeduplicationMode("unknown"),
+			err:  ErrDeduplicationModeNotAllowed,
+		},
+		{
+			name:         "build merkle tree without deduplication should keep all the leaves",
+			mode:         NODEDUPLICATION,
+			wantNbLeaves: 6,
+		},
+		{
+			name:     "build merkle tree keeping first occurrences should drop the duplicated leaves",
+			mode:     KEEPFIRST,
+			wantRoot: mtWithoutDuplicates.Root.Hash,
+			wantDropped: []DroppedLeaf{
+				{Index: 2, KeptIndex: 0, Data: data[2]},
+				{Index: 4, KeptIndex: 1, Data: data[4]},
+				{Index: 5, KeptIndex: 0, Data: data[5]},
+			},
+			wantNbLeaves: 4,
+		},
+		{
+			name:     "build merkle tree counting occurrences should drop the duplicated leaves and commit their count",
+			mode:     COUNT,
+			wantRoot: mtCounted.Root.Hash,
+			wantDropped: []DroppedLeaf{
+				{Index: 2, KeptIndex: 0, Data: data[2]},
+				{Index: 4, KeptIndex: 1, Data: data[4]},
+				{Index: 5, KeptIndex: 0, Data: data[5]},
+			},
+			wantNbLeaves: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a goroutine per leaf so that the leaves are hashed concurrently
+			mt, err := NewMerkleTreeBuilder().
+				WithHasher(configWithHashPool.Hasher).
+				WithMaxGoroutine(uint32(len(data))).
+				WithDeduplication(tt.mode).
+				Build(ctx, data)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if tt.wantRoot != nil {
+				assert.Equal(t, tt.wantRoot, mt.Root.Hash)
+			}
+			assert.Equal(t, tt.wantDropped, mt.DroppedLeaves)
+			assert.Len(t, mt.Leaves, tt.wantNbLeaves)
+		})
+	}
+
+	// the proofs of a tree counting its duplicates cover the count
+	mt, err := NewMerkleTreeBuilder().
+		WithHasher(configWithHashPool.Hasher).
+		WithMaxGoroutine(configWithHashPool.MaxGoroutine).
+		WithDeduplication(COUNT).
+		Build(ctx, data)
+	assert.NoError(t, err)
+	for _, d := range counted {
+		p, err := mt.Proof(d, SHA256)
+		assert.NoError(t, err)
+		isVerified, err := p.Verify(mt.Root.Hash)
+		assert.NoError(t, err)
+		assert.True(t, isVerified)
+	}
+	_, err = mt.Proof(data[0], SHA256)
+	assert.ErrorIs(t, err, ErrMerkleTreeLeafNotFound)
+	_, err = mt.Proof(CountedData{Data: data[0], Occurrences: 2}, SHA256)
+	assert.ErrorIs(t, err, ErrMerkleTreeLeafNotFound)
+}
@@ -0,0 +1,158 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DeletionMode is an enum representing how a leaf is deleted from the tree
+type DeletionMode string
+
+const (
+	// UNKNOWNDELETION is the default value returned for non-supported modes
+	UNKNOWNDELETION DeletionMode = "unknown"
+	// TOMBSTONE replaces the leaf by a leaf whose hash is a well-known tombstone hash so that indices stay stable
+	TOMBSTONE DeletionMode = "tombstone"
+	// REMOVE physically removes the leaf, the leaves on its right being shifted and the tree rebalanced
+	REMOVE DeletionMode = "remove"
+)
+
+var (
+	ErrDeletionModeNotAllowed               = errors.New("the deletion mode is not recognized")
+	ErrMerkleTreeLeafIsAlreadyDeleted       = errors.New("the merkle tree leaf has already been deleted")
+	ErrMerkleTreeConfigTombstoneHashIsWrong = errors.New("the merkle tree configWithHashPool tombstone hash must be the size of its hash algorithm digest")
+)
+
+// IsValid checks if a mode is valid
+func (s DeletionMode) IsValid() bool {
+	switch s {
+	case TOMBSTONE, REMOVE:
+		return true
+	case UNKNOWNDELETION:
+		return false
+	}
+	return false
+}
+
+// Validate returns an error if the mode is not supported
+func (s DeletionMode) Validate() error {
+	if !s.IsValid() {
+		return fmt.Errorf("DeletionMode<%s>: %w", s, ErrDeletionModeNotAllowed)
+	}
+	return nil
+}
+
+// Delete deletes the leaves at the indices passed in parameter according to the deletion mode of the tree
+// the indices are the ones of the leaves before the deletion, no leaf is deleted if one of them is not valid
+func (mt *MerkleTree) Delete(ctx context.Context, indices ...int) error {
+	if len(indices) == 0 {
+		return nil
+	}
+
+	deleted := make(map[int]struct{}, len(indices))
+	for _, index := range indices {
+		if index < 0 || index >= len(mt.Leaves) {
+			return fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeIndexOutOfRange)
+		}
+		if mt.Leaves[index].isOrphan {
+			return fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeLeafIsNotData)
+		}
+		if _, ok := deleted[index]; ok || (mt.Leaves[index].isTombstone && mt.deletionMode() == TOMBSTONE) {
+			return fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeLeafIsAlreadyDeleted)
+		}
+		deleted[index] = struct{}{}
+	}
+
+	if mt.deletionMode() == REMOVE {
+		return mt.remove(ctx, deleted)
+	}
+	return mt.tombstone(ctx, indices)
+}
+
+// tombstone replaces the leaves and their orphan copies by tombstone leaves and regenerates their ancestors
+func (mt *MerkleTree) tombstone(ctx context.Context, indices []int) error {
+	var (
+		dirty  []int
+		groups = make([][2]int, len(indices))
+	)
+
+	// the orphan copies are found before any modification as they are identified by the hash of the leaf
+	for i, index := range indices {
+		first, last := mt.orphanGroup(index)
+		groups[i] = [2]int{first, last}
+	}
+
+	for i, group := range groups {
+		dirty = append(dirty, mt.replaceLeafGroup(indices[i], group[0], group[1], newTombstoneLeaf(mt.tombstoneHashes()))...)
+	}
+
+	return mt.regenerateDirtyPaths(ctx, dirty)
+}
+
+// remove removes the leaves and regenerates the tree from the first leaf that has moved
+func (mt *MerkleTree) remove(ctx context.Context, deleted map[int]struct{}) error {
+	dataLeaves := make([]*Node, 0, len(mt.Leaves))
+	for i, leaf := range mt.Leaves {
+		if _, ok := deleted[i]; ok || leaf.isOrphan {
+			continue
+		}
+		dataLeaves = append(dataLeaves, leaf)
+	}
+
+	if len(dataLeaves) == 0 {
+		return ErrMerkleTreeDataIsNilOrEmpty
+	}
+
+	// perf: allocate the final array once with the orphan and padding leaves
+	leaves := make([]*Node, mt.nbLeaves(len(dataLeaves)))
+	copy(leaves, dataLeaves)
+	if err := mt.completeLeaves(leaves, len(dataLeaves)); err != nil {
+		return fmt.Errorf("mt.completeLeaves(): %w", err)
+	}
+
+	// the groups on the left of the first leaf that has moved are left untouched
+	firstDirty := 0
+	for firstDirty < len(leaves) && firstDirty < len(mt.Leaves) && leaves[firstDirty] == mt.Leaves[firstDirty] {
+		firstDirty++
+	}
+
+	// the changed indices go up to the last leaf before the removal as the leaves on its right have been shifted
+	nbLeaves := len(mt.Leaves)
+	if len(leaves) > nbLeaves {
+		nbLeaves = len(leaves)
+	}
+
+	mt.Leaves = leaves
+	root, err := mt.regenerateParentNodes(ctx, firstDirty)
+	if err != nil {
+		return fmt.Errorf("mt.regenerateParentNodes(): %w", err)
+	}
+	mt.setRoot(root, indexRange(firstDirty, nbLeaves))
+
+	return nil
+}
+
+// deletionMode returns the deletion mode of the tree, TOMBSTONE being the default one
+func (c *MerkleTreeConfig) deletionMode() DeletionMode {
+	if c.DeletionMode == "" {
+		return TOMBSTONE
+	}
+	return c.DeletionMode
+}
+
+// tombstoneHashes returns the tombstone hash for each hasher of the tree
+// a digest full of 0xff is used if none has been configured for the algorithm
+func (mt *MerkleTree) tombstoneHashes() [][]byte {
+	hashers := mt.hashers()
+	hashes := make([][]byte, len(hashers))
+	for i, h := range hashers {
+		if tombstoneHash, ok := mt.TombstoneHashes[h.Hash]; ok {
+			hashes[i] = tombstoneHash
+			continue
+		}
+		hashes[i] = bytes.Repeat([]byte{0xff}, h.Hash.Size())
+	}
+	return hashes
+}
@@ -0,0 +1,215 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMerkleTreeBuilder_WithDeletionMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          DeletionMode
+		tombstoneHash []byte
+		err           error
+	}{
+		{
+			name: "build with a non supported deletion mode should return error",
+			mode: DeletionMode("wrong"),
+			err:  ErrDeletionModeNotAllowed,
+		},
+		{
+			name:          "build with a tombstone hash of the wrong size should return error",
+			mode:          TOMBSTONE,
+			tombstoneHash: []byte{0x00},
+			err:           ErrMerkleTreeConfigTombstoneHashIsWrong,
+		},
+		{
+			name: "build without deletion mode should default to tombstone",
+		},
+		{
+			name:          "build with a tombstone hash of the digest size should not return error",
+			mode:          TOMBSTONE,
+			tombstoneHash: make([]byte, SHA256.Size()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewMerkleTreeBuilder().
+				WithHasher(configWithHashPool.Hasher).
+				WithMaxGoroutine(1000).
+				WithDeletionMode(tt.mode)
+			if tt.tombstoneHash != nil {
+				builder.WithTombstoneHash(SHA256, tt.tombstoneHash)
+			}
+			mt, err := builder.Build(ctx, dataUnEvenNbNodes)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err == nil && tt.mode == "" {
+				assert.Equal(t, TOMBSTONE, mt.DeletionMode)
+			}
+		})
+	}
+}
+
+func TestMerkleTree_DeleteTombstone(t *testing.T) {
+	tombstone := bytes.Repeat([]byte{0xff}, SHA256.Size())
+
+	tests := []struct {
+		name     string
+		arity    uint32
+		strategy OddNodeStrategy
+		indices  []int
+		err      error
+	}{
+		{
+			name:     "delete a leaf at an index out of range should return error",
+			strategy: DUPLICATE,
+			indices:  []int{0, 6},
+			err:      ErrMerkleTreeIndexOutOfRange,
+		},
+		{
+			name:     "delete an orphan leaf should return error",
+			strategy: DUPLICATE,
+			indices:  []int{5},
+			err:      ErrMerkleTreeLeafIsNotData,
+		},
+		{
+			name:     "delete the same leaf twice should return error",
+			strategy: DUPLICATE,
+			indices:  []int{1, 1},
+			err:      ErrMerkleTreeLeafIsAlreadyDeleted,
+		},
+		{
+			name:     "delete a duplicated leaf should tombstone its orphan copies",
+			strategy: DUPLICATE,
+			indices:  []int{4},
+		},
+		{
+			name:     "delete several leaves of a 4-ary tree should keep the indices stable",
+			arity:    4,
+			strategy: PROMOTE,
+			indices:  []int{0, 3},
+		},
+		{
+			name:     "delete a leaf of a padded tree should keep the padding leaves",
+			strategy: PAD,
+			indices:  []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Hasher{Hash: SHA256}
+			mt := buildTree(t, h, tt.arity, tt.strategy, dataUnEvenNbNodes)
+			nbLeaves := len(mt.Leaves)
+
+			err := mt.Delete(ctx, tt.indices...)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Delete() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			// a tombstone leaf hashes the same as a leaf whose hash is the tombstone hash
+			data := make([]Data, len(dataUnEvenNbNodes))
+			copy(data, dataUnEvenNbNodes)
+			for _, index := range tt.indices {
+				data[index] = HashedData{Value: tombstone}
+			}
+			expected := buildTree(t, h, tt.arity, tt.strategy, data)
+			assert.Equal(t, expected.Root.Hash, mt.Root.Hash)
+			assert.Equal(t, nbLeaves, len(mt.Leaves))
+
+			// deleting the same leaf again should return error
+			assert.ErrorIs(t, mt.Delete(ctx, tt.indices[0]), ErrMerkleTreeLeafIsAlreadyDeleted)
+
+			// the proofs of the remaining leaves should still be valid
+			for i, d := range dataUnEvenNbNodes {
+				if mt.Leaves[i].isTombstone {
+					continue
+				}
+				p, err := mt.Proof(d, SHA256)
+				assert.NoError(t, err)
+				isVerified, err := p.Verify(mt.Root.Hash)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		})
+	}
+}
+
+func TestMerkleTree_DeleteRemove(t *testing.T) {
+	removeMode := func(b *MerkleTreeBuilder) { b.WithDeletionMode(REMOVE) }
+
+	tests := []struct {
+		name     string
+		arity    uint32
+		strategy OddNodeStrategy
+		indices  []int
+		err      error
+	}{
+		{
+			name:     "remove all the leaves should return error",
+			strategy: DUPLICATE,
+			indices:  []int{0, 1, 2, 3, 4},
+			err:      ErrMerkleTreeDataIsNilOrEmpty,
+		},
+		{
+			name:     "remove the last leaf should return the same root as a rebuilt tree",
+			strategy: DUPLICATE,
+			indices:  []int{4},
+		},
+		{
+			name:     "remove a leaf in the middle of a 4-ary tree should return the same root as a rebuilt tree",
+			arity:    4,
+			strategy: PROMOTE,
+			indices:  []int{2},
+		},
+		{
+			name:     "remove several leaves of a padded tree should return the same root as a rebuilt tree",
+			strategy: PAD,
+			indices:  []int{3, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Hasher{Hash: SHA256}
+			mt := buildTree(t, h, tt.arity, tt.strategy, dataUnEvenNbNodes, removeMode)
+
+			err := mt.Delete(ctx, tt.indices...)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Delete() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			var data []Data
+			for i, d := range dataUnEvenNbNodes {
+				isRemoved := false
+				for _, index := range tt.indices {
+					isRemoved = isRemoved || index == i
+				}
+				if !isRemoved {
+					data = append(data, d)
+				}
+			}
+			expected := buildTree(t, h, tt.arity, tt.strategy, data, removeMode)
+			assert.Equal(t, expected.Root.Hash, mt.Root.Hash)
+			assert.Equal(t, len(expected.Leaves), len(mt.Leaves))
+			assert.Equal(t, data, leavesData(mt))
+
+			for _, d := range data {
+				isVerified, err := mt.Verify(ctx, d)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		})
+	}
+}
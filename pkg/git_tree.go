@@ -0,0 +1,263 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// GitTree is the Merkle DAG of a directory hashed as git does: the files are blob objects, the directories tree objects
+// listing the mode, the name and the hash of their entries, so that the hash of the root is the one returned by
+// git write-tree once all the files of the directory have been added to the index
+// as in git, the empty directories are left out and the .git directories are skipped, the files ignored by git are
+// however hashed as any other file
+type GitTree struct {
+	GitTreeConfig
+	Root *GitTreeEntry
+}
+
+// GitTreeConfig is the configuration that represents the options used to build the tree
+// the hash is either SHA1 or SHA256, the latter being the hash of the repositories initiated with
+// --object-format=sha256
+type GitTreeConfig struct {
+	Hasher       *Hasher
+	MaxGoroutine uint32
+}
+
+// GitTreeBuilder allows use to pass the configuration before building a git tree
+type GitTreeBuilder struct {
+	config *GitTreeConfig
+}
+
+// GitTreeEntry is an entry of a tree object, Entries being the entries of a directory
+type GitTreeEntry struct {
+	Name    string
+	Mode    string
+	Hash    []byte
+	Entries []*GitTreeEntry
+}
+
+// git modes of the entries of a tree object
+const (
+	GitModeFile       = "100644"
+	GitModeExecutable = "100755"
+	GitModeSymlink    = "120000"
+	GitModeTree       = "40000"
+)
+
+var (
+	ErrGitTreeHashNotAllowed = errors.New("the git tree hash must be sha1 or sha256")
+	ErrGitTreeFileHasChanged = errors.New("the git tree file has changed while being hashed")
+)
+
+func NewGitTreeBuilder() *GitTreeBuilder {
+	return &GitTreeBuilder{config: &GitTreeConfig{}}
+}
+
+func (b *GitTreeBuilder) WithHasher(hasher *Hasher) *GitTreeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+func (b *GitTreeBuilder) WithMaxGoroutine(maxGoroutine uint32) *GitTreeBuilder {
+	b.config.MaxGoroutine = maxGoroutine
+	return b
+}
+
+// Build walks the directory passed in parameter, hashes its files in parallel then hashes its trees bottom up
+func (b *GitTreeBuilder) Build(ctx context.Context, dir string) (*GitTree, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if b.config.Hasher.Hash != SHA1 && b.config.Hasher.Hash != SHA256 {
+		return nil, fmt.Errorf("hash<%s>: %w", b.config.Hasher.Hash, ErrGitTreeHashNotAllowed)
+	}
+
+	if b.config.MaxGoroutine == 0 {
+		return nil, ErrMerkleTreeConfigMaxGoroutineIsEqZero
+	}
+
+	t := &GitTree{
+		GitTreeConfig: *b.config,
+		Root:          &GitTreeEntry{Mode: GitModeTree},
+	}
+
+	var files []gitFile
+	if err := t.readDir(dir, t.Root, &files); err != nil {
+		return nil, err
+	}
+	if err := t.hashFiles(ctx, files); err != nil {
+		return nil, err
+	}
+	if err := t.hashTree(t.Root); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GitObjectHash returns the hash of a git object, i.e. H(type || " " || size || "\x00" || content)
+func GitObjectHash(h *Hasher, objectType string, content []byte) ([]byte, error) {
+	header := []byte(fmt.Sprintf("%s %d\x00", objectType, len(content)))
+	hash, err := h.hashData(append(header, content...))
+	if err != nil {
+		return nil, fmt.Errorf("h.hashData(%s): %w", objectType, err)
+	}
+	return hash, nil
+}
+
+// gitFile is a file whose blob has to be hashed
+type gitFile struct {
+	entry *GitTreeEntry
+	path  string
+	size  int64
+}
+
+// readDir adds the entries of the directory to the tree passed in parameter, the files being collected so that they
+// are hashed afterwards
+func (t *GitTree) readDir(dir string, tree *GitTreeEntry, files *[]gitFile) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("os.ReadDir(%s): %w", dir, err)
+	}
+
+	for _, e := range entries {
+		if e.Name() == ".git" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		info, err := os.Lstat(path)
+		if err != nil {
+			return fmt.Errorf("os.Lstat(%s): %w", path, err)
+		}
+
+		entry := &GitTreeEntry{Name: e.Name()}
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			entry.Mode = GitModeTree
+			if err = t.readDir(path, entry, files); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			entry.Mode = GitModeSymlink
+			*files = append(*files, gitFile{entry: entry, path: path})
+		case mode.IsRegular():
+			entry.Mode = GitModeFile
+			// as git, only the executable bit of the owner is looked at
+			if mode&0o100 != 0 {
+				entry.Mode = GitModeExecutable
+			}
+			*files = append(*files, gitFile{entry: entry, path: path, size: info.Size()})
+		default:
+			// sockets, devices and pipes cannot be added to git
+			continue
+		}
+		tree.Entries = append(tree.Entries, entry)
+	}
+	return nil
+}
+
+// hashFiles hashes the blobs of the files in parallel
+func (t *GitTree) hashFiles(ctx context.Context, files []gitFile) error {
+	errs, _ := errgroup.WithContext(ctx)
+	errs.SetLimit(int(t.MaxGoroutine))
+	for _i := 0; _i < len(files); _i++ {
+		// i can change in the below go routine, allocates a local scope via i
+		i := _i
+
+		errs.Go(func() error {
+			hash, err := t.hashFile(files[i])
+			if err != nil {
+				return fmt.Errorf("t.hashFile(%s): %w", files[i].path, err)
+			}
+			files[i].entry.Hash = hash
+			return nil
+		})
+	}
+
+	// wait for all the go routines to be done
+	return errs.Wait()
+}
+
+// hashFile hashes the blob of a file, the content of a symbolic link being its target
+// regular files are streamed into the hash so that they are not loaded in memory
+func (t *GitTree) hashFile(file gitFile) ([]byte, error) {
+	if file.entry.Mode == GitModeSymlink {
+		target, err := os.Readlink(file.path)
+		if err != nil {
+			return nil, fmt.Errorf("os.Readlink(): %w", err)
+		}
+		return GitObjectHash(t.Hasher, "blob", []byte(filepath.ToSlash(target)))
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(): %w", err)
+	}
+	defer f.Close()
+
+	hf := t.Hasher.getHash()
+	defer hf.Close()
+
+	if _, err = fmt.Fprintf(hf, "blob %d\x00", file.size); err != nil {
+		return nil, fmt.Errorf("hf.Write(header): %w", err)
+	}
+	n, err := io.Copy(hf, f)
+	if err != nil {
+		return nil, fmt.Errorf("io.Copy(): %w", err)
+	}
+	if n != file.size {
+		return nil, fmt.Errorf("size<%d> read<%d>: %w", file.size, n, ErrGitTreeFileHasChanged)
+	}
+	return hf.Sum(nil), nil
+}
+
+// hashTree hashes the trees bottom up, leaving out the empty ones
+// the entries are sorted as git does, i.e. by name, the name of a tree being followed by a slash
+func (t *GitTree) hashTree(tree *GitTreeEntry) error {
+	entries := tree.Entries[:0]
+	for _, e := range tree.Entries {
+		if e.Mode == GitModeTree {
+			if err := t.hashTree(e); err != nil {
+				return err
+			}
+			if len(e.Entries) == 0 {
+				continue
+			}
+		}
+		entries = append(entries, e)
+	}
+	tree.Entries = entries
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].sortName() < entries[j].sortName()
+	})
+
+	var content []byte
+	for _, e := range entries {
+		content = append(content, e.Mode+" "+e.Name+"\x00"...)
+		content = append(content, e.Hash...)
+	}
+	hash, err := GitObjectHash(t.Hasher, "tree", content)
+	if err != nil {
+		return err
+	}
+	tree.Hash = hash
+	return nil
+}
+
+// sortName returns the name the entries are sorted by
+func (e *GitTreeEntry) sortName() string {
+	if e.Mode == GitModeTree {
+		return e.Name + "/"
+	}
+	return e.Name
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeGitTreeDir creates a directory made of files, an executable, a symbolic link, nested and empty directories
+// and a .git directory
+func writeGitTreeDir(t *testing.T) string {
+	dir := t.TempDir()
+	for _, d := range []string{"dir/sub", "empty", "empty2/inner", ".git/objects"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, d), 0o755))
+	}
+	files := []struct {
+		path    string
+		content string
+		mode    os.FileMode
+	}{
+		{path: "a.txt", content: "hello\n", mode: 0o644},
+		{path: "exec.sh", content: "#!/bin/sh\n", mode: 0o755},
+		{path: "dir/b.txt", content: "b\n", mode: 0o644},
+		{path: "dir/sub/c.txt", content: "", mode: 0o644},
+		{path: "dir.txt", content: "x", mode: 0o644},
+		{path: ".git/HEAD", content: "ref: refs/heads/main\n", mode: 0o644},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		assert.NoError(t, os.WriteFile(path, []byte(f.content), f.mode))
+		assert.NoError(t, os.Chmod(path, f.mode))
+	}
+	assert.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "link")))
+	return dir
+}
+
+func TestGitTreeBuilder_Build(t *testing.T) {
+	dir := writeGitTreeDir(t)
+
+	// the expected hashes are the ones returned by git write-tree once the directory has been added to the index of a
+	// repository of the same object format
+	tests := []struct {
+		name         string
+		hasher       *Hasher
+		maxGoroutine uint32
+		root         string
+		dir          string
+		sub          string
+		err          error
+	}{
+		{
+			name:         "build a git tree without hasher should return error",
+			hasher:       nil,
+			maxGoroutine: 1,
+			err:          ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:         "build a git tree with sha512 should return error",
+			hasher:       &Hasher{Hash: SHA512},
+			maxGoroutine: 1,
+			err:          ErrGitTreeHashNotAllowed,
+		},
+		{
+			name:         "build a git tree without goroutine should return error",
+			hasher:       &Hasher{Hash: SHA1},
+			maxGoroutine: 0,
+			err:          ErrMerkleTreeConfigMaxGoroutineIsEqZero,
+		},
+		{
+			name:         "build a sha1 git tree should return the hashes of git",
+			hasher:       &Hasher{Hash: SHA1, Pool: SHA1.NewPool()},
+			maxGoroutine: 2,
+			root:         "45a20cc3be8493d0fda92beffca76cde620502a2",
+			dir:          "41e2000d099507294c05ceb84c2838a2e02862f8",
+			sub:          "1721a7a91e87f5413c842a9c5ce73f674459e92b",
+		},
+		{
+			name:         "build a sha256 git tree should return the hashes of git",
+			hasher:       &Hasher{Hash: SHA256},
+			maxGoroutine: 1,
+			root:         "3cc3da902c28edeee41f8100ff89263b124581d1af399471904b9a4d271b3e9d",
+			dir:          "f0afe4dd2531e8befa29a493cd187a46d180834806ad084802826627ad73d693",
+			sub:          "ca2b3e20bcce92c74e2e16617d97cb301864e51f013cde26722796a1edae21b2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewGitTreeBuilder().WithHasher(tt.hasher).WithMaxGoroutine(tt.maxGoroutine).Build(ctx, dir)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.root, hex.EncodeToString(tree.Root.Hash))
+
+			// the empty directories and the .git directory are left out, a tree is sorted after a file whose name
+			// starts with the name of the tree followed by a character lower than a slash
+			var names []string
+			for _, e := range tree.Root.Entries {
+				names = append(names, e.Name)
+			}
+			assert.Equal(t, []string{"a.txt", "dir.txt", "dir", "exec.sh", "link"}, names)
+			assert.Equal(t, GitModeTree, tree.Root.Entries[2].Mode)
+			assert.Equal(t, tt.dir, hex.EncodeToString(tree.Root.Entries[2].Hash))
+			assert.Equal(t, tt.sub, hex.EncodeToString(tree.Root.Entries[2].Entries[1].Hash))
+			assert.Equal(t, GitModeExecutable, tree.Root.Entries[3].Mode)
+			assert.Equal(t, GitModeSymlink, tree.Root.Entries[4].Mode)
+		})
+	}
+}
+
+func TestGitObjectHash(t *testing.T) {
+	tests := []struct {
+		name       string
+		hash       Hash
+		objectType string
+		content    string
+		want       string
+	}{
+		{
+			name:       "sha1 blob",
+			hash:       SHA1,
+			objectType: "blob",
+			content:    "hello\n",
+			want:       "ce013625030ba8dba906f756967f9e9ca394464a",
+		},
+		{
+			name:       "sha1 empty tree",
+			hash:       SHA1,
+			objectType: "tree",
+			want:       "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+		},
+		{
+			name:       "sha256 empty tree",
+			hash:       SHA256,
+			objectType: "tree",
+			want:       "6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GitObjectHash(&Hasher{Hash: tt.hash}, tt.objectType, []byte(tt.content))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, hex.EncodeToString(got))
+		})
+	}
+
+	// an empty directory is the empty tree
+	tree, err := NewGitTreeBuilder().WithHasher(&Hasher{Hash: SHA1}).WithMaxGoroutine(1).Build(ctx, t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", hex.EncodeToString(tree.Root.Hash))
+}
@@ -2,18 +2,26 @@ package pkg
 
 import (
 	"crypto"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"errors"
 	"fmt"
+	"golang.org/x/crypto/sha3"
 	"hash"
 	"sync"
 )
 
 // Hasher is an enum representing a Hash algorithm
+// IsSortLeaves reorders all the leaves by hash before building the tree whereas IsSortPairs orders each pair of hashes
+// before hashing them together (OpenZeppelin compatibility), both options being independent
+// DigestLength truncates the parent node hashes to the first DigestLength bytes, 0 means no truncation
 type Hasher struct {
-	IsSort bool
-	Hash   Hash
-	Pool   *HashPool
+	IsSortLeaves bool
+	IsSortPairs  bool
+	Hash         Hash
+	Pool         *HashPool
+	DigestLength uint32
 }
 
 type Hash string
@@ -21,16 +29,33 @@ type Hash string
 const (
 	// UNKNOWNHASH is the default value returned for non-supported protocol
 	UNKNOWNHASH Hash = "unknown"
+	// SHA1 is the identifier for the SHA1 Hash algorithm, it is broken and only meant to be compatible with git
+	SHA1 Hash = "sha1"
 	// SHA256 is the identifier for the SHA256 Hash algorithm
 	SHA256 Hash = "sha256"
+	// SHA384 is the identifier for the SHA384 Hash algorithm
+	SHA384 Hash = "sha384"
+	// SHA512 is the identifier for the SHA512 Hash algorithm
+	SHA512 Hash = "sha512"
+	// KECCAK256 is the identifier for the legacy Keccak-256 Hash algorithm used by Ethereum, it differs from SHA3-256
+	// by its padding
+	KECCAK256 Hash = "keccak256"
 )
 
-var ErrHashNotAllowed = errors.New("Hash<%s> is not recognized")
+// MinDigestLength is the lowest length a parent node hash can be truncated to
+// below 16 bytes, finding a collision between two internal nodes becomes practical
+const MinDigestLength = 16
+
+var (
+	ErrHashNotAllowed           = errors.New("Hash<%s> is not recognized")
+	ErrHashDigestLengthTooShort = errors.New("the digest length cannot be lower than the minimum digest length")
+	ErrHashDigestLengthTooLong  = errors.New("the digest length cannot be greater than the hash algorithm digest size")
+)
 
 // IsValid checks if a protocol is valid
 func (s Hash) IsValid() bool {
 	switch s {
-	case SHA256:
+	case SHA1, SHA256, SHA384, SHA512, KECCAK256:
 		return true
 	case UNKNOWNHASH:
 		return false
@@ -38,22 +63,129 @@ func (s Hash) IsValid() bool {
 	return false
 }
 
+// Hash returns the crypto.Hash identifier of the Hash algorithm
+// Keccak-256 does not have any, NewPool has to be used instead of NewHashPool to pool it
 func (s Hash) Hash() crypto.Hash {
 	switch s {
+	case SHA1:
+		return crypto.SHA1
 	case SHA256:
 		return crypto.SHA256
+	case SHA384:
+		return crypto.SHA384
+	case SHA512:
+		return crypto.SHA512
 	}
 	panic(fmt.Sprintf(ErrHashNotAllowed.Error(), s))
 }
 
 func (s Hash) HashFunc() func() hash.Hash {
 	switch s {
+	case SHA1:
+		return sha1.New
 	case SHA256:
 		return sha256.New
+	case SHA384:
+		return sha512.New384
+	case SHA512:
+		return sha512.New
+	case KECCAK256:
+		return sha3.NewLegacyKeccak256
+	}
+	panic(fmt.Sprintf(ErrHashNotAllowed.Error(), s))
+}
+
+// Size returns the length in bytes of a digest produced by the Hash algorithm
+func (s Hash) Size() int {
+	switch s {
+	case SHA1:
+		return sha1.Size
+	case SHA256, KECCAK256:
+		return sha256.Size
+	case SHA384:
+		return sha512.Size384
+	case SHA512:
+		return sha512.Size
 	}
 	panic(fmt.Sprintf(ErrHashNotAllowed.Error(), s))
 }
 
+// MultihashCode returns the code identifying the Hash algorithm in a multihash, e.g. within a CID
+func (s Hash) MultihashCode() uint64 {
+	switch s {
+	case SHA1:
+		return 0x11
+	case SHA256:
+		return 0x12
+	case SHA384:
+		return 0x20
+	case SHA512:
+		return 0x13
+	case KECCAK256:
+		return 0x1b
+	}
+	panic(fmt.Sprintf(ErrHashNotAllowed.Error(), s))
+}
+
+// NewPool allocates a new pool of the Hash algorithm
+func (s Hash) NewPool() *HashPool {
+	return newHashPool(s.HashFunc())
+}
+
+// ValidateDigestLength checks if the hash algorithm digests can be truncated to the length passed in parameter
+func (s Hash) ValidateDigestLength(length uint32) error {
+	if length == 0 {
+		return nil
+	}
+	if length < MinDigestLength {
+		return fmt.Errorf("length<%d>: %w", length, ErrHashDigestLengthTooShort)
+	}
+	if int(length) > s.Size() {
+		return fmt.Errorf("hash<%s> length<%d>: %w", s, length, ErrHashDigestLengthTooLong)
+	}
+	return nil
+}
+
+// getHash returns a Hash func instance either from the pool or freshly allocated if no pool has been configured
+// the returned instance must be closed once done so that pooled instances go back to the pool
+func (h *Hasher) getHash() HashCloser {
+	if h.Pool == nil {
+		return &hashFunc{Hash: h.Hash.HashFunc()()}
+	}
+	return h.Pool.getHash()
+}
+
+// hashData hashes a raw piece of data
+func (h *Hasher) hashData(b []byte) ([]byte, error) {
+	hf := h.getHash()
+	defer hf.Close()
+
+	if _, err := hf.Write(b); err != nil {
+		return nil, fmt.Errorf("hf.Write(%x): %w", b, err)
+	}
+	return hf.Sum(nil), nil
+}
+
+// hashChildren hashes the concatenation of the children hashes
+// the result is truncated if a digest length has been configured
+func (h *Hasher) hashChildren(children ...[]byte) ([]byte, error) {
+	hf := h.getHash()
+	defer hf.Close()
+
+	if err := writeConcat(hf, h.Pool != nil, h.IsSortPairs, children...); err != nil {
+		return nil, fmt.Errorf("hf.Write(concat(%x)): %w", children, err)
+	}
+	return h.truncate(hf.Sum(nil)), nil
+}
+
+// truncate truncates a digest to the configured digest length
+func (h *Hasher) truncate(b []byte) []byte {
+	if h.DigestLength == 0 || int(h.DigestLength) >= len(b) {
+		return b
+	}
+	return b[:h.DigestLength]
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 // HashPool is the pool of hashes
@@ -63,9 +195,14 @@ type HashPool struct {
 
 // NewHashPool allocates a new pool
 func NewHashPool(h crypto.Hash) *HashPool {
+	return newHashPool(h.New)
+}
+
+// newHashPool allocates a new pool of the hashes created by the function passed in parameter
+func newHashPool(newHash func() hash.Hash) *HashPool {
 	p := &HashPool{}
 	p.hashFunc.New = func() interface{} {
-		return &hashFunc{Hash: h.New(), pool: &p.hashFunc}
+		return &hashFunc{Hash: newHash(), pool: &p.hashFunc}
 	}
 	return p
 }
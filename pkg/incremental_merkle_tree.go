@@ -0,0 +1,278 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// IncrementalMerkleTree is a tree of fixed depth where the leaves are appended one after the other
+// this is the Ethereum deposit contract pattern: unfilled leaves are a zero value, the hashes of the zero subtrees are
+// precomputed once per hash algorithm and appending a leaf only updates the branch, i.e. one hash per level
+type IncrementalMerkleTree struct {
+	IncrementalMerkleTreeConfig
+	branch     [][]byte
+	size       uint64
+	leaves     [][]byte
+	zeroHashes [][]byte
+}
+
+// IncrementalMerkleTreeConfig is the configuration that represents the options used to build the tree
+// IsKeepLeaves keeps the leaf hashes in memory in order to be able to generate proofs, otherwise only the branch is kept
+type IncrementalMerkleTreeConfig struct {
+	Hasher       *Hasher
+	Depth        uint32
+	IsKeepLeaves bool
+}
+
+// IncrementalMerkleTreeBuilder allows use to pass the configuration before building an incremental tree
+type IncrementalMerkleTreeBuilder struct {
+	config *IncrementalMerkleTreeConfig
+}
+
+const (
+	// DepositContractTreeDepth is the depth of the Ethereum deposit contract tree
+	DepositContractTreeDepth = 32
+	// MaxIncrementalMerkleTreeDepth is the highest depth supported as the size of the tree is stored on 64 bits
+	MaxIncrementalMerkleTreeDepth = 64
+)
+
+var (
+	ErrIncrementalMerkleTreeConfigDepthIsWrong = errors.New("the incremental merkle tree depth must be between 1 and the max depth")
+	ErrIncrementalMerkleTreeIsFull             = errors.New("the incremental merkle tree is full")
+	ErrIncrementalMerkleTreeLeavesNotKept      = errors.New("the incremental merkle tree does not keep its leaves")
+	ErrIncrementalMerkleTreeIndexOutOfRange    = errors.New("the incremental merkle tree does not contain the index")
+)
+
+func NewIncrementalMerkleTreeBuilder() *IncrementalMerkleTreeBuilder {
+	return &IncrementalMerkleTreeBuilder{config: &IncrementalMerkleTreeConfig{Depth: DepositContractTreeDepth}}
+}
+
+func (b *IncrementalMerkleTreeBuilder) WithHasher(hasher *Hasher) *IncrementalMerkleTreeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+func (b *IncrementalMerkleTreeBuilder) WithDepth(depth uint32) *IncrementalMerkleTreeBuilder {
+	b.config.Depth = depth
+	return b
+}
+
+// WithKeepLeaves keeps the leaves in memory so that proofs can be generated at the cost of O(n) memory
+func (b *IncrementalMerkleTreeBuilder) WithKeepLeaves(isKeepLeaves bool) *IncrementalMerkleTreeBuilder {
+	b.config.IsKeepLeaves = isKeepLeaves
+	return b
+}
+
+// Build creates an empty tree, its root being the hash of the zero subtree of the tree depth
+func (b *IncrementalMerkleTreeBuilder) Build() (*IncrementalMerkleTree, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if b.config.Depth == 0 || b.config.Depth > MaxIncrementalMerkleTreeDepth {
+		return nil, fmt.Errorf("depth<%d>: %w", b.config.Depth, ErrIncrementalMerkleTreeConfigDepthIsWrong)
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if err := b.config.Hasher.Hash.ValidateDigestLength(b.config.Hasher.DigestLength); err != nil {
+		return nil, fmt.Errorf("Hash.ValidateDigestLength(): %w", err)
+	}
+
+	zeroHashes, err := getZeroHashes(b.config.Hasher, b.config.Depth)
+	if err != nil {
+		return nil, fmt.Errorf("getZeroHashes(): %w", err)
+	}
+
+	return &IncrementalMerkleTree{
+		IncrementalMerkleTreeConfig: *b.config,
+		branch:                      make([][]byte, b.config.Depth),
+		zeroHashes:                  zeroHashes,
+	}, nil
+}
+
+// Append appends the data as leaves of the tree
+// for each leaf only the nodes of the branch that are complete are updated
+func (t *IncrementalMerkleTree) Append(data ...Data) error {
+	for _, d := range data {
+		if t.size == t.capacity() {
+			return ErrIncrementalMerkleTreeIsFull
+		}
+
+		leaf, err := d.Hash(t.Hasher)
+		if err != nil {
+			return fmt.Errorf("d.Hasher(): data<%s>: %w", d, err)
+		}
+		if t.IsKeepLeaves {
+			t.leaves = append(t.leaves, leaf)
+		}
+
+		t.size++
+		node, size := leaf, t.size
+		for h := 0; h < int(t.Depth); h++ {
+			// the subtree of this level is not complete yet, the node is stored until its sibling arrives
+			if size&1 == 1 {
+				t.branch[h] = node
+				break
+			}
+			if node, err = t.Hasher.hashChildren(t.branch[h], node); err != nil {
+				return fmt.Errorf("t.Hasher.hashChildren(): %w", err)
+			}
+			size >>= 1
+		}
+	}
+	return nil
+}
+
+// Size returns the nb of leaves appended to the tree
+func (t *IncrementalMerkleTree) Size() uint64 {
+	return t.size
+}
+
+// Root returns the root of the tree, the leaves that have not been appended yet being zero values
+func (t *IncrementalMerkleTree) Root() ([]byte, error) {
+	var (
+		node = t.zeroHashes[0]
+		size = t.size
+		err  error
+	)
+	for h := 0; h < int(t.Depth); h++ {
+		if size&1 == 1 {
+			node, err = t.Hasher.hashChildren(t.branch[h], node)
+		} else {
+			node, err = t.Hasher.hashChildren(node, t.zeroHashes[h])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("t.Hasher.hashChildren(): %w", err)
+		}
+		size >>= 1
+	}
+	return node, nil
+}
+
+// DepositRoot returns the root as computed by the Ethereum deposit contract, i.e. the root mixed in with the size
+func (t *IncrementalMerkleTree) DepositRoot() ([]byte, error) {
+	root, err := t.Root()
+	if err != nil {
+		return nil, err
+	}
+	return t.Hasher.hashChildren(root, t.sizeMixIn())
+}
+
+// Proof generates the inclusion proof of the leaf at the index passed in parameter against Root
+// the leaves must have been kept in memory
+func (t *IncrementalMerkleTree) Proof(index uint64) (*Proof, error) {
+	if !t.IsKeepLeaves {
+		return nil, ErrIncrementalMerkleTreeLeavesNotKept
+	}
+	if index >= t.size {
+		return nil, fmt.Errorf("index<%d>: %w", index, ErrIncrementalMerkleTreeIndexOutOfRange)
+	}
+
+	p := &Proof{
+		Hash:            t.Hasher.Hash,
+		IsSortPairs:     t.Hasher.IsSortPairs,
+		DigestLength:    t.Hasher.DigestLength,
+		OddNodeStrategy: PAD,
+		Arity:           DefaultArity,
+		Index:           int(index),
+		Leaf:            t.leaves[index],
+		Steps:           make([]ProofStep, t.Depth),
+	}
+
+	// compute each level from the leaves, the missing nodes being the zero subtrees
+	level := t.leaves
+	for h := 0; h < int(t.Depth); h++ {
+		sibling := t.zeroHashes[h]
+		if i := index ^ 1; i < uint64(len(level)) {
+			sibling = level[i]
+		}
+		p.Steps[h] = ProofStep{Siblings: [][]byte{sibling}, Position: int(index & 1)}
+
+		next := make([][]byte, (len(level)+1)/2)
+		for i := range next {
+			right := t.zeroHashes[h]
+			if 2*i+1 < len(level) {
+				right = level[2*i+1]
+			}
+			node, err := t.Hasher.hashChildren(level[2*i], right)
+			if err != nil {
+				return nil, fmt.Errorf("t.Hasher.hashChildren(): %w", err)
+			}
+			next[i] = node
+		}
+		level, index = next, index>>1
+	}
+	return p, nil
+}
+
+// DepositProof generates the inclusion proof of the leaf at the index passed in parameter against DepositRoot
+// the last step being the size the root is mixed in with
+func (t *IncrementalMerkleTree) DepositProof(index uint64) (*Proof, error) {
+	p, err := t.Proof(index)
+	if err != nil {
+		return nil, err
+	}
+	p.Steps = append(p.Steps, ProofStep{Siblings: [][]byte{t.sizeMixIn()}, Position: 0})
+	return p, nil
+}
+
+// capacity returns the max nb of leaves of the tree
+// as in the deposit contract, the last leaf is never filled so that the branch always contains the complete subtrees
+func (t *IncrementalMerkleTree) capacity() uint64 {
+	if t.Depth == MaxIncrementalMerkleTreeDepth {
+		return ^uint64(0)
+	}
+	return 1<<t.Depth - 1
+}
+
+// sizeMixIn returns the size of the tree encoded in little endian and padded to the size of a digest
+func (t *IncrementalMerkleTree) sizeMixIn() []byte {
+	b := make([]byte, len(t.zeroHashes[0]))
+	binary.LittleEndian.PutUint64(b, t.size)
+	return b
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// zeroHashesKey identifies the parameters the zero subtree hashes depend on
+type zeroHashesKey struct {
+	hash         Hash
+	digestLength uint32
+}
+
+var (
+	zeroHashesMu    sync.Mutex
+	zeroHashesCache = map[zeroHashesKey][][]byte{}
+)
+
+// getZeroHashes returns the hashes of the zero subtrees of each height up to the depth passed in parameter
+// they are computed once per hash algorithm and shared by all the trees
+func getZeroHashes(h *Hasher, depth uint32) ([][]byte, error) {
+	zeroHashesMu.Lock()
+	defer zeroHashesMu.Unlock()
+
+	key := zeroHashesKey{hash: h.Hash, digestLength: h.DigestLength}
+	zeroHashes := zeroHashesCache[key]
+	if len(zeroHashes) == 0 {
+		zeroHashes = [][]byte{make([]byte, h.Hash.Size())}
+	}
+	for i := len(zeroHashes); i <= int(depth); i++ {
+		node, err := h.hashChildren(zeroHashes[i-1], zeroHashes[i-1])
+		if err != nil {
+			return nil, fmt.Errorf("h.hashChildren(): %w", err)
+		}
+		zeroHashes = append(zeroHashes, node)
+	}
+	zeroHashesCache[key] = zeroHashes
+
+	return zeroHashes[:depth+1], nil
+}
@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIncrementalMerkleTreeBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth uint32
+		want  string
+		err   error
+	}{
+		{
+			name:  "build incremental merkle tree with a depth of 0 should return error",
+			depth: 0,
+			err:   ErrIncrementalMerkleTreeConfigDepthIsWrong,
+		},
+		{
+			name:  "build incremental merkle tree with a depth greater than the max depth should return error",
+			depth: MaxIncrementalMerkleTreeDepth + 1,
+			err:   ErrIncrementalMerkleTreeConfigDepthIsWrong,
+		},
+		{
+			name:  "build empty deposit contract tree should return the empty deposit root",
+			depth: DepositContractTreeDepth,
+			want:  "d70a234731285c6804c2a4f56711ddb8c82c99740f207854891028af34e27e5e",
+			err:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewIncrementalMerkleTreeBuilder().WithHasher(configWithHashPool.Hasher).WithDepth(tt.depth).Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			got, err := tree.DepositRoot()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, hex.EncodeToString(got))
+		})
+	}
+
+	// an unknown hash algorithm is rejected instead of panicking once the hashes are computed
+	_, err := NewIncrementalMerkleTreeBuilder().WithHasher(&Hasher{Hash: "md5"}).Build()
+	assert.EqualError(t, err, fmt.Sprintf(ErrHashNotAllowed.Error(), "md5"))
+}
+
+func TestIncrementalMerkleTree_Append(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth uint32
+		data  []Data
+		err   error
+	}{
+		{
+			name:  "append leaves should return the same root as a padded merkle tree",
+			depth: 3,
+			data:  dataUnEvenNbNodes,
+			err:   nil,
+		},
+		{
+			name:  "append more leaves than the capacity should return error",
+			depth: 2,
+			data:  dataUnEvenNbNodes,
+			err:   ErrIncrementalMerkleTreeIsFull,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewIncrementalMerkleTreeBuilder().
+				WithHasher(configWithHashPool.Hasher).
+				WithDepth(tt.depth).
+				WithKeepLeaves(true).
+				Build()
+			assert.NoError(t, err)
+
+			err = tree.Append(tt.data...)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Append() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, uint64(len(tt.data)), tree.Size())
+
+			mt, err := NewMerkleTreeBuilder().
+				WithHasher(configWithHashPool.Hasher).
+				WithMaxGoroutine(configWithHashPool.MaxGoroutine).
+				WithOddNodeStrategy(PAD).
+				Build(ctx, tt.data)
+			assert.NoError(t, err)
+			root, err := tree.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, mt.Root.Hash, root)
+
+			depositRoot, err := tree.DepositRoot()
+			assert.NoError(t, err)
+			for i := range tt.data {
+				p, err := tree.Proof(uint64(i))
+				assert.NoError(t, err)
+				isVerified, err := p.Verify(root)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+
+				p, err = tree.DepositProof(uint64(i))
+				assert.NoError(t, err)
+				assert.Len(t, p.Steps, int(tt.depth)+1)
+				isVerified, err = p.Verify(depositRoot)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// IndexedMerkleTree is a tree of fixed depth whose leaves form a linked list sorted by value, as the nullifier trees of
+// Aztec: each leaf stores its value, the index and the value of the leaf holding the next greater value, so that the
+// non-membership of a value is proved by the inclusion of the "low leaf" whose value is lower and whose next value is
+// greater, the end of the list being pointed at by a next index and a next value of zero
+// the leaves are appended as in an incremental tree, the first one being the zero value, and inserting a value only
+// updates the low leaf and the appended leaf
+type IndexedMerkleTree struct {
+	IndexedMerkleTreeConfig
+	leaves     []IndexedMerkleTreeLeaf
+	levels     [][][]byte
+	sorted     []uint64
+	zeroHashes [][]byte
+}
+
+// IndexedMerkleTreeConfig is the configuration that represents the options used to build the tree
+// ValueSize is the size in bytes of the values, they are compared as big endian unsigned integers
+type IndexedMerkleTreeConfig struct {
+	Hasher    *Hasher
+	Depth     uint32
+	ValueSize uint32
+}
+
+// IndexedMerkleTreeBuilder allows use to pass the configuration before building an indexed tree
+type IndexedMerkleTreeBuilder struct {
+	config *IndexedMerkleTreeConfig
+}
+
+// IndexedMerkleTreeLeaf is a leaf of the linked list, it is hashed as H(0x00 || value || nextIndex || nextValue), the
+// next index being encoded on 8 bytes big endian
+// the prefix makes the size of a leaf preimage odd whereas the one of a parent node, i.e. two digests, is even so that
+// a parent node can never be passed off as a leaf
+type IndexedMerkleTreeLeaf struct {
+	Value     []byte
+	NextIndex uint64
+	NextValue []byte
+}
+
+// IndexedMerkleTreeProof is the inclusion proof of a leaf, the leaf holding either the value or, for a non-membership
+// proof, the low leaf of the value
+// Depth is the depth of the tree, the proof being made of a step per level
+type IndexedMerkleTreeProof struct {
+	Leaf  IndexedMerkleTreeLeaf
+	Depth uint32
+	Proof *Proof
+}
+
+const (
+	// DefaultIndexedMerkleTreeDepth is the depth of the nullifier tree of Aztec
+	DefaultIndexedMerkleTreeDepth = 20
+	// DefaultIndexedMerkleTreeValueSize is the size of a field element
+	DefaultIndexedMerkleTreeValueSize = 32
+
+	indexedLeafPrefix = 0x00
+)
+
+var (
+	ErrIndexedMerkleTreeConfigValueSizeIsZero = errors.New("the indexed merkle tree value size cannot be equal to 0")
+	ErrIndexedMerkleTreeValueIsWrong          = errors.New("the indexed merkle tree value must be of the value size and cannot be zero")
+	ErrIndexedMerkleTreeValueExists           = errors.New("the indexed merkle tree already contains the value")
+	ErrIndexedMerkleTreeValueNotFound         = errors.New("the indexed merkle tree does not contain the value")
+	ErrIndexedMerkleProofIsWrong              = errors.New("the indexed merkle proof must have a step per level of the tree")
+)
+
+func NewIndexedMerkleTreeBuilder() *IndexedMerkleTreeBuilder {
+	return &IndexedMerkleTreeBuilder{config: &IndexedMerkleTreeConfig{
+		Depth:     DefaultIndexedMerkleTreeDepth,
+		ValueSize: DefaultIndexedMerkleTreeValueSize,
+	}}
+}
+
+func (b *IndexedMerkleTreeBuilder) WithHasher(hasher *Hasher) *IndexedMerkleTreeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+func (b *IndexedMerkleTreeBuilder) WithDepth(depth uint32) *IndexedMerkleTreeBuilder {
+	b.config.Depth = depth
+	return b
+}
+
+func (b *IndexedMerkleTreeBuilder) WithValueSize(valueSize uint32) *IndexedMerkleTreeBuilder {
+	b.config.ValueSize = valueSize
+	return b
+}
+
+// Build creates a tree holding only the zero leaf, i.e. the head of the linked list
+func (b *IndexedMerkleTreeBuilder) Build() (*IndexedMerkleTree, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if b.config.Depth == 0 || b.config.Depth > MaxIncrementalMerkleTreeDepth {
+		return nil, fmt.Errorf("depth<%d>: %w", b.config.Depth, ErrIncrementalMerkleTreeConfigDepthIsWrong)
+	}
+
+	if b.config.ValueSize == 0 {
+		return nil, ErrIndexedMerkleTreeConfigValueSizeIsZero
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if err := b.config.Hasher.Hash.ValidateDigestLength(b.config.Hasher.DigestLength); err != nil {
+		return nil, fmt.Errorf("Hash.ValidateDigestLength(): %w", err)
+	}
+
+	zeroHashes, err := getZeroHashes(b.config.Hasher, b.config.Depth)
+	if err != nil {
+		return nil, fmt.Errorf("getZeroHashes(): %w", err)
+	}
+
+	t := &IndexedMerkleTree{
+		IndexedMerkleTreeConfig: *b.config,
+		levels:                  make([][][]byte, b.config.Depth+1),
+		zeroHashes:              zeroHashes,
+	}
+	zero := make([]byte, t.ValueSize)
+	if err = t.append(IndexedMerkleTreeLeaf{Value: zero, NextValue: zero}); err != nil {
+		return nil, err
+	}
+	t.sorted = []uint64{0}
+	return t, nil
+}
+
+// Insert inserts the values one after the other, each of them being appended after the low leaf has been updated to
+// point at it
+func (t *IndexedMerkleTree) Insert(values ...[]byte) error {
+	for _, v := range values {
+		if err := t.validateValue(v); err != nil {
+			return err
+		}
+		if uint64(len(t.leaves)) == t.capacity() {
+			return ErrIncrementalMerkleTreeIsFull
+		}
+
+		i, isFound := t.search(v)
+		if isFound {
+			return fmt.Errorf("value<%x>: %w", v, ErrIndexedMerkleTreeValueExists)
+		}
+		lowIndex, index := t.sorted[i-1], uint64(len(t.leaves))
+
+		// the new leaf takes over the pointer of the low leaf which now points at it
+		low := t.leaves[lowIndex]
+		leaf := IndexedMerkleTreeLeaf{Value: append([]byte{}, v...), NextIndex: low.NextIndex, NextValue: low.NextValue}
+		low.NextIndex, low.NextValue = index, leaf.Value
+		if err := t.update(lowIndex, low); err != nil {
+			return err
+		}
+		if err := t.append(leaf); err != nil {
+			return err
+		}
+
+		t.sorted = append(t.sorted, 0)
+		copy(t.sorted[i+1:], t.sorted[i:])
+		t.sorted[i] = index
+	}
+	return nil
+}
+
+// Size returns the nb of leaves of the tree, the zero leaf included
+func (t *IndexedMerkleTree) Size() uint64 {
+	return uint64(len(t.leaves))
+}
+
+// Leaf returns the leaf at the index passed in parameter
+func (t *IndexedMerkleTree) Leaf(index uint64) (IndexedMerkleTreeLeaf, error) {
+	if index >= uint64(len(t.leaves)) {
+		return IndexedMerkleTreeLeaf{}, fmt.Errorf("index<%d>: %w", index, ErrIncrementalMerkleTreeIndexOutOfRange)
+	}
+	return t.leaves[index], nil
+}
+
+// Root returns the root of the tree, the leaves that have not been appended yet being zero values
+func (t *IndexedMerkleTree) Root() []byte {
+	return t.node(t.Depth, 0)
+}
+
+// ProveMembership generates the inclusion proof of the leaf holding the value passed in parameter
+func (t *IndexedMerkleTree) ProveMembership(value []byte) (*IndexedMerkleTreeProof, error) {
+	if err := t.validateValue(value); err != nil {
+		return nil, err
+	}
+	i, isFound := t.search(value)
+	if !isFound {
+		return nil, fmt.Errorf("value<%x>: %w", value, ErrIndexedMerkleTreeValueNotFound)
+	}
+	return t.proof(t.sorted[i])
+}
+
+// ProveNonMembership generates the inclusion proof of the low leaf of the value passed in parameter
+func (t *IndexedMerkleTree) ProveNonMembership(value []byte) (*IndexedMerkleTreeProof, error) {
+	if err := t.validateValue(value); err != nil {
+		return nil, err
+	}
+	i, isFound := t.search(value)
+	if isFound {
+		return nil, fmt.Errorf("value<%x>: %w", value, ErrIndexedMerkleTreeValueExists)
+	}
+	return t.proof(t.sorted[i-1])
+}
+
+// VerifyMembership checks that the leaf holds the value and that it is included in the root
+func (p *IndexedMerkleTreeProof) VerifyMembership(root, value []byte) (bool, error) {
+	if p == nil || p.Proof == nil {
+		return false, ErrProofIsNil
+	}
+	if !bytes.Equal(p.Leaf.Value, value) {
+		return false, nil
+	}
+	return p.verify(root)
+}
+
+// VerifyNonMembership checks that the leaf is the low leaf of the value, i.e. that its value is lower and that its
+// next value is greater or that it is the end of the list, and that it is included in the root
+func (p *IndexedMerkleTreeProof) VerifyNonMembership(root, value []byte) (bool, error) {
+	if p == nil || p.Proof == nil {
+		return false, ErrProofIsNil
+	}
+	if len(p.Leaf.Value) != len(value) || len(p.Leaf.NextValue) != len(value) || bytes.Compare(p.Leaf.Value, value) >= 0 {
+		return false, nil
+	}
+	isLast := p.Leaf.NextIndex == 0 && isZeroBytes(p.Leaf.NextValue)
+	if !isLast && bytes.Compare(p.Leaf.NextValue, value) <= 0 {
+		return false, nil
+	}
+	return p.verify(root)
+}
+
+// verify checks that the proof is the one of the leaf and that it is included in the root
+// a proof of fewer steps than the depth of the tree would prove a parent node instead of a leaf
+func (p *IndexedMerkleTreeProof) verify(root []byte) (bool, error) {
+	if !p.Proof.Hash.IsValid() {
+		return false, fmt.Errorf(ErrHashNotAllowed.Error(), p.Proof.Hash)
+	}
+	if p.Depth == 0 || p.Depth > MaxIncrementalMerkleTreeDepth || len(p.Proof.Steps) != int(p.Depth) {
+		return false, fmt.Errorf("depth<%d> steps<%d>: %w", p.Depth, len(p.Proof.Steps), ErrIndexedMerkleProofIsWrong)
+	}
+	leaf, err := p.Leaf.hash(&Hasher{Hash: p.Proof.Hash})
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(leaf, p.Proof.Leaf) {
+		return false, nil
+	}
+	return p.Proof.Verify(root)
+}
+
+// proof generates the inclusion proof of the leaf at the index passed in parameter
+func (t *IndexedMerkleTree) proof(index uint64) (*IndexedMerkleTreeProof, error) {
+	leaf := t.leaves[index]
+	p := &Proof{
+		Hash:            t.Hasher.Hash,
+		IsSortPairs:     t.Hasher.IsSortPairs,
+		DigestLength:    t.Hasher.DigestLength,
+		OddNodeStrategy: PAD,
+		Arity:           DefaultArity,
+		Index:           int(index),
+		Leaf:            t.levels[0][index],
+		Steps:           make([]ProofStep, t.Depth),
+	}
+	for h := uint32(0); h < t.Depth; h++ {
+		p.Steps[h] = ProofStep{Siblings: [][]byte{t.node(h, index^1)}, Position: int(index & 1)}
+		index >>= 1
+	}
+	return &IndexedMerkleTreeProof{Leaf: leaf, Depth: t.Depth, Proof: p}, nil
+}
+
+// append appends the leaf at the end of the tree
+func (t *IndexedMerkleTree) append(leaf IndexedMerkleTreeLeaf) error {
+	t.leaves = append(t.leaves, IndexedMerkleTreeLeaf{})
+	return t.update(uint64(len(t.leaves)-1), leaf)
+}
+
+// update replaces the leaf at the index passed in parameter and hashes its branch up to the root
+func (t *IndexedMerkleTree) update(index uint64, leaf IndexedMerkleTreeLeaf) error {
+	node, err := leaf.hash(t.Hasher)
+	if err != nil {
+		return err
+	}
+	t.leaves[index] = leaf
+
+	for h := uint32(0); ; h++ {
+		if index == uint64(len(t.levels[h])) {
+			t.levels[h] = append(t.levels[h], node)
+		} else {
+			t.levels[h][index] = node
+		}
+		if h == t.Depth {
+			return nil
+		}
+
+		left, right := t.node(h, index&^1), t.node(h, index|1)
+		if node, err = t.Hasher.hashChildren(left, right); err != nil {
+			return fmt.Errorf("t.Hasher.hashChildren(): %w", err)
+		}
+		index >>= 1
+	}
+}
+
+// node returns the node at the height and the index passed in parameter, the zero subtree if it has not been filled
+func (t *IndexedMerkleTree) node(h uint32, index uint64) []byte {
+	if index < uint64(len(t.levels[h])) {
+		return t.levels[h][index]
+	}
+	return t.zeroHashes[h]
+}
+
+// search returns the position in the sorted leaves of the first value greater or equal to the value passed in
+// parameter and whether it is equal
+func (t *IndexedMerkleTree) search(value []byte) (int, bool) {
+	i := sort.Search(len(t.sorted), func(i int) bool {
+		return bytes.Compare(t.leaves[t.sorted[i]].Value, value) >= 0
+	})
+	return i, i < len(t.sorted) && bytes.Equal(t.leaves[t.sorted[i]].Value, value)
+}
+
+// validateValue checks that the value is of the value size and is not the zero value of the head of the list
+func (t *IndexedMerkleTree) validateValue(value []byte) error {
+	if len(value) != int(t.ValueSize) || isZeroBytes(value) {
+		return fmt.Errorf("value<%x>: %w", value, ErrIndexedMerkleTreeValueIsWrong)
+	}
+	return nil
+}
+
+// capacity returns the max nb of leaves of the tree
+func (t *IndexedMerkleTree) capacity() uint64 {
+	if t.Depth == MaxIncrementalMerkleTreeDepth {
+		return ^uint64(0)
+	}
+	return 1 << t.Depth
+}
+
+// hash returns H(0x00 || value || nextIndex || nextValue)
+func (l IndexedMerkleTreeLeaf) hash(h *Hasher) ([]byte, error) {
+	b := append(append([]byte{indexedLeafPrefix}, l.Value...), make([]byte, 8)...)
+	binary.BigEndian.PutUint64(b[1+len(l.Value):], l.NextIndex)
+	leaf, err := h.hashData(append(b, l.NextValue...))
+	if err != nil {
+		return nil, fmt.Errorf("h.hashData(): %w", err)
+	}
+	return leaf, nil
+}
+
+// isZeroBytes returns whether all the bytes are equal to zero
+func isZeroBytes(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
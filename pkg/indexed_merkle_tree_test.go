@@ -0,0 +1,212 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIndexedMerkleTreeBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name      string
+		hasher    *Hasher
+		depth     uint32
+		valueSize uint32
+		err       error
+	}{
+		{
+			name:      "build indexed merkle tree without hasher should return error",
+			depth:     DefaultIndexedMerkleTreeDepth,
+			valueSize: DefaultIndexedMerkleTreeValueSize,
+			err:       ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:      "build indexed merkle tree with a depth of 0 should return error",
+			hasher:    configWithHashPool.Hasher,
+			depth:     0,
+			valueSize: DefaultIndexedMerkleTreeValueSize,
+			err:       ErrIncrementalMerkleTreeConfigDepthIsWrong,
+		},
+		{
+			name:      "build indexed merkle tree with a value size of 0 should return error",
+			hasher:    configWithHashPool.Hasher,
+			depth:     DefaultIndexedMerkleTreeDepth,
+			valueSize: 0,
+			err:       ErrIndexedMerkleTreeConfigValueSizeIsZero,
+		},
+		{
+			name:      "build indexed merkle tree should return a tree holding the zero leaf",
+			hasher:    configWithHashPool.Hasher,
+			depth:     DefaultIndexedMerkleTreeDepth,
+			valueSize: DefaultIndexedMerkleTreeValueSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewIndexedMerkleTreeBuilder().WithHasher(tt.hasher).WithDepth(tt.depth).WithValueSize(tt.valueSize).Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, uint64(1), tree.Size())
+			leaf, err := tree.Leaf(0)
+			assert.NoError(t, err)
+			assert.Equal(t, IndexedMerkleTreeLeaf{Value: make([]byte, tt.valueSize), NextValue: make([]byte, tt.valueSize)}, leaf)
+		})
+	}
+
+	// an unknown hash algorithm is rejected instead of panicking once the hashes are computed
+	_, err := NewIndexedMerkleTreeBuilder().WithHasher(&Hasher{Hash: "md5"}).Build()
+	assert.EqualError(t, err, fmt.Sprintf(ErrHashNotAllowed.Error(), "md5"))
+}
+
+func TestIndexedMerkleTree_Insert(t *testing.T) {
+	tree, err := NewIndexedMerkleTreeBuilder().WithHasher(configWithHashPool.Hasher).WithDepth(4).WithValueSize(1).Build()
+	assert.NoError(t, err)
+
+	// the example of the Aztec documentation: the list is kept sorted whatever the insertion order
+	assert.NoError(t, tree.Insert([]byte{30}, []byte{10}, []byte{20}, []byte{50}))
+	expected := []IndexedMerkleTreeLeaf{
+		{Value: []byte{0}, NextIndex: 2, NextValue: []byte{10}},
+		{Value: []byte{30}, NextIndex: 4, NextValue: []byte{50}},
+		{Value: []byte{10}, NextIndex: 3, NextValue: []byte{20}},
+		{Value: []byte{20}, NextIndex: 1, NextValue: []byte{30}},
+		{Value: []byte{50}, NextIndex: 0, NextValue: []byte{0}},
+	}
+	assert.Equal(t, uint64(len(expected)), tree.Size())
+
+	// the root is the one of an incremental tree of the leaf hashes
+	incremental, err := NewIncrementalMerkleTreeBuilder().WithHasher(configWithHashPool.Hasher).WithDepth(4).Build()
+	assert.NoError(t, err)
+	for i, l := range expected {
+		leaf, err := tree.Leaf(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, l, leaf)
+
+		hash, err := l.hash(configWithHashPool.Hasher)
+		assert.NoError(t, err)
+		assert.NoError(t, incremental.Append(HashedData{Value: hash}))
+	}
+	root, err := incremental.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, root, tree.Root())
+
+	assert.ErrorIs(t, tree.Insert([]byte{20}), ErrIndexedMerkleTreeValueExists)
+	assert.ErrorIs(t, tree.Insert([]byte{0}), ErrIndexedMerkleTreeValueIsWrong)
+	assert.ErrorIs(t, tree.Insert([]byte{1, 2}), ErrIndexedMerkleTreeValueIsWrong)
+
+	full, err := NewIndexedMerkleTreeBuilder().WithHasher(configWithHashPool.Hasher).WithDepth(1).WithValueSize(1).Build()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, full.Insert([]byte{1}, []byte{2}), ErrIncrementalMerkleTreeIsFull)
+}
+
+func TestIndexedMerkleTree_Prove(t *testing.T) {
+	tree, err := NewIndexedMerkleTreeBuilder().WithHasher(configWithHashPool.Hasher).WithDepth(8).WithValueSize(1).Build()
+	assert.NoError(t, err)
+	assert.NoError(t, tree.Insert([]byte{30}, []byte{10}, []byte{20}, []byte{50}))
+	root := tree.Root()
+
+	for _, v := range []byte{10, 20, 30, 50} {
+		p, err := tree.ProveMembership([]byte{v})
+		assert.NoError(t, err)
+		isVerified, err := p.VerifyMembership(root, []byte{v})
+		assert.NoError(t, err)
+		assert.True(t, isVerified, "value<%d>", v)
+
+		isVerified, err = p.VerifyNonMembership(root, []byte{v})
+		assert.NoError(t, err)
+		assert.False(t, isVerified, "value<%d>", v)
+
+		_, err = tree.ProveNonMembership([]byte{v})
+		assert.ErrorIs(t, err, ErrIndexedMerkleTreeValueExists)
+	}
+
+	tests := []struct {
+		value    byte
+		lowValue byte
+	}{
+		{value: 5, lowValue: 0},
+		{value: 15, lowValue: 10},
+		{value: 49, lowValue: 30},
+		{value: 51, lowValue: 50},
+		{value: 255, lowValue: 50},
+	}
+	for _, tt := range tests {
+		p, err := tree.ProveNonMembership([]byte{tt.value})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{tt.lowValue}, p.Leaf.Value)
+		isVerified, err := p.VerifyNonMembership(root, []byte{tt.value})
+		assert.NoError(t, err)
+		assert.True(t, isVerified, "value<%d>", tt.value)
+
+		// the low leaf of another value does not prove the non membership
+		isVerified, err = p.VerifyNonMembership(root, []byte{tt.lowValue})
+		assert.NoError(t, err)
+		assert.False(t, isVerified, "value<%d>", tt.value)
+
+		// a low leaf whose pointer is altered is not included in the root
+		p.Leaf.NextIndex, p.Leaf.NextValue = 0, []byte{0}
+		isVerified, err = p.VerifyNonMembership(root, []byte{tt.value})
+		assert.NoError(t, err)
+		assert.Equal(t, tt.lowValue == 50, isVerified, "value<%d>", tt.value)
+
+		_, err = tree.ProveMembership([]byte{tt.value})
+		assert.ErrorIs(t, err, ErrIndexedMerkleTreeValueNotFound)
+	}
+
+	// a proof against the root before an insertion does not verify against the root after it
+	p, err := tree.ProveNonMembership([]byte{40})
+	assert.NoError(t, err)
+	assert.NoError(t, tree.Insert([]byte{40}))
+	isVerified, err := p.VerifyNonMembership(tree.Root(), []byte{40})
+	assert.NoError(t, err)
+	assert.False(t, isVerified)
+
+	var nilProof *IndexedMerkleTreeProof
+	_, err = nilProof.VerifyMembership(root, []byte{10})
+	assert.ErrorIs(t, err, ErrProofIsNil)
+}
+
+func TestIndexedMerkleTreeProof_ParentNodeAsLeaf(t *testing.T) {
+	// a leaf of 28 bytes values is as long as two sha256 digests without its prefix
+	tree, err := NewIndexedMerkleTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).WithDepth(4).WithValueSize(28).Build()
+	assert.NoError(t, err)
+	values := make([][]byte, 15)
+	for i := range values {
+		h := sha256.Sum256([]byte{byte(i)})
+		values[i] = h[:28]
+	}
+	assert.NoError(t, tree.Insert(values...))
+
+	// each parent node of the first level is passed off as a leaf made of both its children to prove the
+	// non-membership of the values of the tree
+	for j := uint64(0); j < 8; j++ {
+		children := append(append([]byte{}, tree.node(0, 2*j)...), tree.node(0, 2*j+1)...)
+		forged := &IndexedMerkleTreeProof{
+			Leaf:  IndexedMerkleTreeLeaf{Value: children[:28], NextIndex: binary.BigEndian.Uint64(children[28:36]), NextValue: children[36:]},
+			Depth: 3,
+			Proof: &Proof{Hash: SHA256, OddNodeStrategy: PAD, Arity: DefaultArity, Index: int(j), Leaf: tree.node(1, j)},
+		}
+		for h, index := uint32(1), j; h < tree.Depth; h, index = h+1, index>>1 {
+			forged.Proof.Steps = append(forged.Proof.Steps, ProofStep{Siblings: [][]byte{tree.node(h, index^1)}, Position: int(index & 1)})
+		}
+
+		// the proof of the parent node is not a leaf one once its children are hashed with the leaf prefix
+		for _, v := range values {
+			isVerified, err := forged.VerifyNonMembership(tree.Root(), v)
+			assert.NoError(t, err)
+			assert.False(t, isVerified, "node<%d> value<%x>", j, v)
+		}
+
+		// a proof shorter than the depth of the tree is rejected
+		forged.Depth = tree.Depth
+		_, err = forged.verify(tree.Root())
+		assert.ErrorIs(t, err, ErrIndexedMerkleProofIsWrong)
+	}
+}
@@ -0,0 +1,355 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	"math/bits"
+)
+
+// MerkleMountainRange is an append-only accumulator made of a list of perfect binary trees, the mountains
+// its nodes are stored in post-order so that appending a leaf only adds the leaf and the parents it completes at the
+// end of the nodes, the nodes of a previous size being the prefix of the current ones
+// the root is computed by bagging the peaks of the mountains from the right to the left
+type MerkleMountainRange struct {
+	MerkleMountainRangeConfig
+	nodes    [][]byte
+	nbLeaves uint64
+}
+
+// MerkleMountainRangeConfig is the configuration that represents the options used to build the range
+type MerkleMountainRangeConfig struct {
+	Hasher       *Hasher
+	MaxGoroutine uint32
+}
+
+// MerkleMountainRangeBuilder allows use to pass the configuration before building a range
+type MerkleMountainRangeBuilder struct {
+	config *MerkleMountainRangeConfig
+}
+
+// MerkleMountainRangeProof is the inclusion proof of a leaf against the root of a range of NbLeaves leaves
+// Siblings contains the hashes needed to compute the peak of the leaf mountain, ordered from the leaf up to the peak,
+// and Peaks the hashes of the other peaks, ordered from the left to the right
+type MerkleMountainRangeProof struct {
+	Hash         Hash
+	IsSortPairs  bool
+	DigestLength uint32
+	Index        uint64
+	NbLeaves     uint64
+	Leaf         []byte
+	Siblings     [][]byte
+	Peaks        [][]byte
+}
+
+var (
+	ErrMerkleMountainRangeIsEmpty         = errors.New("the merkle mountain range does not contain any leaf")
+	ErrMerkleMountainRangeIndexOutOfRange = errors.New("the merkle mountain range does not contain the index")
+	ErrMerkleMountainRangeSizeIsWrong     = errors.New("the merkle mountain range has never had this nb of leaves")
+	ErrMerkleMountainRangeProofIsWrong    = errors.New("the merkle mountain range proof is not consistent with its index and its nb of leaves")
+)
+
+func NewMerkleMountainRangeBuilder() *MerkleMountainRangeBuilder {
+	return &MerkleMountainRangeBuilder{config: &MerkleMountainRangeConfig{}}
+}
+
+func (b *MerkleMountainRangeBuilder) WithHasher(hasher *Hasher) *MerkleMountainRangeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+// WithMaxGoroutine limits the nb of leaves hashed concurrently by Append
+func (b *MerkleMountainRangeBuilder) WithMaxGoroutine(maxGoroutine uint32) *MerkleMountainRangeBuilder {
+	b.config.MaxGoroutine = maxGoroutine
+	return b
+}
+
+// Build creates an empty range
+func (b *MerkleMountainRangeBuilder) Build() (*MerkleMountainRange, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if b.config.MaxGoroutine == 0 {
+		return nil, ErrMerkleTreeConfigMaxGoroutineIsEqZero
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if err := b.config.Hasher.Hash.ValidateDigestLength(b.config.Hasher.DigestLength); err != nil {
+		return nil, fmt.Errorf("Hash.ValidateDigestLength(): %w", err)
+	}
+
+	return &MerkleMountainRange{MerkleMountainRangeConfig: *b.config}, nil
+}
+
+// Append appends the data as leaves of the range
+// the leaves are hashed concurrently, then each of them is added with the parents it completes
+func (m *MerkleMountainRange) Append(ctx context.Context, data ...Data) error {
+	if len(data) == 0 {
+		return ErrMerkleTreeDataIsNilOrEmpty
+	}
+
+	leaves := make([][]byte, len(data))
+	errs, _ := errgroup.WithContext(ctx)
+	errs.SetLimit(int(m.MaxGoroutine))
+	for _i := 0; _i < len(data); _i++ {
+		// i can change in the below go routine, allocates a local scope via i
+		i := _i
+
+		errs.Go(func() error {
+			leaf, err := data[i].Hash(m.Hasher)
+			if err != nil {
+				return fmt.Errorf("data[%d].Hash(): %w", i, err)
+			}
+			leaves[i] = leaf
+			return nil
+		})
+	}
+
+	// wait for all the go routines to be done
+	if err := errs.Wait(); err != nil {
+		return err
+	}
+
+	for _, leaf := range leaves {
+		// the leaf completes as many mountains as the trailing ones of the nb of leaves
+		node := leaf
+		m.nodes = append(m.nodes, node)
+		for h := 0; h < bits.TrailingZeros64(^m.nbLeaves); h++ {
+			left := m.nodes[uint64(len(m.nodes))-1-mountainSize(h)]
+			parent, err := m.Hasher.hashChildren(left, node)
+			if err != nil {
+				return fmt.Errorf("m.Hasher.hashChildren(): %w", err)
+			}
+			m.nodes = append(m.nodes, parent)
+			node = parent
+		}
+		m.nbLeaves++
+	}
+	return nil
+}
+
+// NbLeaves returns the nb of leaves appended to the range
+func (m *MerkleMountainRange) NbLeaves() uint64 {
+	return m.nbLeaves
+}
+
+// Size returns the nb of nodes of the range
+func (m *MerkleMountainRange) Size() uint64 {
+	return uint64(len(m.nodes))
+}
+
+// Node returns the hash of the node at the position passed in parameter
+func (m *MerkleMountainRange) Node(pos uint64) ([]byte, error) {
+	if pos >= m.Size() {
+		return nil, fmt.Errorf("pos<%d>: %w", pos, ErrMerkleMountainRangeIndexOutOfRange)
+	}
+	return m.nodes[pos], nil
+}
+
+// Peaks returns the hashes of the peaks of the range when it had the nb of leaves passed in parameter
+func (m *MerkleMountainRange) Peaks(nbLeaves uint64) ([][]byte, error) {
+	if nbLeaves > m.nbLeaves {
+		return nil, fmt.Errorf("nbLeaves<%d>: %w", nbLeaves, ErrMerkleMountainRangeSizeIsWrong)
+	}
+
+	positions := MMRPeaks(nbLeaves)
+	peaks := make([][]byte, len(positions))
+	for i, pos := range positions {
+		peaks[i] = m.nodes[pos]
+	}
+	return peaks, nil
+}
+
+// Root returns the root of the range, i.e. its peaks bagged from the right to the left
+func (m *MerkleMountainRange) Root() ([]byte, error) {
+	return m.RootAt(m.nbLeaves)
+}
+
+// RootAt returns the root the range had when it had the nb of leaves passed in parameter
+func (m *MerkleMountainRange) RootAt(nbLeaves uint64) ([]byte, error) {
+	if nbLeaves == 0 {
+		return nil, ErrMerkleMountainRangeIsEmpty
+	}
+
+	peaks, err := m.Peaks(nbLeaves)
+	if err != nil {
+		return nil, err
+	}
+	return bagPeaks(m.Hasher, peaks)
+}
+
+// Proof generates the inclusion proof of the leaf at the index passed in parameter against the root the range had
+// when it had the nb of leaves passed in parameter, the current root being the one of NbLeaves leaves
+func (m *MerkleMountainRange) Proof(index, nbLeaves uint64) (*MerkleMountainRangeProof, error) {
+	if nbLeaves > m.nbLeaves {
+		return nil, fmt.Errorf("nbLeaves<%d>: %w", nbLeaves, ErrMerkleMountainRangeSizeIsWrong)
+	}
+	if index >= nbLeaves {
+		return nil, fmt.Errorf("index<%d>: %w", index, ErrMerkleMountainRangeIndexOutOfRange)
+	}
+
+	pos := MMRLeafPosition(index)
+	siblings, peak := mmrPath(pos, MMRSize(nbLeaves))
+
+	p := &MerkleMountainRangeProof{
+		Hash:         m.Hasher.Hash,
+		IsSortPairs:  m.Hasher.IsSortPairs,
+		DigestLength: m.Hasher.DigestLength,
+		Index:        index,
+		NbLeaves:     nbLeaves,
+		Leaf:         m.nodes[pos],
+		Siblings:     make([][]byte, len(siblings)),
+	}
+	for i, sibling := range siblings {
+		p.Siblings[i] = m.nodes[sibling]
+	}
+	for _, pos := range MMRPeaks(nbLeaves) {
+		if pos != peak {
+			p.Peaks = append(p.Peaks, m.nodes[pos])
+		}
+	}
+	return p, nil
+}
+
+// Verify recomputes the peak of the leaf mountain, bags it with the other peaks and checks the result against the
+// root passed in parameter
+func (p *MerkleMountainRangeProof) Verify(root []byte) (bool, error) {
+	if p == nil {
+		return false, ErrProofIsNil
+	}
+	if !p.Hash.IsValid() {
+		return false, fmt.Errorf(ErrHashNotAllowed.Error(), p.Hash)
+	}
+	if err := p.Hash.ValidateDigestLength(p.DigestLength); err != nil {
+		return false, fmt.Errorf("p.Hash.ValidateDigestLength(): %w", err)
+	}
+	if p.Index >= p.NbLeaves {
+		return false, fmt.Errorf("index<%d>: %w", p.Index, ErrMerkleMountainRangeIndexOutOfRange)
+	}
+
+	var (
+		h              = &Hasher{Hash: p.Hash, IsSortPairs: p.IsSortPairs, DigestLength: p.DigestLength}
+		pos            = MMRLeafPosition(p.Index)
+		siblings, peak = mmrPath(pos, MMRSize(p.NbLeaves))
+		peaks          = MMRPeaks(p.NbLeaves)
+	)
+	if len(siblings) != len(p.Siblings) || len(peaks) != len(p.Peaks)+1 {
+		return false, ErrMerkleMountainRangeProofIsWrong
+	}
+
+	var (
+		node = p.Leaf
+		err  error
+	)
+	for i, sibling := range siblings {
+		if sibling < pos {
+			node, err = h.hashChildren(p.Siblings[i], node)
+			pos++
+		} else {
+			node, err = h.hashChildren(node, p.Siblings[i])
+			pos = sibling + 1
+		}
+		if err != nil {
+			return false, fmt.Errorf("h.hashChildren(): %w", err)
+		}
+	}
+
+	// the peak of the leaf takes its place among the other peaks
+	hashes := make([][]byte, 0, len(peaks))
+	for i, j := 0, 0; i < len(peaks); i++ {
+		if peaks[i] == peak {
+			hashes = append(hashes, node)
+			continue
+		}
+		hashes = append(hashes, p.Peaks[j])
+		j++
+	}
+
+	bagged, err := bagPeaks(h, hashes)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(bagged, root), nil
+}
+
+// MMRSize returns the nb of nodes of a range of the nb of leaves passed in parameter
+func MMRSize(nbLeaves uint64) uint64 {
+	return 2*nbLeaves - uint64(bits.OnesCount64(nbLeaves))
+}
+
+// MMRLeafPosition returns the position of the leaf at the index passed in parameter, i.e. the nb of nodes before it
+func MMRLeafPosition(index uint64) uint64 {
+	return MMRSize(index)
+}
+
+// MMRHeight returns the height of the node at the position passed in parameter, leaves being of height 0
+// the position is moved to the left mountain of the same height until the position+1 is made of ones only, i.e. the
+// position of a peak of the first mountain
+func MMRHeight(pos uint64) uint32 {
+	p := pos + 1
+	for p&(p+1) != 0 {
+		p -= 1<<(bits.Len64(p)-1) - 1
+	}
+	return uint32(bits.Len64(p) - 1)
+}
+
+// MMRPeaks returns the positions of the peaks of a range of the nb of leaves passed in parameter, from the left to the
+// right, each bit set in the nb of leaves being a mountain
+func MMRPeaks(nbLeaves uint64) []uint64 {
+	var (
+		peaks  []uint64
+		offset uint64
+	)
+	for h := bits.Len64(nbLeaves) - 1; h >= 0; h-- {
+		if nbLeaves&(1<<h) != 0 {
+			offset += mountainSize(h)
+			peaks = append(peaks, offset-1)
+		}
+	}
+	return peaks
+}
+
+// mountainSize returns the nb of nodes of a perfect tree of the height passed in parameter
+func mountainSize(height int) uint64 {
+	return 1<<(height+1) - 1
+}
+
+// mmrPath returns the positions of the siblings of the node from the position passed in parameter up to its peak and
+// the position of the peak within a range of the size passed in parameter
+func mmrPath(pos, size uint64) ([]uint64, uint64) {
+	var siblings []uint64
+	for h := int(MMRHeight(pos)); ; h++ {
+		// a node followed by a higher node is a right child, its parent being right after it
+		sibling, parent := pos+mountainSize(h), pos+mountainSize(h)+1
+		if int(MMRHeight(pos+1)) > h {
+			sibling, parent = pos-mountainSize(h), pos+1
+		}
+		if parent >= size {
+			return siblings, pos
+		}
+		siblings = append(siblings, sibling)
+		pos = parent
+	}
+}
+
+// bagPeaks folds the peaks from the right to the left into a single root
+func bagPeaks(h *Hasher, peaks [][]byte) ([]byte, error) {
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		var err error
+		if root, err = h.hashChildren(peaks[i], root); err != nil {
+			return nil, fmt.Errorf("h.hashChildren(): %w", err)
+		}
+	}
+	return root, nil
+}
@@ -0,0 +1,195 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMerkleMountainRangeBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name         string
+		hasher       *Hasher
+		maxGoroutine uint32
+		err          error
+	}{
+		{
+			name:         "build a range without hasher should return error",
+			hasher:       nil,
+			maxGoroutine: 1,
+			err:          ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:         "build a range without goroutine should return error",
+			hasher:       &Hasher{Hash: SHA256},
+			maxGoroutine: 0,
+			err:          ErrMerkleTreeConfigMaxGoroutineIsEqZero,
+		},
+		{
+			name:         "build a range with a too short digest length should return error",
+			hasher:       &Hasher{Hash: SHA256, DigestLength: 8},
+			maxGoroutine: 1,
+			err:          ErrHashDigestLengthTooShort,
+		},
+		{
+			name:         "build a range should return an empty range",
+			hasher:       &Hasher{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+			maxGoroutine: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMerkleMountainRangeBuilder().WithHasher(tt.hasher).WithMaxGoroutine(tt.maxGoroutine).Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, uint64(0), m.NbLeaves())
+			_, err = m.Root()
+			assert.ErrorIs(t, err, ErrMerkleMountainRangeIsEmpty)
+		})
+	}
+
+	// an unknown hash algorithm is rejected instead of panicking once the hashes are computed
+	_, err := NewMerkleMountainRangeBuilder().WithHasher(&Hasher{Hash: "md5"}).WithMaxGoroutine(1).Build()
+	assert.EqualError(t, err, fmt.Sprintf(ErrHashNotAllowed.Error(), "md5"))
+}
+
+func TestMMRHelpers(t *testing.T) {
+	// positions 0 to 14 of a range of 8 leaves
+	heights := []uint32{0, 0, 1, 0, 0, 1, 2, 0, 0, 1, 0, 0, 1, 2, 3}
+	for pos, height := range heights {
+		assert.Equal(t, height, MMRHeight(uint64(pos)), "pos<%d>", pos)
+	}
+
+	tests := []struct {
+		nbLeaves uint64
+		size     uint64
+		peaks    []uint64
+	}{
+		{nbLeaves: 0, size: 0, peaks: nil},
+		{nbLeaves: 1, size: 1, peaks: []uint64{0}},
+		{nbLeaves: 2, size: 3, peaks: []uint64{2}},
+		{nbLeaves: 3, size: 4, peaks: []uint64{2, 3}},
+		{nbLeaves: 4, size: 7, peaks: []uint64{6}},
+		{nbLeaves: 7, size: 11, peaks: []uint64{6, 9, 10}},
+		{nbLeaves: 11, size: 19, peaks: []uint64{14, 17, 18}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d leaves", tt.nbLeaves), func(t *testing.T) {
+			assert.Equal(t, tt.size, MMRSize(tt.nbLeaves))
+			assert.Equal(t, tt.peaks, MMRPeaks(tt.nbLeaves))
+		})
+	}
+
+	assert.Equal(t, []uint64{0, 1, 3, 4, 7, 8, 10, 11}, []uint64{
+		MMRLeafPosition(0), MMRLeafPosition(1), MMRLeafPosition(2), MMRLeafPosition(3),
+		MMRLeafPosition(4), MMRLeafPosition(5), MMRLeafPosition(6), MMRLeafPosition(7),
+	})
+}
+
+func TestMerkleMountainRange_Append(t *testing.T) {
+	h := &Hasher{Hash: SHA256}
+	m, err := NewMerkleMountainRangeBuilder().WithHasher(h).WithMaxGoroutine(4).Build()
+	assert.NoError(t, err)
+
+	data := []Data{StringData{Value: "a"}, StringData{Value: "b"}, StringData{Value: "c"}}
+	assert.NoError(t, m.Append(context.Background(), data...))
+	assert.ErrorIs(t, m.Append(context.Background()), ErrMerkleTreeDataIsNilOrEmpty)
+
+	// the range of 3 leaves is made of a mountain of 2 leaves and a mountain of 1 leaf
+	leaves := make([][]byte, len(data))
+	for i, d := range data {
+		leaves[i], err = d.Hash(h)
+		assert.NoError(t, err)
+	}
+	left, err := h.hashChildren(leaves[0], leaves[1])
+	assert.NoError(t, err)
+	expected, err := h.hashChildren(left, leaves[2])
+	assert.NoError(t, err)
+
+	root, err := m.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, root)
+	assert.Equal(t, uint64(4), m.Size())
+
+	// appending the leaves one by one should return the same nodes as appending them in bulk
+	single, err := NewMerkleMountainRangeBuilder().WithHasher(h).WithMaxGoroutine(1).Build()
+	assert.NoError(t, err)
+	for _, d := range data {
+		assert.NoError(t, single.Append(context.Background(), d))
+	}
+	assert.Equal(t, m.nodes, single.nodes)
+}
+
+func TestMerkleMountainRange_Proof(t *testing.T) {
+	for _, h := range []*Hasher{
+		{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+		{Hash: SHA512, DigestLength: 32, IsSortPairs: true},
+	} {
+		t.Run(string(h.Hash), func(t *testing.T) {
+			m, err := NewMerkleMountainRangeBuilder().WithHasher(h).WithMaxGoroutine(8).Build()
+			assert.NoError(t, err)
+
+			roots := make([][]byte, 0, 20)
+			for i := 0; i < 20; i++ {
+				assert.NoError(t, m.Append(context.Background(), StringData{Value: fmt.Sprintf("leaf%d", i)}))
+				root, err := m.Root()
+				assert.NoError(t, err)
+				roots = append(roots, root)
+			}
+
+			// every leaf should be proven against every historical root that contains it
+			for nbLeaves := uint64(1); nbLeaves <= 20; nbLeaves++ {
+				root, err := m.RootAt(nbLeaves)
+				assert.NoError(t, err)
+				assert.Equal(t, roots[nbLeaves-1], root)
+
+				for index := uint64(0); index < nbLeaves; index++ {
+					p, err := m.Proof(index, nbLeaves)
+					assert.NoError(t, err)
+
+					isVerified, err := p.Verify(root)
+					assert.NoError(t, err)
+					assert.True(t, isVerified, "index<%d> nbLeaves<%d>", index, nbLeaves)
+
+					isVerified, err = p.Verify(roots[(nbLeaves)%20])
+					assert.NoError(t, err)
+					assert.False(t, isVerified, "index<%d> nbLeaves<%d>", index, nbLeaves)
+				}
+			}
+		})
+	}
+}
+
+func TestMerkleMountainRange_ProofErrors(t *testing.T) {
+	m, err := NewMerkleMountainRangeBuilder().WithHasher(&Hasher{Hash: SHA256}).WithMaxGoroutine(1).Build()
+	assert.NoError(t, err)
+	assert.NoError(t, m.Append(context.Background(), StringData{Value: "a"}, StringData{Value: "b"}, StringData{Value: "c"}))
+
+	_, err = m.Proof(3, 3)
+	assert.ErrorIs(t, err, ErrMerkleMountainRangeIndexOutOfRange)
+	_, err = m.Proof(0, 4)
+	assert.ErrorIs(t, err, ErrMerkleMountainRangeSizeIsWrong)
+	_, err = m.RootAt(4)
+	assert.ErrorIs(t, err, ErrMerkleMountainRangeSizeIsWrong)
+
+	p, err := m.Proof(0, 3)
+	assert.NoError(t, err)
+	root, err := m.Root()
+	assert.NoError(t, err)
+
+	// a proof whose nb of leaves has been changed should not match its siblings
+	p.NbLeaves = 4
+	_, err = p.Verify(root)
+	assert.ErrorIs(t, err, ErrMerkleMountainRangeProofIsWrong)
+
+	p = nil
+	_, err = p.Verify(root)
+	assert.ErrorIs(t, err, ErrProofIsNil)
+}
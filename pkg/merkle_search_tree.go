@@ -0,0 +1,523 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// MerkleSearchTree is a search tree of sorted keys whose shape only depends on its keys, two trees with the same keys
+// and values having the same root whatever the order the keys have been put in
+// the layer of a key is the nb of leading zero bits of the hash of the key divided by 2, i.e. a fanout of 4, a node of
+// a layer holding the keys of its layer and, between them, the nodes of the layer below holding the keys in between
+// the nodes are serialized with DAG-CBOR as AT Protocol repositories do, the root being the cid of the top node
+// nodes are never modified once created, an update replacing the nodes of the path of the key and computing their cid
+// before they are part of the tree so that the tree can be read concurrently
+type MerkleSearchTree struct {
+	MerkleSearchTreeConfig
+	root *mstNode
+	size int
+}
+
+// MerkleSearchTreeConfig is the configuration that represents the options used to build the tree
+// the hasher is used both for the layers of the keys and the cids of the nodes, AT Protocol using SHA-256
+type MerkleSearchTreeConfig struct {
+	Hasher *Hasher
+}
+
+// MerkleSearchTreeBuilder allows use to pass the configuration before building a search tree
+type MerkleSearchTreeBuilder struct {
+	config *MerkleSearchTreeConfig
+}
+
+// MerkleSearchTreeDiff is a key whose value differs between two trees, Old being nil for a created key and New being
+// nil for a deleted key
+type MerkleSearchTreeDiff struct {
+	Key string
+	Old CID
+	New CID
+}
+
+// mstNode is a node of a layer, the subtree on the left of its first entry being Left and the subtree on the right of
+// an entry being its right
+// cid is set by seal once the node has been built, it is never written afterwards
+type mstNode struct {
+	layer   int
+	left    *mstNode
+	entries []mstEntry
+	cid     CID
+}
+
+type mstEntry struct {
+	key   string
+	value CID
+	right *mstNode
+}
+
+// MerkleSearchTreeFanoutBits is the nb of leading zero bits of a key hash per layer
+const MerkleSearchTreeFanoutBits = 2
+
+var (
+	ErrMerkleSearchTreeConfigHasherIsWrong = errors.New("the merkle search tree hasher cannot truncate digests nor sort leaves or pairs")
+	ErrMerkleSearchTreeKeyIsEmpty          = errors.New("the merkle search tree key cannot be empty")
+	ErrMerkleSearchTreeKeyNotFound         = errors.New("the merkle search tree does not contain the key")
+	ErrMerkleSearchTreeHashIsDifferent     = errors.New("the merkle search trees are not built with the same hash algorithm")
+)
+
+func NewMerkleSearchTreeBuilder() *MerkleSearchTreeBuilder {
+	return &MerkleSearchTreeBuilder{config: &MerkleSearchTreeConfig{Hasher: &Hasher{Hash: SHA256}}}
+}
+
+// WithHasher sets the hash algorithm of the tree, SHA-256 being the one of AT Protocol
+func (b *MerkleSearchTreeBuilder) WithHasher(hasher *Hasher) *MerkleSearchTreeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+// Build creates an empty tree
+func (b *MerkleSearchTreeBuilder) Build() (*MerkleSearchTree, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if b.config.Hasher.DigestLength != 0 || b.config.Hasher.IsSortLeaves || b.config.Hasher.IsSortPairs {
+		return nil, ErrMerkleSearchTreeConfigHasherIsWrong
+	}
+
+	return &MerkleSearchTree{MerkleSearchTreeConfig: *b.config}, nil
+}
+
+// Size returns the nb of keys of the tree
+func (t *MerkleSearchTree) Size() int {
+	return t.size
+}
+
+// Root returns the cid of the top node, the root of an empty tree being the cid of a node without any entry
+func (t *MerkleSearchTree) Root() (CID, error) {
+	if t.root == nil {
+		return (&mstNode{}).encode(t.Hasher)
+	}
+	return t.root.cid, nil
+}
+
+// Layer returns the layer of the key passed in parameter
+func (t *MerkleSearchTree) Layer(key string) (int, error) {
+	h, err := t.Hasher.hashData([]byte(key))
+	if err != nil {
+		return 0, fmt.Errorf("t.Hasher.hashData(key): %w", err)
+	}
+
+	var zeros int
+	for _, b := range h {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros / MerkleSearchTreeFanoutBits, nil
+}
+
+// Get returns the value of the key passed in parameter
+func (t *MerkleSearchTree) Get(key string) (CID, error) {
+	for n := t.root; n != nil; {
+		i := n.search(key)
+		if i < len(n.entries) && n.entries[i].key == key {
+			return n.entries[i].value, nil
+		}
+		n = n.child(i)
+	}
+	return nil, fmt.Errorf("key<%s>: %w", key, ErrMerkleSearchTreeKeyNotFound)
+}
+
+// Put sets the value of the key passed in parameter
+// if the layer of the key is above the top node, the top node is wrapped by empty nodes up to the layer of the key
+func (t *MerkleSearchTree) Put(key string, value CID) error {
+	if key == "" {
+		return ErrMerkleSearchTreeKeyIsEmpty
+	}
+	if err := value.validate(); err != nil {
+		return fmt.Errorf("value<%x>: %w", []byte(value), err)
+	}
+
+	layer, err := t.Layer(key)
+	if err != nil {
+		return err
+	}
+
+	root, rootLayer := t.root, 0
+	if root != nil {
+		rootLayer = root.layer
+	}
+	for ; rootLayer < layer; rootLayer++ {
+		if root != nil {
+			root = &mstNode{layer: rootLayer + 1, left: root}
+		}
+	}
+
+	root, isNew := mstInsert(root, rootLayer, key, value, layer)
+	if err = root.seal(t.Hasher); err != nil {
+		return err
+	}
+	t.root = root
+	if isNew {
+		t.size++
+	}
+	return nil
+}
+
+// Delete removes the key passed in parameter, the top nodes left without any entry being trimmed
+func (t *MerkleSearchTree) Delete(key string) error {
+	layer, err := t.Layer(key)
+	if err != nil {
+		return err
+	}
+
+	root, isFound := mstDelete(t.root, key, layer)
+	if !isFound {
+		return fmt.Errorf("key<%s>: %w", key, ErrMerkleSearchTreeKeyNotFound)
+	}
+	for root != nil && len(root.entries) == 0 {
+		root = root.left
+	}
+	if err = root.seal(t.Hasher); err != nil {
+		return err
+	}
+	t.root = root
+	t.size--
+	return nil
+}
+
+// Walk calls the function passed in parameter for each key of the tree in order
+func (t *MerkleSearchTree) Walk(fn func(key string, value CID) error) error {
+	return t.root.walk(fn)
+}
+
+// Diff returns the keys whose value differs between the tree and the other tree passed in parameter, in order
+// both trees are walked at once and the subtrees with the same cid, i.e. the same keys and values, are skipped
+func (t *MerkleSearchTree) Diff(other *MerkleSearchTree) ([]MerkleSearchTreeDiff, error) {
+	if t.Hasher.Hash != other.Hasher.Hash {
+		return nil, ErrMerkleSearchTreeHashIsDifferent
+	}
+
+	var (
+		diffs []MerkleSearchTreeDiff
+		a     = newMSTCursor(t.root)
+		b     = newMSTCursor(other.root)
+	)
+	for !a.isDone() || !b.isDone() {
+		switch {
+		case !a.isDone() && a.node() != nil && (b.isDone() || b.node() == nil):
+			a.expand()
+		case !b.isDone() && b.node() != nil && (a.isDone() || a.node() == nil):
+			b.expand()
+		case a.isDone():
+			diffs = append(diffs, MerkleSearchTreeDiff{Key: b.entry().key, New: b.entry().value})
+			b.next()
+		case b.isDone():
+			diffs = append(diffs, MerkleSearchTreeDiff{Key: a.entry().key, Old: a.entry().value})
+			a.next()
+		case a.node() != nil && b.node() != nil:
+			// subtrees of the same layer with the same cid have the same keys and values
+			if a.node().layer == b.node().layer && bytes.Equal(a.node().cid, b.node().cid) {
+				a.next()
+				b.next()
+				continue
+			}
+			// the higher subtree is expanded first so that both cursors may meet subtrees of the same layer
+			layerA, layerB := a.node().layer, b.node().layer
+			if layerA >= layerB {
+				a.expand()
+			}
+			if layerB >= layerA {
+				b.expand()
+			}
+		case a.entry().key < b.entry().key:
+			diffs = append(diffs, MerkleSearchTreeDiff{Key: a.entry().key, Old: a.entry().value})
+			a.next()
+		case a.entry().key > b.entry().key:
+			diffs = append(diffs, MerkleSearchTreeDiff{Key: b.entry().key, New: b.entry().value})
+			b.next()
+		default:
+			if !bytes.Equal(a.entry().value, b.entry().value) {
+				diffs = append(diffs, MerkleSearchTreeDiff{Key: a.entry().key, Old: a.entry().value, New: b.entry().value})
+			}
+			a.next()
+			b.next()
+		}
+	}
+	return diffs, nil
+}
+
+// mstInsert returns the node replacing the node of the layer passed in parameter once the key has been inserted and
+// whether the key is a new one, a nil node being an empty subtree
+func mstInsert(n *mstNode, layer int, key string, value CID, keyLayer int) (*mstNode, bool) {
+	node := n.copy(layer)
+	i := node.search(key)
+	if keyLayer < layer {
+		child, isNew := mstInsert(node.child(i), layer-1, key, value, keyLayer)
+		node.setChild(i, child)
+		return node, isNew
+	}
+
+	if i < len(node.entries) && node.entries[i].key == key {
+		node.entries[i].value = value
+		return node, false
+	}
+
+	// the subtree in which the key falls is split in two by the key, its right part becoming the right of the key
+	left, right := mstSplit(node.child(i), key)
+	node.setChild(i, left)
+	node.entries = append(node.entries, mstEntry{})
+	copy(node.entries[i+1:], node.entries[i:])
+	node.entries[i] = mstEntry{key: key, value: value, right: right}
+	return node, true
+}
+
+// mstSplit returns the subtrees of the keys lower and greater than the key passed in parameter
+func mstSplit(n *mstNode, key string) (*mstNode, *mstNode) {
+	if n == nil {
+		return nil, nil
+	}
+
+	i := n.search(key)
+	left, right := mstSplit(n.child(i), key)
+
+	lower := &mstNode{layer: n.layer, left: n.left, entries: append([]mstEntry{}, n.entries[:i]...)}
+	lower.setChild(i, left)
+	greater := &mstNode{layer: n.layer, left: right, entries: append([]mstEntry{}, n.entries[i:]...)}
+	return lower.prune(), greater.prune()
+}
+
+// mstDelete returns the node replacing the node passed in parameter once the key has been removed and whether the key
+// has been found
+func mstDelete(n *mstNode, key string, keyLayer int) (*mstNode, bool) {
+	if n == nil || n.layer < keyLayer {
+		return n, false
+	}
+
+	i := n.search(key)
+	if keyLayer < n.layer {
+		child, isFound := mstDelete(n.child(i), key, keyLayer)
+		if !isFound {
+			return n, false
+		}
+		node := n.copy(n.layer)
+		node.setChild(i, child)
+		return node.prune(), true
+	}
+
+	if i == len(n.entries) || n.entries[i].key != key {
+		return n, false
+	}
+
+	// the subtrees on both sides of the key are merged together
+	node := n.copy(n.layer)
+	merged := mstMerge(node.child(i), node.entries[i].right)
+	node.entries = append(node.entries[:i], node.entries[i+1:]...)
+	node.setChild(i, merged)
+	return node.prune(), true
+}
+
+// mstMerge returns the subtree of the keys of both subtrees of the same layer, the keys of the left one being lower
+func mstMerge(left, right *mstNode) *mstNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+
+	node := &mstNode{layer: left.layer, left: left.left, entries: append(append([]mstEntry{}, left.entries...), right.entries...)}
+	node.setChild(len(left.entries), mstMerge(left.child(len(left.entries)), right.left))
+	return node
+}
+
+// copy returns a copy of the node that can be modified, an empty node of the layer passed in parameter if nil
+func (n *mstNode) copy(layer int) *mstNode {
+	if n == nil {
+		return &mstNode{layer: layer}
+	}
+	return &mstNode{layer: n.layer, left: n.left, entries: append([]mstEntry{}, n.entries...)}
+}
+
+// prune returns nil if the node neither contains any entry nor any subtree
+func (n *mstNode) prune() *mstNode {
+	if len(n.entries) == 0 && n.left == nil {
+		return nil
+	}
+	return n
+}
+
+// search returns the index of the first entry whose key is greater or equal to the key passed in parameter
+func (n *mstNode) search(key string) int {
+	return sort.Search(len(n.entries), func(i int) bool {
+		return n.entries[i].key >= key
+	})
+}
+
+// child returns the subtree before the i-th entry
+func (n *mstNode) child(i int) *mstNode {
+	if i == 0 {
+		return n.left
+	}
+	return n.entries[i-1].right
+}
+
+func (n *mstNode) setChild(i int, child *mstNode) {
+	if i == 0 {
+		n.left = child
+		return
+	}
+	n.entries[i-1].right = child
+}
+
+func (n *mstNode) walk(fn func(key string, value CID) error) error {
+	if n == nil {
+		return nil
+	}
+	if err := n.left.walk(fn); err != nil {
+		return err
+	}
+	for _, e := range n.entries {
+		if err := fn(e.key, e.value); err != nil {
+			return err
+		}
+		if err := e.right.walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seal computes the cid of the node and of its subtrees that have been built by an update
+// the subtrees that already have a cid are shared with the previous versions of the tree and are left untouched
+func (n *mstNode) seal(h *Hasher) error {
+	if n == nil || n.cid != nil {
+		return nil
+	}
+
+	if err := n.left.seal(h); err != nil {
+		return err
+	}
+	for _, e := range n.entries {
+		if err := e.right.seal(h); err != nil {
+			return err
+		}
+	}
+
+	cid, err := n.encode(h)
+	if err != nil {
+		return err
+	}
+	n.cid = cid
+	return nil
+}
+
+// encode returns the cid of the DAG-CBOR serialization of the node, the cids of its subtrees being sealed first
+// each entry key is compressed as the length of the prefix it shares with the previous entry key and the rest of it
+func (n *mstNode) encode(h *Hasher) (CID, error) {
+	var (
+		entries = make([]interface{}, len(n.entries))
+		prev    string
+	)
+	for i, e := range n.entries {
+		p := 0
+		for p < len(prev) && p < len(e.key) && prev[p] == e.key[p] {
+			p++
+		}
+		entries[i] = map[string]interface{}{"k": []byte(e.key[p:]), "p": p, "t": e.right.link(), "v": e.value}
+		prev = e.key
+	}
+
+	b, err := EncodeDAGCBOR(map[string]interface{}{"e": entries, "l": n.left.link()})
+	if err != nil {
+		return nil, fmt.Errorf("EncodeDAGCBOR(): %w", err)
+	}
+	cid, err := NewCID(DAGCBORCodec, h, b)
+	if err != nil {
+		return nil, fmt.Errorf("NewCID(): %w", err)
+	}
+	return cid, nil
+}
+
+// link returns the cid of the subtree, nil being encoded as null
+func (n *mstNode) link() interface{} {
+	if n == nil {
+		return nil
+	}
+	return n.cid
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// mstCursor walks a tree in order, a position being either a subtree that can be expanded or an entry
+type mstCursor struct {
+	stack [][]mstItem
+}
+
+type mstItem struct {
+	node  *mstNode
+	entry *mstEntry
+}
+
+func newMSTCursor(root *mstNode) *mstCursor {
+	c := &mstCursor{}
+	if root != nil {
+		c.stack = [][]mstItem{{{node: root}}}
+	}
+	return c
+}
+
+func (c *mstCursor) isDone() bool {
+	return len(c.stack) == 0
+}
+
+func (c *mstCursor) current() mstItem {
+	return c.stack[len(c.stack)-1][0]
+}
+
+func (c *mstCursor) node() *mstNode {
+	return c.current().node
+}
+
+func (c *mstCursor) entry() *mstEntry {
+	return c.current().entry
+}
+
+// next skips the current position
+func (c *mstCursor) next() {
+	c.stack[len(c.stack)-1] = c.stack[len(c.stack)-1][1:]
+	if len(c.stack[len(c.stack)-1]) == 0 {
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+}
+
+// expand replaces the current subtree by its subtrees and entries
+func (c *mstCursor) expand() {
+	n := c.node()
+	c.next()
+
+	var items []mstItem
+	if n.left != nil {
+		items = append(items, mstItem{node: n.left})
+	}
+	for i := range n.entries {
+		items = append(items, mstItem{entry: &n.entries[i]})
+		if n.entries[i].right != nil {
+			items = append(items, mstItem{node: n.entries[i].right})
+		}
+	}
+	if len(items) > 0 {
+		c.stack = append(c.stack, items)
+	}
+}
@@ -0,0 +1,278 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+const mstTestValue = "bafyreie5cvv4h45feadgeuwhbcutmh6t2ceseocckahdoe6uat64zmz454"
+
+func TestMerkleSearchTreeBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		err    error
+	}{
+		{
+			name:   "build a search tree without hasher should return error",
+			hasher: nil,
+			err:    ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:   "build a search tree truncating its digests should return error",
+			hasher: &Hasher{Hash: SHA256, DigestLength: 16},
+			err:    ErrMerkleSearchTreeConfigHasherIsWrong,
+		},
+		{
+			name:   "build a search tree sorting its leaves should return error",
+			hasher: &Hasher{Hash: SHA256, IsSortLeaves: true},
+			err:    ErrMerkleSearchTreeConfigHasherIsWrong,
+		},
+		{
+			name:   "build a search tree should return the empty root of at protocol",
+			hasher: &Hasher{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewMerkleSearchTreeBuilder().WithHasher(tt.hasher).Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			root, err := tree.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, "bafyreie5737gdxlw5i64vzichcalba3z2v5n6icifvx5xytvske7mr3hpm", root.String())
+		})
+	}
+}
+
+func TestMerkleSearchTree_Layer(t *testing.T) {
+	tree, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+
+	for key, expected := range map[string]int{
+		"2653ae71":                        0,
+		"blue":                            1,
+		"app.bsky.feed.post/454397e440ec": 4,
+		"app.bsky.feed.post/9adeb165882c": 8,
+	} {
+		layer, err := tree.Layer(key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, layer, key)
+	}
+}
+
+func TestMerkleSearchTree_Root(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []string
+		expected string
+	}{
+		{
+			name:     "a tree with a key of layer 0 should return the root of at protocol",
+			keys:     []string{"com.example.record/3jqfcqzm3fo2j"},
+			expected: "bafyreibj4lsc3aqnrvphp5xmrnfoorvru4wynt6lwidqbm2623a6tatzdu",
+		},
+		{
+			name:     "a tree with a key of layer 2 should return the root of at protocol",
+			keys:     []string{"com.example.record/3jqfcqzm3fx2j"},
+			expected: "bafyreih7wfei65pxzhauoibu3ls7jgmkju4bspy4t2ha2qdjnzqvoy33ai",
+		},
+		{
+			name: "a tree with keys of layers 0 and 1 should return the root of at protocol",
+			keys: []string{
+				"com.example.record/3jqfcqzm3fp2j",
+				"com.example.record/3jqfcqzm3fr2j",
+				"com.example.record/3jqfcqzm3fs2j",
+				"com.example.record/3jqfcqzm3ft2j",
+				"com.example.record/3jqfcqzm4fc2j",
+			},
+			expected: "bafyreicmahysq4n6wfuxo522m6dpiy7z7qzym3dzs756t5n7nfdgccwq7m",
+		},
+	}
+	value, err := ParseCID(mstTestValue)
+	assert.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewMerkleSearchTreeBuilder().Build()
+			assert.NoError(t, err)
+			for _, key := range tt.keys {
+				assert.NoError(t, tree.Put(key, value))
+			}
+			root, err := tree.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, root.String())
+			assert.Equal(t, len(tt.keys), tree.Size())
+		})
+	}
+}
+
+func TestMerkleSearchTree_HistoryIndependence(t *testing.T) {
+	value, err := ParseCID(mstTestValue)
+	assert.NoError(t, err)
+
+	keys := make([]string, 300)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("app.bsky.feed.post/%08d", i)
+	}
+
+	expected, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+	for _, key := range keys {
+		assert.NoError(t, expected.Put(key, value))
+	}
+	expectedRoot, err := expected.Root()
+	assert.NoError(t, err)
+
+	// putting the keys in another order along with keys deleted afterwards should return the same root
+	r := rand.New(rand.NewSource(42))
+	for run := 0; run < 5; run++ {
+		tree, err := NewMerkleSearchTreeBuilder().Build()
+		assert.NoError(t, err)
+		for _, i := range r.Perm(len(keys)) {
+			assert.NoError(t, tree.Put(keys[i], value))
+			assert.NoError(t, tree.Put(fmt.Sprintf("%s/deleted", keys[i]), value))
+		}
+		for _, i := range r.Perm(len(keys)) {
+			assert.NoError(t, tree.Delete(fmt.Sprintf("%s/deleted", keys[i])))
+		}
+
+		root, err := tree.Root()
+		assert.NoError(t, err)
+		assert.Equal(t, expectedRoot, root)
+		assert.Equal(t, len(keys), tree.Size())
+	}
+
+	// deleting every key should return the empty root
+	for _, key := range keys {
+		assert.NoError(t, expected.Delete(key))
+	}
+	root, err := expected.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, "bafyreie5737gdxlw5i64vzichcalba3z2v5n6icifvx5xytvske7mr3hpm", root.String())
+}
+
+func TestMerkleSearchTree_ConcurrentReads(t *testing.T) {
+	value, err := ParseCID(mstTestValue)
+	assert.NoError(t, err)
+
+	tree, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+	expected, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+	other, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("app.bsky.feed.post/%08d", i)
+		assert.NoError(t, tree.Put(key, value))
+		assert.NoError(t, expected.Put(key, value))
+		if i != 0 {
+			assert.NoError(t, other.Put(key, value))
+		}
+	}
+	root, err := expected.Root()
+	assert.NoError(t, err)
+
+	// the cids are computed once the nodes are built so that a tree can be read concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := tree.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, root, got)
+
+			diffs, err := tree.Diff(other)
+			assert.NoError(t, err)
+			assert.Len(t, diffs, 1)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMerkleSearchTree_GetAndWalk(t *testing.T) {
+	tree, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+
+	values := make(map[string]CID)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("com.example.record/%03d", 99-i)
+		values[key], err = NewCID(RawCodec, tree.Hasher, []byte(key))
+		assert.NoError(t, err)
+		assert.NoError(t, tree.Put(key, values[key]))
+	}
+
+	value, err := tree.Get("com.example.record/042")
+	assert.NoError(t, err)
+	assert.Equal(t, values["com.example.record/042"], value)
+
+	_, err = tree.Get("com.example.record/100")
+	assert.ErrorIs(t, err, ErrMerkleSearchTreeKeyNotFound)
+	assert.ErrorIs(t, tree.Delete("com.example.record/100"), ErrMerkleSearchTreeKeyNotFound)
+	assert.ErrorIs(t, tree.Put("", value), ErrMerkleSearchTreeKeyIsEmpty)
+	assert.ErrorIs(t, tree.Put("com.example.record/100", CID{2}), ErrCIDIsWrong)
+
+	var keys []string
+	assert.NoError(t, tree.Walk(func(key string, value CID) error {
+		assert.Equal(t, values[key], value)
+		keys = append(keys, key)
+		return nil
+	}))
+	assert.Len(t, keys, 100)
+	assert.IsIncreasing(t, keys)
+}
+
+func TestMerkleSearchTree_Diff(t *testing.T) {
+	value, err := ParseCID(mstTestValue)
+	assert.NoError(t, err)
+	updated, err := NewCID(RawCodec, &Hasher{Hash: SHA256}, []byte("updated"))
+	assert.NoError(t, err)
+
+	a, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+	b, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+	for i := 0; i < 500; i++ {
+		assert.NoError(t, a.Put(fmt.Sprintf("key%04d", i), value))
+		assert.NoError(t, b.Put(fmt.Sprintf("key%04d", i), value))
+	}
+
+	diffs, err := a.Diff(b)
+	assert.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	assert.NoError(t, b.Delete("key0007"))
+	assert.NoError(t, b.Put("key0250", updated))
+	assert.NoError(t, b.Put("key9999", value))
+	assert.NoError(t, b.Put("a", value))
+
+	diffs, err = a.Diff(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []MerkleSearchTreeDiff{
+		{Key: "a", New: value},
+		{Key: "key0007", Old: value},
+		{Key: "key0250", Old: value, New: updated},
+		{Key: "key9999", New: value},
+	}, diffs)
+
+	// the diff against an empty tree should return every key
+	empty, err := NewMerkleSearchTreeBuilder().Build()
+	assert.NoError(t, err)
+	diffs, err = empty.Diff(a)
+	assert.NoError(t, err)
+	assert.Len(t, diffs, 500)
+
+	other, err := NewMerkleSearchTreeBuilder().WithHasher(&Hasher{Hash: SHA512}).Build()
+	assert.NoError(t, err)
+	_, err = a.Diff(other)
+	assert.ErrorIs(t, err, ErrMerkleSearchTreeHashIsDifferent)
+}
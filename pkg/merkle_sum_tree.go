@@ -0,0 +1,286 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	"math"
+)
+
+// MerkleSumTree is a tree whose nodes commit to the sum of the balances of their subtree, as used by proofs of
+// liabilities: a customer verifies that their balance is included and that the total published along with the root
+// is consistent with the balances of the tree
+// a node is serialized as its hash followed by its sum on 8 bytes big endian, the hash of a leaf being
+// H(account || balance) and the hash of a parent node being H(left || right || sum) so that it commits to the sum of
+// each child and not only to their total
+// a node without sibling is promoted to the level above unchanged
+type MerkleSumTree struct {
+	MerkleSumTreeConfig
+	Leaves   []MerkleSumLeaf
+	levels   [][]MerkleSumNode
+	accounts map[string]int
+}
+
+// MerkleSumTreeConfig is the configuration that represents the options used to build the tree
+type MerkleSumTreeConfig struct {
+	Hasher       *Hasher
+	MaxGoroutine uint32
+}
+
+// MerkleSumTreeBuilder allows use to pass the configuration before building a sum tree
+type MerkleSumTreeBuilder struct {
+	config *MerkleSumTreeConfig
+}
+
+// MerkleSumLeaf is the balance of an account, in the smallest unit of the asset
+type MerkleSumLeaf struct {
+	Account string
+	Balance uint64
+}
+
+// MerkleSumNode is the hash of a node and the sum of the balances of its subtree
+type MerkleSumNode struct {
+	Hash []byte
+	Sum  uint64
+}
+
+// MerkleSumProof is the inclusion proof of the balance of an account, Steps being ordered from the leaf up to the root
+type MerkleSumProof struct {
+	Hash         Hash
+	DigestLength uint32
+	Account      string
+	Balance      uint64
+	Steps        []MerkleSumProofStep
+}
+
+// MerkleSumProofStep is the sibling a node is paired with, Position being the position of the node in the pair
+type MerkleSumProofStep struct {
+	Sibling  MerkleSumNode
+	Position int
+}
+
+var (
+	ErrMerkleSumTreeAccountIsDuplicated  = errors.New("the merkle sum tree cannot contain the same account twice")
+	ErrMerkleSumTreeAccountNotFound      = errors.New("the merkle sum tree does not contain the account")
+	ErrMerkleSumTreeSumOverflow          = errors.New("the merkle sum tree sum of balances overflows")
+	ErrMerkleSumTreeConfigSortNotAllowed = errors.New("the merkle sum tree cannot sort leaves or pairs as the sums are ordered with the hashes")
+)
+
+func NewMerkleSumTreeBuilder() *MerkleSumTreeBuilder {
+	return &MerkleSumTreeBuilder{config: &MerkleSumTreeConfig{}}
+}
+
+func (b *MerkleSumTreeBuilder) WithHasher(hasher *Hasher) *MerkleSumTreeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+func (b *MerkleSumTreeBuilder) WithMaxGoroutine(maxGoroutine uint32) *MerkleSumTreeBuilder {
+	b.config.MaxGoroutine = maxGoroutine
+	return b
+}
+
+// Build builds the tree with the balances passed in parameter
+func (b *MerkleSumTreeBuilder) Build(ctx context.Context, leaves []MerkleSumLeaf) (*MerkleSumTree, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if b.config.Hasher.IsSortLeaves || b.config.Hasher.IsSortPairs {
+		return nil, ErrMerkleSumTreeConfigSortNotAllowed
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if err := b.config.Hasher.Hash.ValidateDigestLength(b.config.Hasher.DigestLength); err != nil {
+		return nil, fmt.Errorf("Hash.ValidateDigestLength(): %w", err)
+	}
+
+	if b.config.MaxGoroutine == 0 {
+		return nil, ErrMerkleTreeConfigMaxGoroutineIsEqZero
+	}
+
+	if len(leaves) == 0 {
+		return nil, ErrMerkleTreeDataIsNilOrEmpty
+	}
+
+	t := &MerkleSumTree{
+		MerkleSumTreeConfig: *b.config,
+		Leaves:              leaves,
+		accounts:            make(map[string]int, len(leaves)),
+	}
+	for i, leaf := range leaves {
+		if _, ok := t.accounts[leaf.Account]; ok {
+			return nil, fmt.Errorf("account<%s>: %w", leaf.Account, ErrMerkleSumTreeAccountIsDuplicated)
+		}
+		t.accounts[leaf.Account] = i
+	}
+
+	level, err := t.generateLeafNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.levels = [][]MerkleSumNode{level}
+	for len(level) > 1 {
+		if level, err = t.generateParentNodes(ctx, level); err != nil {
+			return nil, err
+		}
+		t.levels = append(t.levels, level)
+	}
+	return t, nil
+}
+
+// Root returns the root of the tree, its sum being the total of the balances
+func (t *MerkleSumTree) Root() MerkleSumNode {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Proof generates the inclusion proof of the balance of the account passed in parameter
+func (t *MerkleSumTree) Proof(account string) (*MerkleSumProof, error) {
+	index, ok := t.accounts[account]
+	if !ok {
+		return nil, fmt.Errorf("account<%s>: %w", account, ErrMerkleSumTreeAccountNotFound)
+	}
+
+	p := &MerkleSumProof{
+		Hash:         t.Hasher.Hash,
+		DigestLength: t.Hasher.DigestLength,
+		Account:      account,
+		Balance:      t.Leaves[index].Balance,
+	}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		// a node without sibling is promoted and does not produce any step
+		if sibling := index ^ 1; sibling < len(level) {
+			p.Steps = append(p.Steps, MerkleSumProofStep{Sibling: level[sibling], Position: index & 1})
+		}
+		index >>= 1
+	}
+	return p, nil
+}
+
+// Verify recomputes the root from the balance of the account and the siblings and checks it against the root passed
+// in parameter, both its hash and its sum
+func (p *MerkleSumProof) Verify(root MerkleSumNode) (bool, error) {
+	if p == nil {
+		return false, ErrProofIsNil
+	}
+	if !p.Hash.IsValid() {
+		return false, fmt.Errorf(ErrHashNotAllowed.Error(), p.Hash)
+	}
+	if err := p.Hash.ValidateDigestLength(p.DigestLength); err != nil {
+		return false, fmt.Errorf("p.Hash.ValidateDigestLength(): %w", err)
+	}
+
+	h := &Hasher{Hash: p.Hash, DigestLength: p.DigestLength}
+	node, err := hashSumLeaf(h, MerkleSumLeaf{Account: p.Account, Balance: p.Balance})
+	if err != nil {
+		return false, err
+	}
+	for _, step := range p.Steps {
+		left, right := node, step.Sibling
+		switch step.Position {
+		case 0:
+		case 1:
+			left, right = step.Sibling, node
+		default:
+			return false, ErrProofStepIsWrong
+		}
+		if node, err = hashSumNode(h, left, right); err != nil {
+			return false, err
+		}
+	}
+	return node.Sum == root.Sum && bytes.Equal(node.Hash, root.Hash), nil
+}
+
+func (t *MerkleSumTree) generateLeafNodes(ctx context.Context) ([]MerkleSumNode, error) {
+	nodes := make([]MerkleSumNode, len(t.Leaves))
+
+	errs, _ := errgroup.WithContext(ctx)
+	errs.SetLimit(int(t.MaxGoroutine))
+	for _i := 0; _i < len(t.Leaves); _i++ {
+		// i can change in the below go routine, allocates a local scope via i
+		i := _i
+
+		errs.Go(func() error {
+			node, err := hashSumLeaf(t.Hasher, t.Leaves[i])
+			if err != nil {
+				return fmt.Errorf("hashSumLeaf(leaves[%d]): %w", i, err)
+			}
+			nodes[i] = node
+			return nil
+		})
+	}
+
+	// wait for all the go routines to be done
+	if err := errs.Wait(); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// generateParentNodes pairs the nodes of a level, the last node being promoted if it has no sibling
+func (t *MerkleSumTree) generateParentNodes(ctx context.Context, level []MerkleSumNode) ([]MerkleSumNode, error) {
+	parents := make([]MerkleSumNode, (len(level)+1)/2)
+	if len(level)%2 == 1 {
+		parents[len(parents)-1] = level[len(level)-1]
+	}
+
+	errs, _ := errgroup.WithContext(ctx)
+	errs.SetLimit(int(t.MaxGoroutine))
+	for _i := 0; _i < len(level)/2; _i++ {
+		// i can change in the below go routine, allocates a local scope via i
+		i := _i
+
+		errs.Go(func() error {
+			node, err := hashSumNode(t.Hasher, level[2*i], level[2*i+1])
+			if err != nil {
+				return err
+			}
+			parents[i] = node
+			return nil
+		})
+	}
+
+	// wait for all the go routines to be done
+	if err := errs.Wait(); err != nil {
+		return nil, err
+	}
+	return parents, nil
+}
+
+// hashSumLeaf returns the leaf of a balance, i.e. H(account || balance) and the balance
+func hashSumLeaf(h *Hasher, leaf MerkleSumLeaf) (MerkleSumNode, error) {
+	hash, err := h.hashData(binary.BigEndian.AppendUint64([]byte(leaf.Account), leaf.Balance))
+	if err != nil {
+		return MerkleSumNode{}, fmt.Errorf("h.hashData(account<%s>): %w", leaf.Account, err)
+	}
+	return MerkleSumNode{Hash: hash, Sum: leaf.Balance}, nil
+}
+
+// hashSumNode returns the parent of both nodes, i.e. H(left || right || sum) and the sum of their sums
+func hashSumNode(h *Hasher, left, right MerkleSumNode) (MerkleSumNode, error) {
+	if left.Sum > math.MaxUint64-right.Sum {
+		return MerkleSumNode{}, ErrMerkleSumTreeSumOverflow
+	}
+	sum := left.Sum + right.Sum
+
+	hash, err := h.hashChildren(left.bytes(), right.bytes(), binary.BigEndian.AppendUint64(nil, sum))
+	if err != nil {
+		return MerkleSumNode{}, fmt.Errorf("h.hashChildren(): %w", err)
+	}
+	return MerkleSumNode{Hash: hash, Sum: sum}, nil
+}
+
+// bytes serializes the node as its hash followed by its sum
+func (n MerkleSumNode) bytes() []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{}, n.Hash...), n.Sum)
+}
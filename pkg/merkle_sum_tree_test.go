@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestMerkleSumTreeBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name         string
+		hasher       *Hasher
+		maxGoroutine uint32
+		leaves       []MerkleSumLeaf
+		err          error
+	}{
+		{
+			name:         "build a tree without hasher should return error",
+			hasher:       nil,
+			maxGoroutine: 1,
+			leaves:       []MerkleSumLeaf{{Account: "alice", Balance: 1}},
+			err:          ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:         "build a tree without goroutine should return error",
+			hasher:       &Hasher{Hash: SHA256},
+			maxGoroutine: 0,
+			leaves:       []MerkleSumLeaf{{Account: "alice", Balance: 1}},
+			err:          ErrMerkleTreeConfigMaxGoroutineIsEqZero,
+		},
+		{
+			name:         "build a tree with sorted pairs should return error",
+			hasher:       &Hasher{Hash: SHA256, IsSortPairs: true},
+			maxGoroutine: 1,
+			leaves:       []MerkleSumLeaf{{Account: "alice", Balance: 1}},
+			err:          ErrMerkleSumTreeConfigSortNotAllowed,
+		},
+		{
+			name:         "build a tree with a too short digest length should return error",
+			hasher:       &Hasher{Hash: SHA256, DigestLength: 8},
+			maxGoroutine: 1,
+			leaves:       []MerkleSumLeaf{{Account: "alice", Balance: 1}},
+			err:          ErrHashDigestLengthTooShort,
+		},
+		{
+			name:         "build a tree without leaves should return error",
+			hasher:       &Hasher{Hash: SHA256},
+			maxGoroutine: 1,
+			err:          ErrMerkleTreeDataIsNilOrEmpty,
+		},
+		{
+			name:         "build a tree with a duplicated account should return error",
+			hasher:       &Hasher{Hash: SHA256},
+			maxGoroutine: 1,
+			leaves:       []MerkleSumLeaf{{Account: "alice", Balance: 1}, {Account: "alice", Balance: 2}},
+			err:          ErrMerkleSumTreeAccountIsDuplicated,
+		},
+		{
+			name:         "build a tree whose total overflows should return error",
+			hasher:       &Hasher{Hash: SHA256},
+			maxGoroutine: 1,
+			leaves:       []MerkleSumLeaf{{Account: "alice", Balance: math.MaxUint64}, {Account: "bob", Balance: 1}},
+			err:          ErrMerkleSumTreeSumOverflow,
+		},
+		{
+			name:         "build a tree should return the total of the balances",
+			hasher:       &Hasher{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+			maxGoroutine: 2,
+			leaves:       []MerkleSumLeaf{{Account: "alice", Balance: 10}, {Account: "bob", Balance: 0}, {Account: "carol", Balance: 32}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mst, err := NewMerkleSumTreeBuilder().WithHasher(tt.hasher).WithMaxGoroutine(tt.maxGoroutine).Build(context.TODO(), tt.leaves)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, uint64(42), mst.Root().Sum)
+		})
+	}
+
+	// an unknown hash algorithm is rejected instead of panicking once the hashes are computed
+	_, err := NewMerkleSumTreeBuilder().WithHasher(&Hasher{Hash: "md5"}).WithMaxGoroutine(1).Build(context.TODO(), []MerkleSumLeaf{{Account: "alice", Balance: 1}})
+	assert.EqualError(t, err, fmt.Sprintf(ErrHashNotAllowed.Error(), "md5"))
+}
+
+func TestMerkleSumTree_Root(t *testing.T) {
+	leaf := func(account string, balance uint64) []byte {
+		h := sha256.Sum256(binary.BigEndian.AppendUint64([]byte(account), balance))
+		return h[:]
+	}
+	node := func(left []byte, leftSum uint64, right []byte, rightSum uint64) []byte {
+		b := binary.BigEndian.AppendUint64(append([]byte{}, left...), leftSum)
+		b = binary.BigEndian.AppendUint64(append(b, right...), rightSum)
+		h := sha256.Sum256(binary.BigEndian.AppendUint64(b, leftSum+rightSum))
+		return h[:]
+	}
+
+	// the balance of carol is promoted and paired at the level above
+	ab := node(leaf("alice", 10), 10, leaf("bob", 20), 20)
+	expected := node(ab, 30, leaf("carol", 5), 5)
+
+	mst, err := NewMerkleSumTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).WithMaxGoroutine(1).Build(context.TODO(), []MerkleSumLeaf{
+		{Account: "alice", Balance: 10},
+		{Account: "bob", Balance: 20},
+		{Account: "carol", Balance: 5},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, MerkleSumNode{Hash: expected, Sum: 35}, mst.Root())
+}
+
+func TestMerkleSumTree_Proof(t *testing.T) {
+	for _, nbLeaves := range []int{1, 2, 3, 7, 16} {
+		t.Run(fmt.Sprintf("%d leaves", nbLeaves), func(t *testing.T) {
+			leaves := make([]MerkleSumLeaf, nbLeaves)
+			for i := range leaves {
+				leaves[i] = MerkleSumLeaf{Account: fmt.Sprintf("account-%d", i), Balance: uint64(i * 100)}
+			}
+			mst, err := NewMerkleSumTreeBuilder().WithHasher(&Hasher{Hash: SHA256, DigestLength: 20}).WithMaxGoroutine(4).Build(context.TODO(), leaves)
+			assert.NoError(t, err)
+			root := mst.Root()
+
+			for _, l := range leaves {
+				p, err := mst.Proof(l.Account)
+				assert.NoError(t, err)
+				ok, err := p.Verify(root)
+				assert.NoError(t, err)
+				assert.True(t, ok, "account<%s>", l.Account)
+
+				// a balance lowered by the exchange is detected
+				if l.Balance > 0 {
+					p.Balance--
+					ok, err = p.Verify(root)
+					assert.NoError(t, err)
+					assert.False(t, ok, "account<%s>", l.Account)
+					p.Balance++
+				}
+
+				// a total that differs from the published one is detected
+				ok, err = p.Verify(MerkleSumNode{Hash: root.Hash, Sum: root.Sum + 1})
+				assert.NoError(t, err)
+				assert.False(t, ok, "account<%s>", l.Account)
+			}
+		})
+	}
+
+	mst, err := NewMerkleSumTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).WithMaxGoroutine(1).Build(context.TODO(), []MerkleSumLeaf{
+		{Account: "alice", Balance: 10},
+		{Account: "bob", Balance: 20},
+	})
+	assert.NoError(t, err)
+
+	_, err = mst.Proof("carol")
+	assert.ErrorIs(t, err, ErrMerkleSumTreeAccountNotFound)
+
+	// a sibling whose sum is altered no longer matches the root even though the total is kept
+	p, err := mst.Proof("alice")
+	assert.NoError(t, err)
+	p.Steps[0].Sibling.Sum = 15
+	p.Balance = 25
+	ok, err := p.Verify(mst.Root())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	p.Steps[0].Position = 2
+	_, err = p.Verify(mst.Root())
+	assert.ErrorIs(t, err, ErrProofStepIsWrong)
+
+	var nilProof *MerkleSumProof
+	_, err = nilProof.Verify(mst.Root())
+	assert.ErrorIs(t, err, ErrProofIsNil)
+}
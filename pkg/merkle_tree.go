@@ -12,17 +12,41 @@ import (
 
 // MerkleTree is the data structure representing a tree
 // it contains the root which is a concatenation of all the hashes from all the nodes of the tree
+// DroppedLeaves reports the duplicated leaves removed at build time when deduplication is enabled
 type MerkleTree struct {
-	Root   *Node
-	Leaves []*Node
+	Root          *Node
+	Leaves        []*Node
+	DroppedLeaves []DroppedLeaf
 	MerkleTreeConfig
+
+	// levels contains the nodes of each level of the tree, from the leaves up to the root
+	levels [][]*Node
+	// subscribers are notified whenever the root of the tree changes
+	subscribers subscribers
 }
 
 // MerkleTreeConfig is the configuration that represents the options used to build / verify the tree
+// Hasher is the main hasher of the tree, Hashers contains all the hashers computed in parallel in a single build pass
+// with Hasher being its first element
+// DigestLength is the length the parent node hashes are truncated to, 0 meaning that no truncation happens
+// OddNodeStrategy defines how unpaired nodes are handled, EmptyLeafHashes are the hashes per algorithm of the leaves
+// used to pad the tree with the PAD strategy
+// Arity is the number of children of each parent node, 2 (binary tree) by default
+// Deduplication defines whether the leaves with the same hash are removed before being grouped
+// DeletionMode defines how leaves are deleted, TombstoneHashes are the hashes per algorithm of the deleted leaves
+// with the TOMBSTONE mode
 type MerkleTreeConfig struct {
-	Hasher       *Hasher
-	MaxGoroutine uint32
-	isSort       bool
+	Hasher          *Hasher
+	Hashers         []*Hasher
+	MaxGoroutine    uint32
+	DigestLength    uint32
+	Arity           uint32
+	Deduplication   DeduplicationMode
+	DeletionMode    DeletionMode
+	TombstoneHashes map[Hash][]byte
+	OddNodeStrategy OddNodeStrategy
+	EmptyLeafHashes map[Hash][]byte
+	isSort          bool
 }
 
 // MerkleTreeBuilder allows use to pass the configuration from the cli before building a tree
@@ -35,6 +59,21 @@ var (
 	ErrMerkleTreeConfigHasherIsNil          = errors.New("the merkle tree configWithHashPool hasher cannot be nil")
 	ErrMerkleTreeConfigMaxGoroutineIsEqZero = errors.New("the merkle tree configWithHashPool max goroutine cannot be equal to 0")
 	ErrMerkleTreeDataIsNilOrEmpty           = errors.New("the merkle tree data cannot be nil or empty")
+	ErrMerkleTreeConfigHasherIsDuplicated   = errors.New("the merkle tree configWithHashPool hashers cannot contain the same algorithm twice")
+	ErrMerkleTreeHashNotBuilt               = errors.New("the merkle tree has not been built with this hash algorithm")
+	ErrMerkleTreeLeafNotFound               = errors.New("the merkle tree does not contain the leaf")
+	ErrMerkleTreeConfigEmptyLeafHashIsWrong = errors.New("the merkle tree configWithHashPool empty leaf hash must be the size of its hash algorithm digest")
+	ErrMerkleTreeConfigArityIsWrong         = errors.New("the merkle tree configWithHashPool arity must be between 2 and the max arity")
+	ErrMerkleTreeStructureIsWrong           = errors.New("the merkle tree nodes do not match its nb of leaves and its configuration")
+	ErrMerkleTreeConfigSortLeavesIsWrong    = errors.New("the merkle tree configWithHashPool cannot sort the leaves with several hashers")
+	ErrMerkleTreeConfigDigestLengthIsWrong  = errors.New("the merkle tree configWithHashPool digest length conflicts with the hasher one")
+)
+
+const (
+	// DefaultArity is the arity of a binary tree
+	DefaultArity = 2
+	// MaxArity is the highest number of children a parent node can have
+	MaxArity = 16
 )
 
 func NewMerkleTreeBuilder() *MerkleTreeBuilder {
@@ -43,6 +82,20 @@ func NewMerkleTreeBuilder() *MerkleTreeBuilder {
 
 func (b *MerkleTreeBuilder) WithHasher(hasher *Hasher) *MerkleTreeBuilder {
 	b.config.Hasher = hasher
+	b.config.Hashers = []*Hasher{hasher}
+	return b
+}
+
+// WithHashers allows the tree to be built with several hash algorithms in a single pass over the data
+// the first hasher is considered as the main one, it is the one used to verify the tree
+// the leaves cannot be sorted as their order would depend on the hasher, the pairs can
+func (b *MerkleTreeBuilder) WithHashers(hashers ...*Hasher) *MerkleTreeBuilder {
+	b.config.Hashers = hashers
+	if len(hashers) > 0 {
+		b.config.Hasher = hashers[0]
+	} else {
+		b.config.Hasher = nil
+	}
 	return b
 }
 
@@ -51,6 +104,56 @@ func (b *MerkleTreeBuilder) WithMaxGoroutine(maxGoroutine uint32) *MerkleTreeBui
 	return b
 }
 
+// WithDigestLength truncates the parent node hashes for compact trees and proofs, leaves being kept untouched
+func (b *MerkleTreeBuilder) WithDigestLength(digestLength uint32) *MerkleTreeBuilder {
+	b.config.DigestLength = digestLength
+	return b
+}
+
+// WithOddNodeStrategy defines how a node that has no sibling to be paired with is handled, DUPLICATE by default
+func (b *MerkleTreeBuilder) WithOddNodeStrategy(strategy OddNodeStrategy) *MerkleTreeBuilder {
+	b.config.OddNodeStrategy = strategy
+	return b
+}
+
+// WithEmptyLeafHash sets the hash of the leaves used to pad the tree for the hash algorithm passed in parameter
+// it is only used by the PAD strategy, a digest full of zeros being used by default
+func (b *MerkleTreeBuilder) WithEmptyLeafHash(hash Hash, emptyLeafHash []byte) *MerkleTreeBuilder {
+	if b.config.EmptyLeafHashes == nil {
+		b.config.EmptyLeafHashes = map[Hash][]byte{}
+	}
+	b.config.EmptyLeafHashes[hash] = emptyLeafHash
+	return b
+}
+
+// WithArity sets the number of children of each parent node, e.g. 4, 8 or 16 to cut the height of the tree
+func (b *MerkleTreeBuilder) WithArity(arity uint32) *MerkleTreeBuilder {
+	b.config.Arity = arity
+	return b
+}
+
+// WithDeduplication removes the leaves with the same hash before grouping them, giving set semantics to the tree
+func (b *MerkleTreeBuilder) WithDeduplication(mode DeduplicationMode) *MerkleTreeBuilder {
+	b.config.Deduplication = mode
+	return b
+}
+
+// WithDeletionMode defines how leaves are deleted, TOMBSTONE by default
+func (b *MerkleTreeBuilder) WithDeletionMode(mode DeletionMode) *MerkleTreeBuilder {
+	b.config.DeletionMode = mode
+	return b
+}
+
+// WithTombstoneHash sets the hash of the deleted leaves for the hash algorithm passed in parameter
+// it is only used by the TOMBSTONE mode, a digest full of 0xff being used by default
+func (b *MerkleTreeBuilder) WithTombstoneHash(hash Hash, tombstoneHash []byte) *MerkleTreeBuilder {
+	if b.config.TombstoneHashes == nil {
+		b.config.TombstoneHashes = map[Hash][]byte{}
+	}
+	b.config.TombstoneHashes[hash] = tombstoneHash
+	return b
+}
+
 // Build builds the tree with the data passed parameter
 // we allow the passage of a context in order to be able to stop the execution from the caller if needed
 func (b *MerkleTreeBuilder) Build(ctx context.Context, data []Data) (*MerkleTree, error) {
@@ -70,10 +173,66 @@ func (b *MerkleTreeBuilder) Build(ctx context.Context, data []Data) (*MerkleTree
 		return mt, ErrMerkleTreeConfigHasherIsNil
 	}
 
+	algos := make(map[Hash]struct{}, len(b.config.Hashers))
+	for _, h := range b.config.Hashers {
+		if h == nil {
+			return mt, ErrMerkleTreeConfigHasherIsNil
+		}
+		if _, ok := algos[h.Hash]; ok {
+			return mt, ErrMerkleTreeConfigHasherIsDuplicated
+		}
+		if !h.Hash.IsValid() {
+			return mt, fmt.Errorf(ErrHashNotAllowed.Error(), h.Hash)
+		}
+		if err = h.Hash.ValidateDigestLength(b.config.DigestLength); err != nil {
+			return mt, fmt.Errorf("h.Hash.ValidateDigestLength(): %w", err)
+		}
+		if err = h.Hash.ValidateDigestLength(h.DigestLength); err != nil {
+			return mt, fmt.Errorf("h.Hash.ValidateDigestLength(): %w", err)
+		}
+		if b.config.DigestLength != 0 && h.DigestLength != 0 && b.config.DigestLength != h.DigestLength {
+			return mt, fmt.Errorf("hash<%s> digest length<%d> hasher digest length<%d>: %w",
+				h.Hash, b.config.DigestLength, h.DigestLength, ErrMerkleTreeConfigDigestLengthIsWrong)
+		}
+		// the leaves are ordered once for all the hashers, an order per hasher would need a tree per hasher
+		if h.IsSortLeaves && len(b.config.Hashers) > 1 {
+			return mt, fmt.Errorf("hash<%s>: %w", h.Hash, ErrMerkleTreeConfigSortLeavesIsWrong)
+		}
+		algos[h.Hash] = struct{}{}
+	}
+
 	if b.config.MaxGoroutine == 0 {
 		return mt, ErrMerkleTreeConfigMaxGoroutineIsEqZero
 	}
 
+	if arity := b.config.arity(); arity < DefaultArity || arity > MaxArity {
+		return mt, fmt.Errorf("arity<%d>: %w", arity, ErrMerkleTreeConfigArityIsWrong)
+	}
+
+	if err = b.config.oddNodeStrategy().Validate(); err != nil {
+		return mt, err
+	}
+
+	if err = b.config.deduplication().Validate(); err != nil {
+		return mt, err
+	}
+
+	if err = b.config.deletionMode().Validate(); err != nil {
+		return mt, err
+	}
+
+	for hash, tombstoneHash := range b.config.TombstoneHashes {
+		if !hash.IsValid() || len(tombstoneHash) != hash.Size() {
+			return mt, fmt.Errorf("hash<%s>: %w", hash, ErrMerkleTreeConfigTombstoneHashIsWrong)
+		}
+	}
+
+	for hash, emptyLeafHash := range b.config.EmptyLeafHashes {
+		if !hash.IsValid() || len(emptyLeafHash) != hash.Size() {
+			return mt, fmt.Errorf("hash<%s>: %w", hash, ErrMerkleTreeConfigEmptyLeafHashIsWrong)
+		}
+	}
+
 	if len(data) == 0 {
 		return mt, ErrMerkleTreeDataIsNilOrEmpty
 	}
@@ -85,11 +244,29 @@ func (b *MerkleTreeBuilder) Build(ctx context.Context, data []Data) (*MerkleTree
 		MerkleTreeConfig: *b.config,
 	}
 
+	// the defaults are applied to the configuration of the tree, the builder being left untouched
+	mt.Arity = uint32(mt.arity())
+	mt.OddNodeStrategy = mt.oddNodeStrategy()
+	mt.Deduplication = mt.deduplication()
+	mt.DeletionMode = mt.deletionMode()
+
+	// hashers are copied so that the caller's ones are left untouched by the tree configuration
+	if mt.DigestLength != 0 {
+		mt.Hashers = make([]*Hasher, len(b.config.Hashers))
+		for i, h := range b.config.Hashers {
+			_h := *h
+			_h.DigestLength = mt.DigestLength
+			mt.Hashers[i] = &_h
+		}
+		mt.Hasher = mt.Hashers[0]
+	}
+
 	// build tree
 	if leafNodes, err = mt.generateLeafNodes(ctx, data); err != nil {
 		return mt, fmt.Errorf("mt.generateLeafNodes(data): %w", err)
 	}
 
+	mt.levels = [][]*Node{leafNodes}
 	if mt.Root, err = mt.generateParentNodes(ctx, leafNodes); err != nil {
 		return mt, fmt.Errorf("mt.generateParentNodes(): %w", err)
 	}
@@ -100,26 +277,22 @@ func (b *MerkleTreeBuilder) Build(ctx context.Context, data []Data) (*MerkleTree
 }
 
 // generateLeafNodes generates an array of Nodes that represents the leaves placed at the bottom of the tree
-// it handles the case where there's an uneven nb of leaves in the tree
+// it handles the case where there's an uneven nb of leaves in the tree according to the odd node strategy
 func (mt *MerkleTree) generateLeafNodes(ctx context.Context, data []Data) ([]*Node, error) {
 	if len(data) == 0 {
 		return nil, ErrMerkleTreeDataIsNilOrEmpty
 	}
 
 	var (
-		leaves       []*Node
-		isUnevenData = len(data)%2 == 1
+		leaves []*Node
 	)
 
 	// generate bottom leaves
-	// handle use case where there's an uneven nb of leaves (it always goes by pair)
+	// handle use case where there's an uneven nb of leaves (it always goes by group of arity leaves)
 	// perf: better to use make here than using append which doubles the array increasing memory pressure
 	// use allocation here to avoid handling concurrent writes with a lock
-	if isUnevenData {
-		leaves = make([]*Node, len(data)+1)
-	} else {
-		leaves = make([]*Node, len(data))
-	}
+	// the capacity covers the orphan and padding leaves as there cannot be more than with all the data
+	leaves = make([]*Node, len(data), mt.nbLeaves(len(data)))
 
 	// create leaves
 	errs, _ := errgroup.WithContext(ctx)
@@ -129,7 +302,7 @@ func (mt *MerkleTree) generateLeafNodes(ctx context.Context, data []Data) ([]*No
 		i := _i
 
 		errs.Go(func() error {
-			leaf, err := NewLeaf(mt.Hasher, data[i])
+			leaf, err := newLeaf(mt.hashers(), data[i], false)
 			if err != nil {
 				return fmt.Errorf("NewLeaf(data[%d]): %w", i, err)
 			}
@@ -144,86 +317,154 @@ func (mt *MerkleTree) generateLeafNodes(ctx context.Context, data []Data) ([]*No
 		return nil, err
 	}
 
-	// create last leaf - duplicate the last leaf to have a even number of leaves in the tree
-	if isUnevenData {
-		d := data[len(data)-1]
-		leaf, err := NewOrphanLeaf(mt.Hasher, d)
-		if err != nil {
-			return nil, err
+	// remove the duplicated leaves once all of them have been hashed so that the result stays deterministic
+	if mt.deduplication() != NODEDUPLICATION {
+		var occurrences []uint32
+		leaves, mt.DroppedLeaves, occurrences = deduplicateLeaves(leaves)
+		if mt.deduplication() == COUNT {
+			if err := mt.countLeaves(ctx, leaves, occurrences); err != nil {
+				return nil, err
+			}
 		}
-		leaves[len(data)] = leaf
 	}
+	nbDataLeaves := len(leaves)
+	leaves = leaves[:mt.nbLeaves(nbDataLeaves)]
 
-	if mt.Hasher.IsSort {
-		sort.Sort(NodeSorter{nodes: leaves})
+	if err := mt.completeLeaves(leaves, nbDataLeaves); err != nil {
+		return nil, err
 	}
 
 	return leaves, nil
 }
 
-// generateParentNodes generates a parent node by pairing two nodes together
+// completeLeaves adds the orphan or padding leaves after the nbDataLeaves first leaves and sorts the leaves if needed
+func (mt *MerkleTree) completeLeaves(leaves []*Node, nbDataLeaves int) error {
+	strategy := mt.oddNodeStrategy()
+
+	// create last leaves - duplicate the last leaf to have a multiple of arity leaves in the tree
+	for i := nbDataLeaves; strategy == DUPLICATE && i < len(leaves); i++ {
+		leaves[i] = leaves[nbDataLeaves-1].orphanCopy()
+	}
+
+	if mt.Hasher.IsSortLeaves {
+		if strategy == PAD {
+			sort.Sort(NodeSorter{nodes: leaves[:nbDataLeaves]})
+		} else {
+			sort.Sort(NodeSorter{nodes: leaves})
+		}
+	}
+
+	// pad the tree with empty leaves up to the next power of arity, after sorting so that they remain at the end
+	for i := nbDataLeaves; strategy == PAD && i < len(leaves); i++ {
+		leaves[i] = newPaddingLeaf(mt.emptyLeafHashes())
+	}
+
+	return nil
+}
+
+// nbLeaves returns the nb of leaves of a tree containing nbDataLeaves data once orphan or padding leaves are added
+func (mt *MerkleTree) nbLeaves(nbDataLeaves int) int {
+	arity := mt.arity()
+	switch mt.oddNodeStrategy() {
+	case DUPLICATE:
+		return nbDataLeaves + (arity-nbDataLeaves%arity)%arity
+	case PAD:
+		return nextPowerOf(arity, nbDataLeaves)
+	}
+	return nbDataLeaves
+}
+
+// generateParentNodes generates a parent node by grouping arity nodes together
+// each level of nodes is kept so that the right edge of the tree can be regenerated when appending leaves
 func (mt *MerkleTree) generateParentNodes(ctx context.Context, leafNodes []*Node) (*Node, error) {
 	if len(leafNodes) == 0 {
 		return nil, ErrMerkleTreeDataIsNilOrEmpty
 	}
 
 	var (
-		nodes   []*Node
-		counter int
-
-		isUnevenNode = len(leafNodes)%2 == 1
+		nodes []*Node
+		arity = mt.arity()
 	)
 
-	// generate a parent node from 2 nodes' hashes
+	// generate a parent node from arity nodes' hashes
 	// once again calculate the array and use allocation instead of append which is prone to error with goroutines
-	if isUnevenNode {
-		nodes = make([]*Node, len(leafNodes)/2+1)
-	} else {
-		nodes = make([]*Node, len(leafNodes)/2)
+	nodes = make([]*Node, (len(leafNodes)+arity-1)/arity)
+
+	if err := mt.generateGroups(ctx, leafNodes, nodes, 0); err != nil {
+		return nil, err
+	}
+	mt.levels = append(mt.levels, nodes)
+
+	// we have calculated the last group available, in sum, the tree root
+	if len(nodes) == 1 {
+		return nodes[0], nil
 	}
 
+	// otherwise let's keep it calculating the parent nodes up to the merkle tree root
+	return mt.generateParentNodes(ctx, nodes)
+}
+
+// generateGroups generates the parent node of each group of arity children starting from the group firstGroup
+// the parent node of the i-th group is placed at the i-th position of nodes
+func (mt *MerkleTree) generateGroups(ctx context.Context, children []*Node, nodes []*Node, firstGroup int) error {
+	arity := mt.arity()
+
 	errs, _ := errgroup.WithContext(ctx)
 	errs.SetLimit(int(mt.MerkleTreeConfig.MaxGoroutine))
-	for _i := 0; _i < len(leafNodes); _i += 2 {
-		left, right := _i, _i+1
-		c := counter
+	for _i := firstGroup * arity; _i < len(children); _i += arity {
+		start, end := _i, _i+arity
+		if end > len(children) {
+			end = len(children)
+		}
+		c := _i / arity
 
 		errs.Go(func() error {
-			// if orphan node, we need to Hash it twice to respect the binary property of the tree
-			if left+1 == len(leafNodes) {
-				right = left
-			}
-
-			// generate parent node Hash
-			node, err := NewParentNode(mt.Hasher, leafNodes[left], leafNodes[right])
+			node, err := mt.generateGroup(children[start:end])
 			if err != nil {
-				return fmt.Errorf("NewParentNode(): %w", err)
+				return err
 			}
-			log.Debugf("new parent: val<%x,%x>=Hash<%x>", leafNodes[left].Hash, leafNodes[right].Hash, node.Hash)
-
-			// refer each leaf to its freshly generated parent node
-			leafNodes[left].Parent = node
-			leafNodes[right].Parent = node
-
 			nodes[c] = node
-
 			return nil
 		})
-		counter++
 	}
 
 	// wait for all the go routines to be done
-	if err := errs.Wait(); err != nil {
-		return nil, err
+	return errs.Wait()
+}
+
+// generateGroup generates the parent node of a group of at most arity children
+// a lone child is returned as is when it is promoted to the next level
+func (mt *MerkleTree) generateGroup(group []*Node) (*Node, error) {
+	arity := mt.arity()
+
+	// a lone node is promoted to the next level unchanged unless the strategy is to duplicate it
+	if len(group) == 1 && mt.oddNodeStrategy() != DUPLICATE {
+		return group[0], nil
 	}
 
-	// we have calculated the last pair available, in sum, the tree root
-	if len(leafNodes) == 2 {
-		return leafNodes[len(leafNodes)-1].Parent, nil
+	// the children are copied as the levels of the tree are modified in place when it is updated, whereas the nodes
+	// are shared with its snapshots
+	// if the group is incomplete, the last node is duplicated to respect the arity property of the tree
+	// unless the strategy is to hash the group as is
+	size := len(group)
+	if mt.oddNodeStrategy() == DUPLICATE {
+		size = arity
 	}
+	_group := make([]*Node, size)
+	copy(_group, group)
+	for i := len(group); i < size; i++ {
+		_group[i] = group[len(group)-1]
+	}
+	group = _group
 
-	// otherwise let's keep it calculating the parent nodes up to the merkle tree root
-	return mt.generateParentNodes(ctx, nodes)
+	// generate parent node Hash
+	node, err := newParentNode(mt.hashers(), group...)
+	if err != nil {
+		return nil, fmt.Errorf("newParentNode(): %w", err)
+	}
+	log.Debugf("new parent: nb children<%d>=Hash<%x>", len(group), node.Hash)
+
+	return node, nil
 }
 
 // Verify verifies if a leaf containing the data passed in parameter is present in the tree
@@ -241,76 +482,199 @@ func (mt *MerkleTree) Verify(context context.Context, data Data) (bool, error) {
 		return false, fmt.Errorf("data.Hasher(): %w", err)
 	}
 
-	for _, leaf := range mt.Leaves {
+	for index, leaf := range mt.Leaves {
 		if !bytes.Equal(leaf.Hash, hash) {
 			continue
 		}
 
-		currentParent := leaf.Parent
-		for currentParent != nil {
-			var (
-				leftNodeHash, rightNodeHash []byte
-			)
+		return mt.verifyPath(mt.Root, len(mt.Leaves), index)
+	}
+	return false, nil
+}
 
-			if leftNodeHash, err = mt.computeNodeHash(currentParent.Left); err != nil {
-				return false, fmt.Errorf("mt.computeNodeHash(currentParent.Left): %w", err)
-			}
+// verifyPath recomputes the hash of each ancestor of the leaf at the index passed in parameter from its children
+// if one hash is different than its parent's, false is returned
+func (c *MerkleTreeConfig) verifyPath(root *Node, nbLeaves, index int) (bool, error) {
+	path, err := c.path(root, nbLeaves, index)
+	if err != nil {
+		return false, err
+	}
+
+	for level := 1; level < len(path); level++ {
+		parent := path[level]
+
+		// a promoted node has not been hashed again
+		if parent == path[level-1] {
+			continue
+		}
 
-			if rightNodeHash, err = mt.computeNodeHash(currentParent.Right); err != nil {
-				return false, fmt.Errorf("mt.computeNodeHash(currentParent.Right): %w", err)
+		childrenHashes := make([][]byte, len(parent.Children))
+		for i, child := range parent.Children {
+			if childrenHashes[i], err = c.computeNodeHash(0, child); err != nil {
+				return false, fmt.Errorf("c.computeNodeHash(parent.Children[%d]): %w", i, err)
 			}
+		}
 
-			if mt.Hasher.Pool == nil {
-				hf := mt.Hasher.Hash.HashFunc()()
-				if _, err = hf.Write(concat(false, mt.Hasher.IsSort, leftNodeHash, rightNodeHash)); err != nil {
-					return false, fmt.Errorf("hf.Write(concat(%x,%x)): %w", leftNodeHash, rightNodeHash, err)
-				}
+		parentHash, err := c.Hasher.hashChildren(childrenHashes...)
+		if err != nil {
+			return false, fmt.Errorf("c.Hasher.hashChildren(): %w", err)
+		}
 
-				if !bytes.Equal(hf.Sum(nil), currentParent.Hash) {
-					return false, nil
-				}
+		if !bytes.Equal(parentHash, parent.Hash) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
 
-				currentParent = currentParent.Parent
-				continue
-			}
+// levelSizes returns the nb of nodes of each level of a tree of nbLeaves leaves, from the leaves up to the root
+// a promoted node is counted at each level it appears at
+func (c *MerkleTreeConfig) levelSizes(nbLeaves int) []int {
+	var (
+		arity = c.arity()
+		sizes = []int{nbLeaves}
+	)
+	for {
+		size := (sizes[len(sizes)-1] + arity - 1) / arity
+		sizes = append(sizes, size)
+		if size <= 1 {
+			return sizes
+		}
+	}
+}
 
-			hf := mt.Hasher.Pool.getHash()
-			defer hf.Close()
+// path returns the nodes from the leaf at the index passed in parameter up to the root of a tree of nbLeaves leaves
+// the tree is walked down from the root so that no node has to refer to its parent, nodes being shared between
+// several versions of a tree, a promoted node appears at several consecutive levels
+func (c *MerkleTreeConfig) path(root *Node, nbLeaves, index int) ([]*Node, error) {
+	if root == nil || index < 0 || index >= nbLeaves {
+		return nil, fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeIndexOutOfRange)
+	}
 
-			if _, err = hf.Write(concat(true, mt.Hasher.IsSort, leftNodeHash, rightNodeHash)); err != nil {
-				return false, fmt.Errorf("hf.Write(concat(%x,%x)): %w", leftNodeHash, rightNodeHash, err)
-			}
+	var (
+		arity     = c.arity()
+		sizes     = c.levelSizes(nbLeaves)
+		positions = make([]int, len(sizes))
+		path      = make([]*Node, len(sizes))
+	)
 
-			if !bytes.Equal(hf.Sum(nil), currentParent.Hash) {
-				return false, nil
-			}
+	// position of the ancestor of the leaf at each level
+	positions[0] = index
+	for level := 1; level < len(sizes); level++ {
+		positions[level] = positions[level-1] / arity
+	}
 
-			currentParent = currentParent.Parent
+	path[len(path)-1] = root
+	for level := len(path) - 1; level > 0; level-- {
+		start, end := positions[level]*arity, positions[level]*arity+arity
+		if end > sizes[level-1] {
+			end = sizes[level-1]
+		}
+
+		// a lone node has been promoted unless it has been duplicated
+		if end-start == 1 && c.oddNodeStrategy() != DUPLICATE {
+			path[level-1] = path[level]
+			continue
 		}
-		return true, nil
+
+		child := positions[level-1] - start
+		if child >= len(path[level].Children) {
+			return nil, fmt.Errorf("level<%d> position<%d>: %w", level-1, positions[level-1], ErrMerkleTreeStructureIsWrong)
+		}
+		path[level-1] = path[level].Children[child]
 	}
-	return false, nil
+	return path, nil
 }
 
 // computeNodeHash firstly determines if the node is a leaf or a parent node
-// a leaf is only calculate such as H(data) whereas a parent node is calculated such as H(Hl(data)+Hr(data))
-func (mt *MerkleTree) computeNodeHash(n *Node) ([]byte, error) {
+// a leaf is only calculate such as H(data) whereas a parent node is calculated such as H(H1(data)+...+Hk(data))
+// i is the index of the hasher to use within the tree hashers
+func (c *MerkleTreeConfig) computeNodeHash(i int, n *Node) ([]byte, error) {
+	h := c.hashers()[i]
 	if n.isLeaf() {
-		return n.Data.Hash(mt.Hasher)
+		// padding and tombstone leaves do not contain any data, their hash is used as is
+		if n.Data == nil {
+			return n.hashAt(i), nil
+		}
+		return n.Data.Hash(h)
+	}
+	childrenHashes := make([][]byte, len(n.Children))
+	for j, child := range n.Children {
+		childrenHashes[j] = child.hashAt(i)
 	}
-	if mt.Hasher.Pool == nil {
-		hf := mt.Hasher.Hash.HashFunc()()
-		if _, err := hf.Write(concat(false, mt.Hasher.IsSort, n.Left.Hash, n.Right.Hash)); err != nil {
-			return nil, fmt.Errorf("hf.Write(concat(%x,%x)): %w", n.Left.Hash, n.Right.Hash, err)
+	return h.hashChildren(childrenHashes...)
+}
+
+// deduplication returns the deduplication mode of the tree, NODEDUPLICATION being the default one
+func (c *MerkleTreeConfig) deduplication() DeduplicationMode {
+	if c.Deduplication == "" {
+		return NODEDUPLICATION
+	}
+	return c.Deduplication
+}
+
+// arity returns the number of children of each parent node, DefaultArity if none has been configured
+func (c *MerkleTreeConfig) arity() int {
+	if c.Arity == 0 {
+		return DefaultArity
+	}
+	return int(c.Arity)
+}
+
+// oddNodeStrategy returns the strategy used to handle unpaired nodes, DUPLICATE being the default one
+func (c *MerkleTreeConfig) oddNodeStrategy() OddNodeStrategy {
+	if c.OddNodeStrategy == "" {
+		return DUPLICATE
+	}
+	return c.OddNodeStrategy
+}
+
+// emptyLeafHashes returns the hash of an empty leaf for each hasher of the tree
+// a digest full of zeros is used if none has been configured for the algorithm
+func (c *MerkleTreeConfig) emptyLeafHashes() [][]byte {
+	hashers := c.hashers()
+	hashes := make([][]byte, len(hashers))
+	for i, h := range hashers {
+		if emptyLeafHash, ok := c.EmptyLeafHashes[h.Hash]; ok {
+			hashes[i] = emptyLeafHash
+			continue
+		}
+		hashes[i] = make([]byte, h.Hash.Size())
+	}
+	return hashes
+}
+
+// hashers returns all the hashers the tree is built with, the main hasher being the first one
+func (c *MerkleTreeConfig) hashers() []*Hasher {
+	if len(c.Hashers) == 0 {
+		return []*Hasher{c.Hasher}
+	}
+	return c.Hashers
+}
+
+// hasherIndex returns the position of the hash algorithm within the tree hashers
+func (c *MerkleTreeConfig) hasherIndex(hash Hash) (int, error) {
+	for i, h := range c.hashers() {
+		if h.Hash == hash {
+			return i, nil
 		}
-		return hf.Sum(nil), nil
 	}
+	return 0, fmt.Errorf("hash<%s>: %w", hash, ErrMerkleTreeHashNotBuilt)
+}
 
-	h := mt.Hasher.Pool.getHash()
-	defer h.Close()
+// RootHash returns the merkle root computed with the hash algorithm passed in parameter
+func (mt *MerkleTree) RootHash(hash Hash) ([]byte, error) {
+	return mt.rootHash(mt.Root, hash)
+}
 
-	if _, err := h.Write(concat(true, mt.Hasher.IsSort, n.Left.Hash, n.Right.Hash)); err != nil {
-		return nil, fmt.Errorf("hf.Write(concat(%x,%x)): %w", n.Left.Hash, n.Right.Hash, err)
+// rootHash returns the hash of the root passed in parameter computed with the hash algorithm passed in parameter
+func (c *MerkleTreeConfig) rootHash(root *Node, hash Hash) ([]byte, error) {
+	if root == nil {
+		return nil, ErrMerkleTreeDataIsNilOrEmpty
+	}
+	i, err := c.hasherIndex(hash)
+	if err != nil {
+		return nil, err
 	}
-	return h.Sum(nil), nil
+	return root.hashAt(i), nil
 }
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -425,3 +426,410 @@ func verify(b *testing.B, n int) {
 		assert.Equal(b, true, isTrue)
 	}
 }
+
+func TestMerkleTreeBuilder_WithHashers(t *testing.T) {
+	sha256Hasher := &Hasher{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())}
+	sha384Hasher := &Hasher{Hash: SHA384, Pool: NewHashPool(SHA384.Hash())}
+
+	mtSHA256, err := NewMerkleTreeBuilder().WithHasher(sha256Hasher).WithMaxGoroutine(1000).Build(ctx, dataUnEvenNbNodes)
+	assert.NoError(t, err)
+	mtSHA384, err := NewMerkleTreeBuilder().WithHasher(sha384Hasher).WithMaxGoroutine(1000).Build(ctx, dataUnEvenNbNodes)
+	assert.NoError(t, err)
+
+	sortedSHA256Hasher := &Hasher{Hash: SHA256, IsSortPairs: true}
+	sortedSHA384Hasher := &Hasher{Hash: SHA384, IsSortPairs: true}
+	mtSortedSHA256, err := NewMerkleTreeBuilder().WithHasher(sortedSHA256Hasher).WithMaxGoroutine(1000).Build(ctx, dataUnEvenNbNodes)
+	assert.NoError(t, err)
+	mtSortedSHA384, err := NewMerkleTreeBuilder().WithHasher(sortedSHA384Hasher).WithMaxGoroutine(1000).Build(ctx, dataUnEvenNbNodes)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		hashers []*Hasher
+		want    map[Hash][]byte
+		err     error
+	}{
+		{
+			name:    "build merkle tree with no hashers should return error",
+			hashers: nil,
+			err:     ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:    "build merkle tree with the same algorithm twice should return error",
+			hashers: []*Hasher{sha256Hasher, {Hash: SHA256}},
+			err:     ErrMerkleTreeConfigHasherIsDuplicated,
+		},
+		{
+			name:    "build merkle tree with several algorithms should return the same roots as separate builds",
+			hashers: []*Hasher{sha256Hasher, sha384Hasher},
+			want: map[Hash][]byte{
+				SHA256: mtSHA256.Root.Hash,
+				SHA384: mtSHA384.Root.Hash,
+			},
+			err: nil,
+		},
+		{
+			name:    "build merkle tree with several algorithms and sorted pairs should return the same roots as separate builds",
+			hashers: []*Hasher{sortedSHA256Hasher, sortedSHA384Hasher},
+			want: map[Hash][]byte{
+				SHA256: mtSortedSHA256.Root.Hash,
+				SHA384: mtSortedSHA384.Root.Hash,
+			},
+			err: nil,
+		},
+		{
+			name:    "build merkle tree with several algorithms and sorted leaves should return error",
+			hashers: []*Hasher{sha256Hasher, {Hash: SHA384, IsSortLeaves: true}},
+			err:     ErrMerkleTreeConfigSortLeavesIsWrong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt, err := NewMerkleTreeBuilder().WithHashers(tt.hashers...).WithMaxGoroutine(1000).Build(ctx, dataUnEvenNbNodes)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			for hash, want := range tt.want {
+				got, err := mt.RootHash(hash)
+				assert.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+func TestMerkleTreeBuilder_WithHasherDigestLength(t *testing.T) {
+	tests := []struct {
+		name         string
+		hasher       *Hasher
+		digestLength uint32
+		err          error
+	}{
+		{
+			name:   "build merkle tree with a hasher digest length lower than the minimum should return error",
+			hasher: &Hasher{Hash: SHA256, DigestLength: 4},
+			err:    ErrHashDigestLengthTooShort,
+		},
+		{
+			name:   "build merkle tree with a hasher digest length greater than the digest size should return error",
+			hasher: &Hasher{Hash: SHA256, DigestLength: 33},
+			err:    ErrHashDigestLengthTooLong,
+		},
+		{
+			name:         "build merkle tree with a hasher digest length different from the tree one should return error",
+			hasher:       &Hasher{Hash: SHA256, DigestLength: 20},
+			digestLength: 24,
+			err:          ErrMerkleTreeConfigDigestLengthIsWrong,
+		},
+		{
+			name:         "build merkle tree with the same hasher and tree digest lengths should truncate parent nodes",
+			hasher:       &Hasher{Hash: SHA256, DigestLength: 20},
+			digestLength: 20,
+		},
+		{
+			name:   "build merkle tree with a hasher digest length should truncate parent nodes and verify",
+			hasher: &Hasher{Hash: SHA256, DigestLength: 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt, err := NewMerkleTreeBuilder().
+				WithHasher(tt.hasher).
+				WithMaxGoroutine(configWithHashPool.MaxGoroutine).
+				WithDigestLength(tt.digestLength).
+				Build(ctx, dataUnEvenNbNodes)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Len(t, mt.Root.Hash, int(tt.hasher.DigestLength))
+
+			p, err := mt.Proof(dataUnEvenNbNodes[1], SHA256)
+			assert.NoError(t, err)
+			isVerified, err := p.Verify(mt.Root.Hash)
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+		})
+	}
+}
+
+func TestMerkleTreeBuilder_WithDigestLength(t *testing.T) {
+	tests := []struct {
+		name         string
+		digestLength uint32
+		err          error
+	}{
+		{
+			name:         "build merkle tree with a digest length lower than the minimum should return error",
+			digestLength: MinDigestLength - 1,
+			err:          ErrHashDigestLengthTooShort,
+		},
+		{
+			name:         "build merkle tree with a digest length greater than the digest size should return error",
+			digestLength: 33,
+			err:          ErrHashDigestLengthTooLong,
+		},
+		{
+			name:         "build merkle tree with a digest length of 20 should truncate parent nodes and verify",
+			digestLength: 20,
+			err:          nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt, err := NewMerkleTreeBuilder().
+				WithHasher(configWithHashPool.Hasher).
+				WithMaxGoroutine(configWithHashPool.MaxGoroutine).
+				WithDigestLength(tt.digestLength).
+				Build(ctx, dataUnEvenNbNodes)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Len(t, mt.Root.Hash, int(tt.digestLength))
+			assert.Len(t, mt.Leaves[0].Hash, SHA256.Size())
+			assert.Equal(t, uint32(0), configWithHashPool.Hasher.DigestLength)
+
+			isVerified, err := mt.Verify(ctx, dataUnEvenNbNodes[1])
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+
+			p, err := mt.Proof(dataUnEvenNbNodes[1], SHA256)
+			assert.NoError(t, err)
+			isVerified, err = p.Verify(mt.Root.Hash)
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+		})
+	}
+}
+
+func TestMerkleTreeBuilder_WithOddNodeStrategy(t *testing.T) {
+	h := configWithNoHashPool.Hasher
+	leaf := func(d Data) []byte {
+		b, _ := d.Hash(h)
+		return b
+	}
+	pair := func(left, right []byte) []byte {
+		b, _ := h.hashChildren(left, right)
+		return b
+	}
+	a, b, c, d, e := leaf(dataUnEvenNbNodes[0]), leaf(dataUnEvenNbNodes[1]), leaf(dataUnEvenNbNodes[2]),
+		leaf(dataUnEvenNbNodes[3]), leaf(dataUnEvenNbNodes[4])
+	emptyLeafHash := make([]byte, SHA256.Size())
+	emptyLeafHash[0] = 1
+
+	tests := []struct {
+		name          string
+		strategy      OddNodeStrategy
+		emptyLeafHash []byte
+		want          []byte
+		err           error
+	}{
+		{
+			name:     "build merkle tree with an unknown strategy should return error",
+			strategy: OddNodeStrategy("unknown"),
+			err:      ErrOddNodeStrategyNotAllowed,
+		},
+		{
+			name:     "build merkle tree with duplicate strategy should duplicate the unpaired nodes",
+			strategy: DUPLICATE,
+			want:     pair(pair(pair(a, b), pair(c, d)), pair(pair(e, e), pair(e, e))),
+		},
+		{
+			name:     "build merkle tree with promote strategy should move the unpaired nodes up",
+			strategy: PROMOTE,
+			want:     pair(pair(pair(a, b), pair(c, d)), e),
+		},
+		{
+			name:          "build merkle tree with pad strategy should pad the leaves with empty leaves",
+			strategy:      PAD,
+			emptyLeafHash: emptyLeafHash,
+			want:          pair(pair(pair(a, b), pair(c, d)), pair(pair(e, emptyLeafHash), pair(emptyLeafHash, emptyLeafHash))),
+		},
+		{
+			name:          "build merkle tree with an empty leaf hash of the wrong size should return error",
+			strategy:      PAD,
+			emptyLeafHash: emptyLeafHash[1:],
+			err:           ErrMerkleTreeConfigEmptyLeafHashIsWrong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewMerkleTreeBuilder().WithHasher(h).WithMaxGoroutine(1000).WithOddNodeStrategy(tt.strategy)
+			if tt.emptyLeafHash != nil {
+				builder.WithEmptyLeafHash(SHA256, tt.emptyLeafHash)
+			}
+			mt, err := builder.Build(ctx, dataUnEvenNbNodes)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, mt.Root.Hash)
+
+			for _, data := range dataUnEvenNbNodes {
+				isVerified, err := mt.Verify(ctx, data)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+
+				p, err := mt.Proof(data, SHA256)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.strategy, p.OddNodeStrategy)
+				isVerified, err = p.Verify(mt.Root.Hash)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		})
+	}
+}
+
+func TestMerkleTreeBuilder_Build_Defaults(t *testing.T) {
+	builder := NewMerkleTreeBuilder().WithHasher(configWithNoHashPool.Hasher).WithMaxGoroutine(1000)
+	mt, err := builder.Build(ctx, dataUnEvenNbNodes)
+	assert.NoError(t, err)
+
+	// the defaults should be applied to the tree and not written back into the builder
+	assert.Equal(t, uint32(DefaultArity), mt.Arity)
+	assert.Zero(t, builder.config.Arity)
+	assert.Equal(t, DUPLICATE, mt.OddNodeStrategy)
+	assert.Empty(t, builder.config.OddNodeStrategy)
+	assert.Equal(t, NODEDUPLICATION, mt.Deduplication)
+	assert.Empty(t, builder.config.Deduplication)
+	assert.Equal(t, TOMBSTONE, mt.DeletionMode)
+	assert.Empty(t, builder.config.DeletionMode)
+}
+
+func TestMerkleTreeBuilder_SortModes(t *testing.T) {
+	tests := []struct {
+		name         string
+		isSortLeaves bool
+		isSortPairs  bool
+	}{
+		{name: "build merkle tree without sorting should keep insertion order"},
+		{name: "build merkle tree sorting leaves should reorder leaves", isSortLeaves: true},
+		{name: "build merkle tree sorting pairs should keep insertion order", isSortPairs: true},
+		{name: "build merkle tree sorting leaves and pairs should reorder leaves", isSortLeaves: true, isSortPairs: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Hasher{Hash: SHA256, IsSortLeaves: tt.isSortLeaves, IsSortPairs: tt.isSortPairs}
+			mt, err := NewMerkleTreeBuilder().WithHasher(h).WithMaxGoroutine(1000).Build(ctx, dataEvenNbNodes)
+			assert.NoError(t, err)
+
+			isInsertionOrder := true
+			for i, leaf := range mt.Leaves {
+				isInsertionOrder = isInsertionOrder && leaf.Data.String() == dataEvenNbNodes[i].String()
+			}
+			assert.Equal(t, !tt.isSortLeaves, isInsertionOrder)
+
+			for _, data := range dataEvenNbNodes {
+				p, err := mt.Proof(data, SHA256)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.isSortPairs, p.IsSortPairs)
+				isVerified, err := p.Verify(mt.Root.Hash)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		})
+	}
+}
+
+func TestMerkleTreeBuilder_WithArity(t *testing.T) {
+	h := configWithNoHashPool.Hasher
+	leaf := func(d Data) []byte {
+		b, _ := d.Hash(h)
+		return b
+	}
+	group := func(children ...[]byte) []byte {
+		b, _ := h.hashChildren(children...)
+		return b
+	}
+	a, b, c, d, e := leaf(dataUnEvenNbNodes[0]), leaf(dataUnEvenNbNodes[1]), leaf(dataUnEvenNbNodes[2]),
+		leaf(dataUnEvenNbNodes[3]), leaf(dataUnEvenNbNodes[4])
+	z := make([]byte, SHA256.Size())
+
+	tests := []struct {
+		name     string
+		arity    uint32
+		strategy OddNodeStrategy
+		want     []byte
+		err      error
+	}{
+		{
+			name:  "build merkle tree with an arity of 1 should return error",
+			arity: 1,
+			err:   ErrMerkleTreeConfigArityIsWrong,
+		},
+		{
+			name:  "build merkle tree with an arity greater than the max arity should return error",
+			arity: MaxArity + 1,
+			err:   ErrMerkleTreeConfigArityIsWrong,
+		},
+		{
+			name:     "build 4-ary merkle tree with duplicate strategy should fill groups with their last node",
+			arity:    4,
+			strategy: DUPLICATE,
+			want:     group(group(a, b, c, d), group(e, e, e, e), group(e, e, e, e), group(e, e, e, e)),
+		},
+		{
+			name:     "build 4-ary merkle tree with promote strategy should hash incomplete groups as is",
+			arity:    4,
+			strategy: PROMOTE,
+			want:     group(group(a, b, c, d), e),
+		},
+		{
+			name:     "build 4-ary merkle tree with pad strategy should pad up to the next power of 4",
+			arity:    4,
+			strategy: PAD,
+			want:     group(group(a, b, c, d), group(e, z, z, z), group(z, z, z, z), group(z, z, z, z)),
+		},
+		{
+			name:     "build 16-ary merkle tree should hash all the leaves together",
+			arity:    16,
+			strategy: PROMOTE,
+			want:     group(a, b, c, d, e),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt, err := NewMerkleTreeBuilder().
+				WithHasher(h).
+				WithMaxGoroutine(1000).
+				WithArity(tt.arity).
+				WithOddNodeStrategy(tt.strategy).
+				Build(ctx, dataUnEvenNbNodes)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, mt.Root.Hash)
+
+			for _, data := range dataUnEvenNbNodes {
+				isVerified, err := mt.Verify(ctx, data)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+
+				p, err := mt.Proof(data, SHA256)
+				assert.NoError(t, err)
+				for _, step := range p.Steps {
+					assert.Less(t, len(step.Siblings), int(tt.arity))
+				}
+				isVerified, err = p.Verify(mt.Root.Hash)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		})
+	}
+}
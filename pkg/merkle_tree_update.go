@@ -0,0 +1,354 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"sort"
+)
+
+var (
+	ErrMerkleTreeIndexOutOfRange   = errors.New("the merkle tree does not contain a leaf at this index")
+	ErrMerkleTreeLeafIsNotData     = errors.New("the merkle tree leaf is an orphan or a padding leaf and cannot be modified")
+	ErrMerkleTreeLeafOrderIsBroken = errors.New("the merkle tree leaf would move position as the leaves are sorted")
+	ErrMerkleTreeAppendNotAllowed  = errors.New("the merkle tree leaves cannot be appended when they are sorted or deduplicated")
+)
+
+// Update replaces the data of the leaf at the index passed in parameter
+// only the leaf and its ancestors are rehashed up to the root, i.e. O(log n) hashes
+// nodes are never modified but replaced so that the snapshots of the tree keep sharing them
+// for sorted leaves, an error is returned if the new data would move the leaf position
+func (mt *MerkleTree) Update(index int, data Data) error {
+	if index < 0 || index >= len(mt.Leaves) {
+		return fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeIndexOutOfRange)
+	}
+
+	leaf := mt.Leaves[index]
+	if leaf.isOrphan {
+		return fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeLeafIsNotData)
+	}
+
+	updated, err := newLeaf(mt.hashers(), data, false)
+	if err != nil {
+		return fmt.Errorf("newLeaf(): %w", err)
+	}
+
+	// the orphan leaves duplicating the leaf have to be updated as well
+	first, last := mt.orphanGroup(index)
+
+	if mt.Hasher.IsSortLeaves && !mt.isInOrder(first, last, updated.Hash) {
+		return fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeLeafOrderIsBroken)
+	}
+
+	return mt.regenerateDirtyPaths(context.Background(), mt.replaceLeafGroup(index, first, last, updated))
+}
+
+// ApplyBatch replaces the data of the leaves at the indices passed in parameter in a single pass
+// the dirty ancestors are marked level by level and each of them is rehashed exactly once, the nodes of a same level
+// being rehashed concurrently
+// for sorted leaves, an error is returned if the new data would break the order of the leaves
+func (mt *MerkleTree) ApplyBatch(ctx context.Context, updates map[int]Data) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	indices := make([]int, 0, len(updates))
+	for index := range updates {
+		if index < 0 || index >= len(mt.Leaves) {
+			return fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeIndexOutOfRange)
+		}
+		if mt.Leaves[index].isOrphan {
+			return fmt.Errorf("index<%d>: %w", index, ErrMerkleTreeLeafIsNotData)
+		}
+		indices = append(indices, index)
+	}
+
+	// create the updated leaves
+	updated := make([]*Node, len(indices))
+	errs, _ := errgroup.WithContext(ctx)
+	errs.SetLimit(int(mt.MerkleTreeConfig.MaxGoroutine))
+	for _i := 0; _i < len(indices); _i++ {
+		// i can change in the below go routine, allocates a local scope via i
+		i := _i
+
+		errs.Go(func() error {
+			leaf, err := newLeaf(mt.hashers(), updates[indices[i]], false)
+			if err != nil {
+				return fmt.Errorf("NewLeaf(data[%d]): %w", indices[i], err)
+			}
+			updated[i] = leaf
+			return nil
+		})
+	}
+
+	// wait for all the go routines to be done
+	if err := errs.Wait(); err != nil {
+		return err
+	}
+
+	// the orphan leaves duplicating the leaves have to be updated as well
+	// the groups are identified by hash so they have to be found before any modification
+	groups := make([][2]int, len(indices))
+	for i, index := range indices {
+		first, last := mt.orphanGroup(index)
+		groups[i] = [2]int{first, last}
+	}
+
+	if mt.Hasher.IsSortLeaves && !mt.isBatchInOrder(groups, updated) {
+		return ErrMerkleTreeLeafOrderIsBroken
+	}
+
+	dirty := make([]int, 0, len(indices))
+	for i, group := range groups {
+		dirty = append(dirty, mt.replaceLeafGroup(indices[i], group[0], group[1], updated[i])...)
+	}
+
+	return mt.regenerateDirtyPaths(ctx, dirty)
+}
+
+// Append appends the data as new leaves at the end of the tree
+// the orphan or padding leaves are replaced and only the right edge of the tree is rehashed, i.e. the groups containing
+// a new leaf and their ancestors
+// sorted or deduplicated trees are not supported as a new leaf could move or drop existing ones
+func (mt *MerkleTree) Append(ctx context.Context, data ...Data) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if mt.Hasher.IsSortLeaves || mt.deduplication() != NODEDUPLICATION {
+		return ErrMerkleTreeAppendNotAllowed
+	}
+
+	if len(mt.Leaves) == 0 {
+		return ErrMerkleTreeDataIsNilOrEmpty
+	}
+
+	// the orphan and padding leaves are always at the end when the leaves are not sorted
+	nbDataLeaves := len(mt.Leaves)
+	for nbDataLeaves > 0 && mt.Leaves[nbDataLeaves-1].isOrphan {
+		nbDataLeaves--
+	}
+
+	// perf: allocate the final array once with the orphan and padding leaves
+	leaves := make([]*Node, mt.nbLeaves(nbDataLeaves+len(data)))
+	copy(leaves, mt.Leaves[:nbDataLeaves])
+
+	// create new leaves
+	errs, _ := errgroup.WithContext(ctx)
+	errs.SetLimit(int(mt.MerkleTreeConfig.MaxGoroutine))
+	for _i := 0; _i < len(data); _i++ {
+		// i can change in the below go routine, allocates a local scope via i
+		i := _i
+
+		errs.Go(func() error {
+			leaf, err := newLeaf(mt.hashers(), data[i], false)
+			if err != nil {
+				return fmt.Errorf("NewLeaf(data[%d]): %w", i, err)
+			}
+			log.Debugf("new leaf: val<%s>=Hash<%x>", leaf.Data, leaf.Hash)
+			leaves[nbDataLeaves+i] = leaf
+			return nil
+		})
+	}
+
+	// wait for all the go routines to be done
+	if err := errs.Wait(); err != nil {
+		return err
+	}
+
+	if err := mt.completeLeaves(leaves, nbDataLeaves+len(data)); err != nil {
+		return fmt.Errorf("mt.completeLeaves(): %w", err)
+	}
+
+	mt.Leaves = leaves
+	root, err := mt.regenerateParentNodes(ctx, nbDataLeaves)
+	if err != nil {
+		return fmt.Errorf("mt.regenerateParentNodes(): %w", err)
+	}
+	mt.setRoot(root, indexRange(nbDataLeaves, len(leaves)))
+
+	return nil
+}
+
+// regenerateParentNodes regenerates the parent nodes of the groups containing a leaf from the index firstDirtyLeaf
+// the parent nodes of the groups on the left of the dirty ones are kept as is, level by level up to the root
+func (mt *MerkleTree) regenerateParentNodes(ctx context.Context, firstDirtyLeaf int) (*Node, error) {
+	var (
+		arity      = mt.arity()
+		firstDirty = firstDirtyLeaf
+	)
+
+	mt.levels[0] = mt.Leaves
+	for level := 0; ; level++ {
+		var (
+			children   = mt.levels[level]
+			nodes      = make([]*Node, (len(children)+arity-1)/arity)
+			firstGroup = firstDirty / arity
+		)
+
+		// keep the parent nodes of the clean groups
+		if level+1 < len(mt.levels) {
+			copy(nodes[:firstGroup], mt.levels[level+1])
+		}
+
+		if err := mt.generateGroups(ctx, children, nodes, firstGroup); err != nil {
+			return nil, err
+		}
+
+		if level+1 < len(mt.levels) {
+			mt.levels[level+1] = nodes
+		} else {
+			mt.levels = append(mt.levels, nodes)
+		}
+
+		// we have calculated the last group available, in sum, the tree root
+		if len(nodes) == 1 {
+			mt.levels = mt.levels[:level+2]
+			return nodes[0], nil
+		}
+		firstDirty = firstGroup
+	}
+}
+
+// orphanGroup returns the range of leaves made of the leaf at the index passed in parameter and its orphan copies
+// orphan copies are always next to the leaf they duplicate, sorted or not
+func (mt *MerkleTree) orphanGroup(index int) (int, int) {
+	first, last := index, index
+	for first > 0 && mt.isOrphanCopy(mt.Leaves[first-1], mt.Leaves[index]) {
+		first--
+	}
+	for last < len(mt.Leaves)-1 && mt.isOrphanCopy(mt.Leaves[last+1], mt.Leaves[index]) {
+		last++
+	}
+	return first, last
+}
+
+// isOrphanCopy checks if a leaf is an orphan duplicating the leaf passed in parameter
+func (mt *MerkleTree) isOrphanCopy(n, leaf *Node) bool {
+	return n.isOrphan && !n.isPadding() && bytes.Equal(n.Hash, leaf.Hash)
+}
+
+// isInOrder checks if a leaf hash can be placed between the leaves surrounding the range passed in parameter
+// padding leaves are not taken into account as they always remain at the end of the tree
+func (mt *MerkleTree) isInOrder(first, last int, hash []byte) bool {
+	if first > 0 && bytes.Compare(mt.Leaves[first-1].Hash, hash) == 1 {
+		return false
+	}
+	if last < len(mt.Leaves)-1 {
+		next := mt.Leaves[last+1]
+		if next.Data != nil && bytes.Compare(hash, next.Hash) == 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// isBatchInOrder checks if the leaves remain sorted once the groups passed in parameter are replaced by the hashes of the
+// updated leaves, padding and deleted leaves are not taken into account
+func (mt *MerkleTree) isBatchInOrder(groups [][2]int, updated []*Node) bool {
+	hashes := make([][]byte, len(mt.Leaves))
+	for i, leaf := range mt.Leaves {
+		if leaf.Data != nil {
+			hashes[i] = leaf.Hash
+		}
+	}
+	for i, group := range groups {
+		for j := group[0]; j <= group[1]; j++ {
+			hashes[j] = updated[i].Hash
+		}
+	}
+
+	var previous []byte
+	for _, hash := range hashes {
+		if hash == nil {
+			continue
+		}
+		if previous != nil && bytes.Compare(previous, hash) == 1 {
+			return false
+		}
+		previous = hash
+	}
+	return true
+}
+
+// replaceLeafGroup replaces the leaves of the range passed in parameter by the leaf, the leaf being placed at the index
+// passed in parameter and its orphan copies around it, the indices of the replaced leaves are returned
+func (mt *MerkleTree) replaceLeafGroup(index, first, last int, leaf *Node) []int {
+	dirty := make([]int, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		if i == index {
+			mt.Leaves[i] = leaf
+		} else {
+			mt.Leaves[i] = leaf.orphanCopy()
+		}
+		dirty = append(dirty, i)
+	}
+	return dirty
+}
+
+// regenerateDirtyPaths regenerates the parent nodes of the groups containing a dirty node level by level up to the root
+// each dirty ancestor is regenerated exactly once, the groups of a same level being regenerated concurrently
+// parent nodes are never modified but replaced so that the snapshots of the tree keep sharing the previous ones
+func (mt *MerkleTree) regenerateDirtyPaths(ctx context.Context, dirty []int) error {
+	arity := mt.arity()
+
+	sort.Ints(dirty)
+	indices := dirty
+
+	mt.levels[0] = mt.Leaves
+	for level := 0; level+1 < len(mt.levels); level++ {
+		var (
+			children = mt.levels[level]
+			nodes    = mt.levels[level+1]
+			groups   = make([]int, 0, len(dirty))
+		)
+
+		// the dirty indices are sorted so that the groups of a same parent are next to each other
+		for _, i := range dirty {
+			if len(groups) == 0 || groups[len(groups)-1] != i/arity {
+				groups = append(groups, i/arity)
+			}
+		}
+
+		errs, _ := errgroup.WithContext(ctx)
+		errs.SetLimit(int(mt.MerkleTreeConfig.MaxGoroutine))
+		for _, _c := range groups {
+			// c can change in the below go routine, allocates a local scope via c
+			c := _c
+
+			errs.Go(func() error {
+				start, end := c*arity, c*arity+arity
+				if end > len(children) {
+					end = len(children)
+				}
+				node, err := mt.generateGroup(children[start:end])
+				if err != nil {
+					return err
+				}
+				nodes[c] = node
+				return nil
+			})
+		}
+
+		// wait for all the go routines to be done
+		if err := errs.Wait(); err != nil {
+			return err
+		}
+		dirty = groups
+	}
+
+	mt.setRoot(mt.levels[len(mt.levels)-1][0], indices)
+	return nil
+}
+
+// indexRange returns the indices from start included to end excluded
+func indexRange(start, end int) []int {
+	indices := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		indices = append(indices, i)
+	}
+	return indices
+}
@@ -0,0 +1,332 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// buildTree builds a tree with the hasher and the options of the strategy passed in parameter
+// opts sets the other options of the builder, e.g. the deletion mode
+func buildTree(t *testing.T, h *Hasher, arity uint32, strategy OddNodeStrategy, data []Data, opts ...func(b *MerkleTreeBuilder)) *MerkleTree {
+	builder := NewMerkleTreeBuilder().
+		WithHasher(h).
+		WithMaxGoroutine(1000).
+		WithArity(arity).
+		WithOddNodeStrategy(strategy)
+	for _, opt := range opts {
+		opt(builder)
+	}
+	mt, err := builder.Build(ctx, data)
+	assert.NoError(t, err)
+	return mt
+}
+
+// leavesData returns the data of the leaves in the order of the leaves, orphan and padding leaves excluded
+func leavesData(mt *MerkleTree) []Data {
+	var data []Data
+	for _, leaf := range mt.Leaves {
+		if !leaf.isOrphan {
+			data = append(data, leaf.Data)
+		}
+	}
+	return data
+}
+
+func TestMerkleTree_Update(t *testing.T) {
+	type args struct {
+		index int
+		data  Data
+	}
+	tests := []struct {
+		name     string
+		hasher   *Hasher
+		arity    uint32
+		strategy OddNodeStrategy
+		args     args
+		err      error
+	}{
+		{
+			name:     "update a leaf at an index out of range should return error",
+			hasher:   configWithHashPool.Hasher,
+			strategy: DUPLICATE,
+			args:     args{index: 6, data: StringData{Value: "updated"}},
+			err:      ErrMerkleTreeIndexOutOfRange,
+		},
+		{
+			name:     "update an orphan leaf should return error",
+			hasher:   configWithHashPool.Hasher,
+			strategy: DUPLICATE,
+			args:     args{index: 5, data: StringData{Value: "updated"}},
+			err:      ErrMerkleTreeLeafIsNotData,
+		},
+		{
+			name:     "update a leaf should return the same root as a rebuilt tree",
+			hasher:   configWithHashPool.Hasher,
+			strategy: DUPLICATE,
+			args:     args{index: 1, data: StringData{Value: "updated"}},
+		},
+		{
+			name:     "update a duplicated leaf should update its orphan copies",
+			hasher:   configWithNoHashPool.Hasher,
+			strategy: DUPLICATE,
+			args:     args{index: 4, data: StringData{Value: "updated"}},
+		},
+		{
+			name:     "update a promoted leaf of a 4-ary tree should return the same root as a rebuilt tree",
+			hasher:   configWithHashPool.Hasher,
+			arity:    4,
+			strategy: PROMOTE,
+			args:     args{index: 4, data: StringData{Value: "updated"}},
+		},
+		{
+			name:     "update a leaf of a padded tree should return the same root as a rebuilt tree",
+			hasher:   configWithHashPool.Hasher,
+			strategy: PAD,
+			args:     args{index: 2, data: StringData{Value: "updated"}},
+		},
+		{
+			name:     "update a sorted leaf keeping its position should return the same root as a rebuilt tree",
+			hasher:   &Hasher{Hash: SHA256, IsSortLeaves: true},
+			strategy: PROMOTE,
+			args:     args{index: 0, data: StringData{Value: "u3"}},
+		},
+		{
+			name:     "update a sorted leaf moving its position should return error",
+			hasher:   &Hasher{Hash: SHA256, IsSortLeaves: true},
+			strategy: PROMOTE,
+			args:     args{index: 0, data: StringData{Value: "u1"}},
+			err:      ErrMerkleTreeLeafOrderIsBroken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := buildTree(t, tt.hasher, tt.arity, tt.strategy, dataUnEvenNbNodes)
+			data := leavesData(mt)
+
+			err := mt.Update(tt.args.index, tt.args.data)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Update() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			data[tt.args.index] = tt.args.data
+			expected := buildTree(t, tt.hasher, tt.arity, tt.strategy, data)
+			assert.Equal(t, expected.Root.Hash, mt.Root.Hash)
+
+			isVerified, err := mt.Verify(ctx, tt.args.data)
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+		})
+	}
+}
+
+func TestMerkleTree_Append(t *testing.T) {
+	data := make([]Data, 23)
+	for i := range data {
+		data[i] = StringData{Value: fmt.Sprintf("value%d", i)}
+	}
+
+	tests := []struct {
+		name     string
+		hasher   *Hasher
+		arity    uint32
+		strategy OddNodeStrategy
+		nbBuilt  int
+		batch    int
+		err      error
+	}{
+		{
+			name:     "append leaves to a tree with sorted leaves should return error",
+			hasher:   &Hasher{Hash: SHA256, IsSortLeaves: true},
+			strategy: DUPLICATE,
+			nbBuilt:  5,
+			batch:    1,
+			err:      ErrMerkleTreeAppendNotAllowed,
+		},
+		{
+			name:     "append leaves one by one with duplicate strategy should replace the orphan leaves",
+			hasher:   configWithHashPool.Hasher,
+			strategy: DUPLICATE,
+			nbBuilt:  1,
+			batch:    1,
+		},
+		{
+			name:     "append leaves by batch to a 4-ary tree with duplicate strategy should replace the orphan leaves",
+			hasher:   configWithHashPool.Hasher,
+			arity:    4,
+			strategy: DUPLICATE,
+			nbBuilt:  5,
+			batch:    3,
+		},
+		{
+			name:     "append leaves one by one with promote strategy should return the same root as a rebuilt tree",
+			hasher:   configWithNoHashPool.Hasher,
+			strategy: PROMOTE,
+			nbBuilt:  1,
+			batch:    1,
+		},
+		{
+			name:     "append leaves by batch to a 8-ary tree with promote strategy should return the same root as a rebuilt tree",
+			hasher:   configWithHashPool.Hasher,
+			arity:    8,
+			strategy: PROMOTE,
+			nbBuilt:  3,
+			batch:    7,
+		},
+		{
+			name:     "append leaves one by one with pad strategy should replace the padding leaves",
+			hasher:   configWithHashPool.Hasher,
+			strategy: PAD,
+			nbBuilt:  1,
+			batch:    1,
+		},
+		{
+			name:     "append leaves by batch to a 4-ary tree with pad strategy should replace the padding leaves",
+			hasher:   configWithHashPool.Hasher,
+			arity:    4,
+			strategy: PAD,
+			nbBuilt:  6,
+			batch:    5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := buildTree(t, tt.hasher, tt.arity, tt.strategy, data[:tt.nbBuilt])
+
+			for i := tt.nbBuilt; i < len(data); i += tt.batch {
+				end := i + tt.batch
+				if end > len(data) {
+					end = len(data)
+				}
+				err := mt.Append(ctx, data[i:end]...)
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Append() error = %v, wantErr %v", err, tt.err)
+					return
+				}
+				if err != nil {
+					return
+				}
+
+				expected := buildTree(t, tt.hasher, tt.arity, tt.strategy, data[:end])
+				assert.Equal(t, expected.Root.Hash, mt.Root.Hash)
+				assert.Equal(t, len(expected.Leaves), len(mt.Leaves))
+			}
+
+			for _, d := range data {
+				p, err := mt.Proof(d, SHA256)
+				assert.NoError(t, err)
+				isVerified, err := p.Verify(mt.Root.Hash)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		})
+	}
+}
+
+func TestMerkleTree_ApplyBatch(t *testing.T) {
+	data := make([]Data, 23)
+	for i := range data {
+		data[i] = StringData{Value: fmt.Sprintf("value%d", i)}
+	}
+
+	tests := []struct {
+		name     string
+		hasher   *Hasher
+		arity    uint32
+		strategy OddNodeStrategy
+		data     []Data
+		updates  map[int]Data
+		err      error
+	}{
+		{
+			name:     "apply a batch with an index out of range should return error",
+			hasher:   configWithHashPool.Hasher,
+			strategy: DUPLICATE,
+			data:     data,
+			updates:  map[int]Data{0: StringData{Value: "updated"}, 24: StringData{Value: "updated"}},
+			err:      ErrMerkleTreeIndexOutOfRange,
+		},
+		{
+			name:     "apply a batch with an orphan leaf should return error",
+			hasher:   configWithHashPool.Hasher,
+			strategy: DUPLICATE,
+			data:     data,
+			updates:  map[int]Data{23: StringData{Value: "updated"}},
+			err:      ErrMerkleTreeLeafIsNotData,
+		},
+		{
+			name:     "apply a batch with a duplicated leaf should update its orphan copies",
+			hasher:   configWithNoHashPool.Hasher,
+			strategy: DUPLICATE,
+			data:     data,
+			updates:  map[int]Data{0: StringData{Value: "u0"}, 1: StringData{Value: "u1"}, 22: StringData{Value: "u22"}},
+		},
+		{
+			name:     "apply a batch to a 4-ary tree with promote strategy should return the same root as a rebuilt tree",
+			hasher:   configWithHashPool.Hasher,
+			arity:    4,
+			strategy: PROMOTE,
+			data:     data,
+			updates:  map[int]Data{3: StringData{Value: "u3"}, 7: StringData{Value: "u7"}, 20: StringData{Value: "u20"}, 22: StringData{Value: "u22"}},
+		},
+		{
+			name:     "apply a batch to a padded tree should return the same root as a rebuilt tree",
+			hasher:   configWithHashPool.Hasher,
+			strategy: PAD,
+			data:     data,
+			updates:  map[int]Data{5: StringData{Value: "u5"}, 6: StringData{Value: "u6"}, 11: StringData{Value: "u11"}},
+		},
+		{
+			name:     "apply a batch to sorted leaves keeping their order should return the same root as a rebuilt tree",
+			hasher:   &Hasher{Hash: SHA256, IsSortLeaves: true},
+			strategy: PROMOTE,
+			data:     dataUnEvenNbNodes,
+			updates:  map[int]Data{0: StringData{Value: "u3"}, 4: StringData{Value: "u1"}},
+		},
+		{
+			name:     "apply a batch to sorted leaves breaking their order should return error",
+			hasher:   &Hasher{Hash: SHA256, IsSortLeaves: true},
+			strategy: PROMOTE,
+			data:     dataUnEvenNbNodes,
+			updates:  map[int]Data{0: StringData{Value: "u1"}},
+			err:      ErrMerkleTreeLeafOrderIsBroken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := buildTree(t, tt.hasher, tt.arity, tt.strategy, tt.data)
+			data := leavesData(mt)
+			root := mt.Root.Hash
+
+			err := mt.ApplyBatch(ctx, tt.updates)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ApplyBatch() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				// no leaf should have been modified
+				assert.Equal(t, root, mt.Root.Hash)
+				assert.Equal(t, data, leavesData(mt))
+				return
+			}
+
+			for index, d := range tt.updates {
+				data[index] = d
+			}
+			expected := buildTree(t, tt.hasher, tt.arity, tt.strategy, data)
+			assert.Equal(t, expected.Root.Hash, mt.Root.Hash)
+			assert.Equal(t, data, leavesData(mt))
+
+			for _, d := range tt.updates {
+				isVerified, err := mt.Verify(ctx, d)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+		})
+	}
+}
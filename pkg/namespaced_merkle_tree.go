@@ -0,0 +1,563 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// NamespacedMerkleTree is a tree whose leaves are ordered by namespace, each node recording the min and the max
+// namespace of its subtree along with its hash, so that a proof can show that it contains all the leaves of a namespace
+// or none of them, as Celestia data availability does
+// a node is serialized as min namespace || max namespace || digest, the digest of a leaf being H(0x00 || leaf) and the
+// digest of a parent node being H(0x01 || left || right), the tree being split at the largest power of 2 as RFC 6962
+// minNamespace and maxNamespace are the namespaces of the first and the last leaves, the leaves of the max namespace
+// included, whereas the max namespace of the root may leave them out
+type NamespacedMerkleTree struct {
+	NamespacedMerkleTreeConfig
+	leaves       [][]byte
+	leafNodes    [][]byte
+	nodes        map[[2]int][]byte
+	minNamespace []byte
+	maxNamespace []byte
+}
+
+// NamespacedMerkleTreeConfig is the configuration that represents the options used to build the tree
+// IsIgnoreMaxNamespace leaves out the leaves of the max namespace, i.e. a namespace full of 0xff used for the parity
+// data, from the max namespace of their parents
+type NamespacedMerkleTreeConfig struct {
+	Hasher               *Hasher
+	NamespaceSize        uint32
+	IsIgnoreMaxNamespace bool
+}
+
+// NamespacedMerkleTreeBuilder allows use to pass the configuration before building a namespaced tree
+type NamespacedMerkleTreeBuilder struct {
+	config *NamespacedMerkleTreeConfig
+}
+
+// NamespaceProof proves the leaves from the index Start to the index End (excluded), Nodes being the roots of the
+// subtrees on their left and on their right, in order
+// a proof of absence of a namespace proves instead the leaf whose hash is LeafHash, i.e. the first leaf of a greater
+// namespace, and an empty proof, i.e. Start equal to End, proves a namespace out of the namespace range of the root,
+// the max namespace excepted when it is left out of the root
+type NamespaceProof struct {
+	Hash                 Hash
+	NamespaceSize        uint32
+	IsIgnoreMaxNamespace bool
+	Start                int
+	End                  int
+	Nodes                [][]byte
+	LeafHash             []byte
+}
+
+const (
+	// DefaultNamespaceSize is the default size of the namespace of a leaf, Celestia using 29 bytes
+	DefaultNamespaceSize = 8
+	// MaxNamespaceSize is the highest size of the namespace of a leaf
+	MaxNamespaceSize = 64
+
+	nmtLeafPrefix = 0x00
+	nmtNodePrefix = 0x01
+)
+
+var (
+	ErrNamespacedMerkleTreeConfigHasherIsWrong        = errors.New("the namespaced merkle tree hasher cannot truncate digests nor sort leaves or pairs")
+	ErrNamespacedMerkleTreeConfigNamespaceSizeIsWrong = errors.New("the namespaced merkle tree namespace size must be between 1 and the max namespace size")
+	ErrNamespacedMerkleTreeLeafIsWrong                = errors.New("the namespaced merkle tree leaf must start with its namespace")
+	ErrNamespacedMerkleTreeNamespaceIsNotOrdered      = errors.New("the namespaced merkle tree leaves must be pushed in namespace order")
+	ErrNamespacedMerkleTreeIndexOutOfRange            = errors.New("the namespaced merkle tree does not contain the range")
+	ErrNamespacedMerkleProofIsWrong                   = errors.New("the namespace proof is not consistent with its range or its leaves")
+)
+
+func NewNamespacedMerkleTreeBuilder() *NamespacedMerkleTreeBuilder {
+	return &NamespacedMerkleTreeBuilder{config: &NamespacedMerkleTreeConfig{
+		NamespaceSize:        DefaultNamespaceSize,
+		IsIgnoreMaxNamespace: true,
+	}}
+}
+
+func (b *NamespacedMerkleTreeBuilder) WithHasher(hasher *Hasher) *NamespacedMerkleTreeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+// WithNamespaceSize sets the size of the namespace each leaf starts with
+func (b *NamespacedMerkleTreeBuilder) WithNamespaceSize(namespaceSize uint32) *NamespacedMerkleTreeBuilder {
+	b.config.NamespaceSize = namespaceSize
+	return b
+}
+
+// WithIgnoreMaxNamespace leaves out the leaves of the max namespace from the max namespace of their parents, true by
+// default as Celestia does
+func (b *NamespacedMerkleTreeBuilder) WithIgnoreMaxNamespace(isIgnoreMaxNamespace bool) *NamespacedMerkleTreeBuilder {
+	b.config.IsIgnoreMaxNamespace = isIgnoreMaxNamespace
+	return b
+}
+
+// Build creates an empty tree
+func (b *NamespacedMerkleTreeBuilder) Build() (*NamespacedMerkleTree, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if b.config.Hasher.DigestLength != 0 || b.config.Hasher.IsSortLeaves || b.config.Hasher.IsSortPairs {
+		return nil, ErrNamespacedMerkleTreeConfigHasherIsWrong
+	}
+
+	if b.config.NamespaceSize == 0 || b.config.NamespaceSize > MaxNamespaceSize {
+		return nil, fmt.Errorf("namespaceSize<%d>: %w", b.config.NamespaceSize, ErrNamespacedMerkleTreeConfigNamespaceSizeIsWrong)
+	}
+
+	return &NamespacedMerkleTree{NamespacedMerkleTreeConfig: *b.config}, nil
+}
+
+// Push appends a leaf, its namespace being its first NamespaceSize bytes
+// the namespace cannot be lower than the namespace of the previous leaf
+func (t *NamespacedMerkleTree) Push(namespacedData []byte) error {
+	if len(namespacedData) < int(t.NamespaceSize) {
+		return fmt.Errorf("leaf<%x>: %w", namespacedData, ErrNamespacedMerkleTreeLeafIsWrong)
+	}
+	if n := len(t.leaves); n > 0 && bytes.Compare(t.namespace(namespacedData), t.namespace(t.leaves[n-1])) < 0 {
+		return fmt.Errorf("leaf<%x>: %w", namespacedData, ErrNamespacedMerkleTreeNamespaceIsNotOrdered)
+	}
+
+	leafNode, err := t.hashLeaf(namespacedData)
+	if err != nil {
+		return err
+	}
+	leaf := append([]byte{}, namespacedData...)
+	if len(t.leaves) == 0 {
+		t.minNamespace = t.namespace(leaf)
+	}
+	t.maxNamespace = t.namespace(leaf)
+	t.leaves = append(t.leaves, leaf)
+	t.leafNodes = append(t.leafNodes, leafNode)
+	t.nodes = nil
+	return nil
+}
+
+// Size returns the nb of leaves of the tree
+func (t *NamespacedMerkleTree) Size() int {
+	return len(t.leaves)
+}
+
+// Root returns the root node of the tree, i.e. its min namespace, its max namespace and its digest
+// the root of an empty tree is made of empty namespaces and of the digest of an empty input
+func (t *NamespacedMerkleTree) Root() ([]byte, error) {
+	if len(t.leaves) == 0 {
+		return t.emptyRoot()
+	}
+	if t.nodes == nil {
+		t.nodes = make(map[[2]int][]byte)
+	}
+	return t.subtreeRoot(0, len(t.leaves))
+}
+
+// Get returns the leaves of the namespace passed in parameter
+func (t *NamespacedMerkleTree) Get(namespace []byte) [][]byte {
+	start, end := t.namespaceRange(namespace)
+	return t.leaves[start:end]
+}
+
+// ProveRange generates the inclusion proof of the leaves from the index start to the index end (excluded)
+func (t *NamespacedMerkleTree) ProveRange(start, end int) (*NamespaceProof, error) {
+	if start < 0 || start >= end || end > len(t.leaves) {
+		return nil, fmt.Errorf("range<%d, %d>: %w", start, end, ErrNamespacedMerkleTreeIndexOutOfRange)
+	}
+	if _, err := t.Root(); err != nil {
+		return nil, err
+	}
+
+	p := t.newProof(start, end)
+	if err := t.proveRange(p, 0, len(t.leaves)); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ProveNamespace generates the proof that the leaves of the namespace passed in parameter are all the leaves of the
+// tree of this namespace, or that the tree does not contain any leaf of this namespace
+func (t *NamespacedMerkleTree) ProveNamespace(namespace []byte) (*NamespaceProof, error) {
+	if len(namespace) != int(t.NamespaceSize) {
+		return nil, fmt.Errorf("namespace<%x>: %w", namespace, ErrNamespacedMerkleTreeLeafIsWrong)
+	}
+
+	// the namespaces of the leaves are used rather than the ones of the root so that the leaves of the max namespace
+	// left out of the root are proven too
+	if len(t.leaves) == 0 || bytes.Compare(namespace, t.minNamespace) < 0 || bytes.Compare(namespace, t.maxNamespace) > 0 {
+		return t.newProof(0, 0), nil
+	}
+
+	start, end := t.namespaceRange(namespace)
+	if start == end {
+		// the first leaf of a greater namespace proves that the namespace is absent
+		p, err := t.ProveRange(start, start+1)
+		if err != nil {
+			return nil, err
+		}
+		p.LeafHash = t.leafNodes[start]
+		return p, nil
+	}
+	return t.ProveRange(start, end)
+}
+
+// IsOfAbsence returns true if the proof proves that the tree does not contain any leaf of a namespace
+func (p *NamespaceProof) IsOfAbsence() bool {
+	return p.LeafHash != nil || p.Start == p.End
+}
+
+// VerifyRange checks the inclusion of the leaves passed in parameter against the root, whatever their namespace
+func (p *NamespaceProof) VerifyRange(root []byte, leaves [][]byte) (bool, error) {
+	c, err := p.config()
+	if err != nil {
+		return false, err
+	}
+	if p.IsOfAbsence() || len(leaves) != p.End-p.Start {
+		return false, ErrNamespacedMerkleProofIsWrong
+	}
+
+	leafNodes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		if len(leaf) < int(p.NamespaceSize) {
+			return false, fmt.Errorf("leaf<%x>: %w", leaf, ErrNamespacedMerkleTreeLeafIsWrong)
+		}
+		if leafNodes[i], err = c.hashLeaf(leaf); err != nil {
+			return false, err
+		}
+	}
+
+	computed, _, err := p.computeRoot(c, leafNodes, nil)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(computed, root), nil
+}
+
+// VerifyNamespace checks that the leaves passed in parameter are all the leaves of the namespace the root commits to
+// the leaves of a proof of absence are empty
+func (p *NamespaceProof) VerifyNamespace(root, namespace []byte, leaves [][]byte) (bool, error) {
+	c, err := p.config()
+	if err != nil {
+		return false, err
+	}
+	if len(namespace) != int(p.NamespaceSize) || len(root) != 2*int(p.NamespaceSize)+p.Hash.Size() {
+		return false, ErrNamespacedMerkleProofIsWrong
+	}
+
+	// the namespace is out of the namespace range of the root
+	// the root does not commit to the leaves of the max namespace when they are left out of its max namespace
+	if p.Start == p.End {
+		if len(leaves) > 0 || len(p.Nodes) > 0 {
+			return false, ErrNamespacedMerkleProofIsWrong
+		}
+		emptyRoot, err := c.emptyRoot()
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(root, emptyRoot) {
+			return true, nil
+		}
+		if p.IsIgnoreMaxNamespace && isMaxNamespace(namespace) {
+			return false, nil
+		}
+		return bytes.Compare(namespace, c.MinNamespace(root)) < 0 || bytes.Compare(namespace, c.MaxNamespace(root)) > 0, nil
+	}
+
+	var leafNodes [][]byte
+	if p.LeafHash != nil {
+		if len(leaves) > 0 || p.End-p.Start != 1 || len(p.LeafHash) != len(root) {
+			return false, ErrNamespacedMerkleProofIsWrong
+		}
+		if bytes.Compare(c.MinNamespace(p.LeafHash), namespace) <= 0 {
+			return false, nil
+		}
+		leafNodes = [][]byte{p.LeafHash}
+	} else {
+		if len(leaves) != p.End-p.Start {
+			return false, ErrNamespacedMerkleProofIsWrong
+		}
+		leafNodes = make([][]byte, len(leaves))
+		for i, leaf := range leaves {
+			if len(leaf) < int(p.NamespaceSize) || !bytes.Equal(c.namespace(leaf), namespace) {
+				return false, nil
+			}
+			if leafNodes[i], err = c.hashLeaf(leaf); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	computed, isComplete, err := p.computeRoot(c, leafNodes, namespace)
+	if err != nil {
+		return false, err
+	}
+	return isComplete && bytes.Equal(computed, root), nil
+}
+
+// computeRoot computes the root from the leaf nodes of the range and the nodes of the proof
+// the size of the tree is unknown, the range is assumed to be within the left subtree of the smallest power of 2
+// covering it, the nodes that remain being the roots of the subtrees on the right of this subtree
+// if a namespace is passed in parameter, it also checks that the nodes on the left of the range have a lower max
+// namespace and the nodes on the right a greater min namespace, i.e. that the range contains all its leaves
+func (p *NamespaceProof) computeRoot(c *NamespacedMerkleTreeConfig, leafNodes [][]byte, namespace []byte) ([]byte, bool, error) {
+	var (
+		nodes      = p.Nodes
+		isComplete = true
+	)
+	pop := func(isLeft bool) []byte {
+		if len(nodes) == 0 {
+			return nil
+		}
+		node := nodes[0]
+		nodes = nodes[1:]
+		if namespace != nil {
+			if isLeft && bytes.Compare(c.MaxNamespace(node), namespace) >= 0 ||
+				!isLeft && bytes.Compare(c.MinNamespace(node), namespace) <= 0 {
+				isComplete = false
+			}
+		}
+		return node
+	}
+
+	var compute func(start, end int) ([]byte, error)
+	compute = func(start, end int) ([]byte, error) {
+		switch {
+		case end <= p.Start:
+			return pop(true), nil
+		case start >= p.End:
+			return pop(false), nil
+		case end-start == 1:
+			if len(leafNodes) == 0 {
+				return nil, ErrNamespacedMerkleProofIsWrong
+			}
+			leaf := leafNodes[0]
+			leafNodes = leafNodes[1:]
+			return leaf, nil
+		}
+
+		k := splitPoint(end - start)
+		left, err := compute(start, start+k)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compute(start+k, end)
+		if err != nil {
+			return nil, err
+		}
+		// only the right subtree can be missing when the tree is smaller than the estimated subtree
+		if left == nil {
+			return nil, ErrNamespacedMerkleProofIsWrong
+		}
+		if right == nil {
+			return left, nil
+		}
+		return c.hashNode(left, right)
+	}
+
+	estimate := 1
+	if p.End > 1 {
+		estimate = 1 << bits.Len(uint(p.End-1))
+	}
+	root, err := compute(0, estimate)
+	if err != nil {
+		return nil, false, err
+	}
+	for len(nodes) > 0 {
+		if root, err = c.hashNode(root, pop(false)); err != nil {
+			return nil, false, err
+		}
+	}
+	if len(leafNodes) > 0 {
+		return nil, false, ErrNamespacedMerkleProofIsWrong
+	}
+	return root, isComplete, nil
+}
+
+// config returns the configuration the proof has been generated with
+func (p *NamespaceProof) config() (*NamespacedMerkleTreeConfig, error) {
+	if p == nil {
+		return nil, ErrProofIsNil
+	}
+	if !p.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), p.Hash)
+	}
+	if p.NamespaceSize == 0 || p.NamespaceSize > MaxNamespaceSize {
+		return nil, fmt.Errorf("namespaceSize<%d>: %w", p.NamespaceSize, ErrNamespacedMerkleTreeConfigNamespaceSizeIsWrong)
+	}
+	if p.Start < 0 || p.End < p.Start {
+		return nil, ErrNamespacedMerkleProofIsWrong
+	}
+	for _, node := range p.Nodes {
+		if len(node) != 2*int(p.NamespaceSize)+p.Hash.Size() {
+			return nil, ErrNamespacedMerkleProofIsWrong
+		}
+	}
+	return &NamespacedMerkleTreeConfig{
+		Hasher:               &Hasher{Hash: p.Hash},
+		NamespaceSize:        p.NamespaceSize,
+		IsIgnoreMaxNamespace: p.IsIgnoreMaxNamespace,
+	}, nil
+}
+
+func (t *NamespacedMerkleTree) newProof(start, end int) *NamespaceProof {
+	return &NamespaceProof{
+		Hash:                 t.Hasher.Hash,
+		NamespaceSize:        t.NamespaceSize,
+		IsIgnoreMaxNamespace: t.IsIgnoreMaxNamespace,
+		Start:                start,
+		End:                  end,
+	}
+}
+
+// proveRange adds to the proof the roots of the subtrees of the subtree from start to end that are out of the range
+func (t *NamespacedMerkleTree) proveRange(p *NamespaceProof, start, end int) error {
+	switch {
+	case end <= p.Start || start >= p.End:
+		node, err := t.subtreeRoot(start, end)
+		if err != nil {
+			return err
+		}
+		p.Nodes = append(p.Nodes, node)
+		return nil
+	case start >= p.Start && end <= p.End:
+		return nil
+	}
+
+	k := splitPoint(end - start)
+	if err := t.proveRange(p, start, start+k); err != nil {
+		return err
+	}
+	return t.proveRange(p, start+k, end)
+}
+
+// subtreeRoot returns the root of the subtree of the leaves from start to end (excluded), computed once per tree
+func (t *NamespacedMerkleTree) subtreeRoot(start, end int) ([]byte, error) {
+	if end-start == 1 {
+		return t.leafNodes[start], nil
+	}
+	if node, ok := t.nodes[[2]int{start, end}]; ok {
+		return node, nil
+	}
+
+	k := splitPoint(end - start)
+	left, err := t.subtreeRoot(start, start+k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.subtreeRoot(start+k, end)
+	if err != nil {
+		return nil, err
+	}
+	node, err := t.hashNode(left, right)
+	if err != nil {
+		return nil, err
+	}
+	t.nodes[[2]int{start, end}] = node
+	return node, nil
+}
+
+// namespaceRange returns the range of the leaves of the namespace passed in parameter
+func (t *NamespacedMerkleTree) namespaceRange(namespace []byte) (int, int) {
+	start := sort.Search(len(t.leaves), func(i int) bool {
+		return bytes.Compare(t.namespace(t.leaves[i]), namespace) >= 0
+	})
+	end := sort.Search(len(t.leaves), func(i int) bool {
+		return bytes.Compare(t.namespace(t.leaves[i]), namespace) > 0
+	})
+	return start, end
+}
+
+// MinNamespace returns the min namespace of the node passed in parameter
+func (c *NamespacedMerkleTreeConfig) MinNamespace(node []byte) []byte {
+	return node[:c.NamespaceSize]
+}
+
+// MaxNamespace returns the max namespace of the node passed in parameter
+func (c *NamespacedMerkleTreeConfig) MaxNamespace(node []byte) []byte {
+	return node[c.NamespaceSize : 2*c.NamespaceSize]
+}
+
+func (c *NamespacedMerkleTreeConfig) namespace(leaf []byte) []byte {
+	return leaf[:c.NamespaceSize]
+}
+
+// hashLeaf returns the node of a leaf, i.e. its namespace twice and H(0x00 || leaf)
+func (c *NamespacedMerkleTreeConfig) hashLeaf(leaf []byte) ([]byte, error) {
+	digest, err := c.hash([]byte{nmtLeafPrefix}, leaf)
+	if err != nil {
+		return nil, err
+	}
+	namespace := c.namespace(leaf)
+	return append(append(append(make([]byte, 0, 2*len(namespace)+len(digest)), namespace...), namespace...), digest...), nil
+}
+
+// hashNode returns the parent node of both nodes, i.e. their min and max namespaces and H(0x01 || left || right)
+// the max namespace of the right node is ignored if it is a subtree of the max namespace only
+func (c *NamespacedMerkleTreeConfig) hashNode(left, right []byte) ([]byte, error) {
+	digest, err := c.hash([]byte{nmtNodePrefix}, left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	minNamespace := c.MinNamespace(left)
+	if bytes.Compare(c.MinNamespace(right), minNamespace) < 0 {
+		minNamespace = c.MinNamespace(right)
+	}
+	maxNamespace := c.MaxNamespace(left)
+	switch {
+	case c.IsIgnoreMaxNamespace && isMaxNamespace(c.MinNamespace(left)):
+		maxNamespace = c.MinNamespace(left)
+	case c.IsIgnoreMaxNamespace && isMaxNamespace(c.MinNamespace(right)):
+	case bytes.Compare(c.MaxNamespace(right), maxNamespace) > 0:
+		maxNamespace = c.MaxNamespace(right)
+	}
+
+	node := make([]byte, 0, len(minNamespace)+len(maxNamespace)+len(digest))
+	return append(append(append(node, minNamespace...), maxNamespace...), digest...), nil
+}
+
+func (c *NamespacedMerkleTreeConfig) emptyRoot() ([]byte, error) {
+	digest, err := c.hash()
+	if err != nil {
+		return nil, err
+	}
+	return append(make([]byte, 2*c.NamespaceSize), digest...), nil
+}
+
+func (c *NamespacedMerkleTreeConfig) hash(parts ...[]byte) ([]byte, error) {
+	hf := c.Hasher.getHash()
+	defer hf.Close()
+
+	for _, part := range parts {
+		if _, err := hf.Write(part); err != nil {
+			return nil, fmt.Errorf("hf.Write(%x): %w", part, err)
+		}
+	}
+	return hf.Sum(nil), nil
+}
+
+// isMaxNamespace checks if the namespace is full of 0xff
+func isMaxNamespace(namespace []byte) bool {
+	for _, b := range namespace {
+		if b != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPoint returns the largest power of 2 lower than n, n being greater than 1
+func splitPoint(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
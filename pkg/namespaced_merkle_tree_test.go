@@ -0,0 +1,237 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNamespacedMerkleTreeBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name          string
+		hasher        *Hasher
+		namespaceSize uint32
+		err           error
+	}{
+		{
+			name:          "build a namespaced tree without hasher should return error",
+			hasher:        nil,
+			namespaceSize: DefaultNamespaceSize,
+			err:           ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:          "build a namespaced tree truncating its digests should return error",
+			hasher:        &Hasher{Hash: SHA256, DigestLength: 16},
+			namespaceSize: DefaultNamespaceSize,
+			err:           ErrNamespacedMerkleTreeConfigHasherIsWrong,
+		},
+		{
+			name:          "build a namespaced tree without namespace should return error",
+			hasher:        &Hasher{Hash: SHA256},
+			namespaceSize: 0,
+			err:           ErrNamespacedMerkleTreeConfigNamespaceSizeIsWrong,
+		},
+		{
+			name:          "build a namespaced tree with a too long namespace should return error",
+			hasher:        &Hasher{Hash: SHA256},
+			namespaceSize: MaxNamespaceSize + 1,
+			err:           ErrNamespacedMerkleTreeConfigNamespaceSizeIsWrong,
+		},
+		{
+			name:          "build a namespaced tree with the celestia namespace size should return the empty root",
+			hasher:        &Hasher{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+			namespaceSize: 29,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewNamespacedMerkleTreeBuilder().WithHasher(tt.hasher).WithNamespaceSize(tt.namespaceSize).Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			root, err := tree.Root()
+			assert.NoError(t, err)
+			emptyDigest := sha256.Sum256(nil)
+			assert.Equal(t, append(make([]byte, 2*tt.namespaceSize), emptyDigest[:]...), root)
+		})
+	}
+}
+
+func TestNamespacedMerkleTree_Root(t *testing.T) {
+	leaves := [][]byte{{0, 'a'}, {0, 'b'}, {1, 'c'}}
+	hash := func(b ...byte) []byte {
+		digest := sha256.Sum256(b)
+		return digest[:]
+	}
+	leafNode := func(leaf []byte) []byte {
+		return append([]byte{leaf[0], leaf[0]}, hash(append([]byte{0}, leaf...)...)...)
+	}
+
+	// the root of 3 leaves is H(0x01 || H(0x01 || leaf0 || leaf1) || leaf2) with the namespaces of the leaves
+	left := append([]byte{0, 0}, hash(append(append([]byte{1}, leafNode(leaves[0])...), leafNode(leaves[1])...)...)...)
+	expected := append([]byte{0, 1}, hash(append(append([]byte{1}, left...), leafNode(leaves[2])...)...)...)
+
+	tree, err := NewNamespacedMerkleTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).WithNamespaceSize(1).Build()
+	assert.NoError(t, err)
+	for _, leaf := range leaves {
+		assert.NoError(t, tree.Push(leaf))
+	}
+	root, err := tree.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, root)
+	assert.Equal(t, []byte{0}, tree.MinNamespace(root))
+	assert.Equal(t, []byte{1}, tree.MaxNamespace(root))
+
+	assert.ErrorIs(t, tree.Push([]byte{0, 'd'}), ErrNamespacedMerkleTreeNamespaceIsNotOrdered)
+	assert.ErrorIs(t, tree.Push(nil), ErrNamespacedMerkleTreeLeafIsWrong)
+	assert.Equal(t, [][]byte{{0, 'a'}, {0, 'b'}}, tree.Get([]byte{0}))
+}
+
+func TestNamespacedMerkleTree_IgnoreMaxNamespace(t *testing.T) {
+	tests := []struct {
+		name                 string
+		isIgnoreMaxNamespace bool
+		expected             []byte
+	}{
+		{
+			name:                 "ignoring the max namespace should return the max namespace of the data",
+			isIgnoreMaxNamespace: true,
+			expected:             []byte{0, 2},
+		},
+		{
+			name:                 "not ignoring the max namespace should return the max namespace",
+			isIgnoreMaxNamespace: false,
+			expected:             []byte{0xff, 0xff},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewNamespacedMerkleTreeBuilder().
+				WithHasher(&Hasher{Hash: SHA256}).
+				WithNamespaceSize(2).
+				WithIgnoreMaxNamespace(tt.isIgnoreMaxNamespace).
+				Build()
+			assert.NoError(t, err)
+			for _, leaf := range [][]byte{{0, 1, 'a'}, {0, 2, 'b'}, {0xff, 0xff, 'c'}, {0xff, 0xff, 'd'}} {
+				assert.NoError(t, tree.Push(leaf))
+			}
+			root, err := tree.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, []byte{0, 1}, tree.MinNamespace(root))
+			assert.Equal(t, tt.expected, tree.MaxNamespace(root))
+
+			// the parity leaves should be proven even if they are left out of the max namespace of the root
+			maxNamespace := []byte{0xff, 0xff}
+			p, err := tree.ProveNamespace(maxNamespace)
+			assert.NoError(t, err)
+			assert.False(t, p.IsOfAbsence())
+			isVerified, err := p.VerifyNamespace(root, maxNamespace, tree.Get(maxNamespace))
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+
+			// an empty proof should not prove the absence of the parity leaves
+			isVerified, err = tree.newProof(0, 0).VerifyNamespace(root, maxNamespace, nil)
+			assert.NoError(t, err)
+			assert.False(t, isVerified)
+		})
+	}
+}
+
+func TestNamespacedMerkleTree_ProveRange(t *testing.T) {
+	for nbLeaves := 1; nbLeaves <= 10; nbLeaves++ {
+		t.Run(fmt.Sprintf("%d leaves", nbLeaves), func(t *testing.T) {
+			tree, err := NewNamespacedMerkleTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).WithNamespaceSize(1).Build()
+			assert.NoError(t, err)
+			leaves := make([][]byte, nbLeaves)
+			for i := range leaves {
+				leaves[i] = []byte{byte(i / 2), byte('a' + i)}
+				assert.NoError(t, tree.Push(leaves[i]))
+			}
+			root, err := tree.Root()
+			assert.NoError(t, err)
+
+			for start := 0; start < nbLeaves; start++ {
+				for end := start + 1; end <= nbLeaves; end++ {
+					p, err := tree.ProveRange(start, end)
+					assert.NoError(t, err)
+
+					isVerified, err := p.VerifyRange(root, leaves[start:end])
+					assert.NoError(t, err)
+					assert.True(t, isVerified, "range<%d, %d>", start, end)
+
+					isVerified, err = p.VerifyRange(root, append([][]byte{{9, 'z'}}, leaves[start+1:end]...))
+					assert.NoError(t, err)
+					assert.False(t, isVerified, "range<%d, %d>", start, end)
+				}
+			}
+
+			_, err = tree.ProveRange(0, nbLeaves+1)
+			assert.ErrorIs(t, err, ErrNamespacedMerkleTreeIndexOutOfRange)
+		})
+	}
+}
+
+func TestNamespacedMerkleTree_ProveNamespace(t *testing.T) {
+	tree, err := NewNamespacedMerkleTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).WithNamespaceSize(1).Build()
+	assert.NoError(t, err)
+	for _, leaf := range [][]byte{{1, 'a'}, {1, 'b'}, {3, 'c'}, {3, 'd'}, {3, 'e'}, {5, 'f'}, {7, 'g'}} {
+		assert.NoError(t, tree.Push(leaf))
+	}
+	root, err := tree.Root()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		namespace   byte
+		isOfAbsence bool
+	}{
+		{name: "prove the first namespace should return its leaves", namespace: 1},
+		{name: "prove a namespace in the middle should return its leaves", namespace: 3},
+		{name: "prove the last namespace should return its leaf", namespace: 7},
+		{name: "prove an absent namespace between namespaces should prove its absence", namespace: 4, isOfAbsence: true},
+		{name: "prove a namespace lower than the min namespace should prove its absence", namespace: 0, isOfAbsence: true},
+		{name: "prove a namespace greater than the max namespace should prove its absence", namespace: 8, isOfAbsence: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := []byte{tt.namespace}
+			p, err := tree.ProveNamespace(namespace)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.isOfAbsence, p.IsOfAbsence())
+
+			leaves := tree.Get(namespace)
+			isVerified, err := p.VerifyNamespace(root, namespace, leaves)
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+
+			if len(leaves) > 1 {
+				// a proof of a part of the leaves of the namespace should not be complete
+				partial, err := tree.ProveRange(p.Start, p.End-1)
+				assert.NoError(t, err)
+				isVerified, err = partial.VerifyNamespace(root, namespace, leaves[:len(leaves)-1])
+				assert.NoError(t, err)
+				assert.False(t, isVerified)
+			}
+		})
+	}
+
+	// a proof of absence of a namespace should not prove the absence of the namespace of its leaf
+	p, err := tree.ProveNamespace([]byte{4})
+	assert.NoError(t, err)
+	isVerified, err := p.VerifyNamespace(root, []byte{5}, nil)
+	assert.NoError(t, err)
+	assert.False(t, isVerified)
+
+	_, err = tree.ProveNamespace([]byte{1, 2})
+	assert.ErrorIs(t, err, ErrNamespacedMerkleTreeLeafIsWrong)
+
+	p = nil
+	_, err = p.VerifyNamespace(root, []byte{1}, nil)
+	assert.ErrorIs(t, err, ErrProofIsNil)
+}
@@ -6,70 +6,117 @@ import (
 )
 
 // Node represents a node within the tree
-// a node can be defined as a leaf or a parent node - calculated from two leaves or two child nodes
+// a node can be defined as a leaf or a parent node - calculated from arity leaves or arity child nodes
+// Hashes contains one hash per hasher the tree has been built with, Hash being the one of the main hasher
 type Node struct {
-	Parent   *Node
-	Left     *Node
-	Right    *Node
-	isOrphan bool
-	Hash     []byte
-	Data     Data
+	Children    []*Node
+	isOrphan    bool
+	isTombstone bool
+	Hash        []byte
+	Hashes      [][]byte
+	Data        Data
 }
 
 func NewLeaf(p *Hasher, d Data) (*Node, error) {
-	return newLeaf(p, d, false)
+	return newLeaf([]*Hasher{p}, d, false)
 }
 
 func NewOrphanLeaf(p *Hasher, d Data) (*Node, error) {
-	return newLeaf(p, d, true)
+	return newLeaf([]*Hasher{p}, d, true)
 }
 
-func NewParentNode(p *Hasher, left, right *Node) (*Node, error) {
-	if p.Pool == nil {
-		hf := p.Hash.HashFunc()()
-		if _, err := hf.Write(concat(false, p.IsSort, left.Hash, right.Hash)); err != nil {
-			return nil, fmt.Errorf("hf.Write(concat(%x,%x)): %w", left.Hash, right.Hash, err)
+func NewParentNode(p *Hasher, children ...*Node) (*Node, error) {
+	return newParentNode([]*Hasher{p}, children...)
+}
+
+func newLeaf(hashers []*Hasher, d Data, isPadding bool) (*Node, error) {
+	var (
+		err    error
+		hashes = make([][]byte, len(hashers))
+	)
+
+	for i, h := range hashers {
+		if hashes[i], err = d.Hash(h); err != nil {
+			return nil, fmt.Errorf("d.Hasher(%s): data<%s>: %w", h.Hash, d, err)
 		}
-		return &Node{
-			Left:  left,
-			Right: right,
-			Hash:  hf.Sum(nil),
-		}, nil
 	}
 
-	h := p.Pool.getHash()
-	defer h.Close()
+	return &Node{
+		isOrphan: isPadding,
+		Hash:     hashes[0],
+		Hashes:   hashes,
+		Data:     d,
+	}, nil
+}
 
-	if _, err := h.Write(concat(true, p.IsSort, left.Hash, right.Hash)); err != nil {
-		return nil, fmt.Errorf("hf.Write(concat(%x,%x)): %w", left.Hash, right.Hash, err)
+// newPaddingLeaf generates a leaf that does not contain any data and that is used to pad the tree
+func newPaddingLeaf(hashes [][]byte) *Node {
+	return &Node{
+		isOrphan: true,
+		Hash:     hashes[0],
+		Hashes:   hashes,
 	}
+}
 
+// newTombstoneLeaf generates a leaf replacing a deleted leaf, it does not contain any data
+func newTombstoneLeaf(hashes [][]byte) *Node {
 	return &Node{
-		Left:  left,
-		Right: right,
-		Hash:  h.Sum(nil),
-	}, nil
+		isTombstone: true,
+		Hash:        hashes[0],
+		Hashes:      hashes,
+	}
 }
 
-func newLeaf(p *Hasher, d Data, isPadding bool) (*Node, error) {
+// orphanCopy returns an orphan leaf duplicating the leaf, the hashes being copied instead of being computed again
+func (n *Node) orphanCopy() *Node {
+	return &Node{
+		isOrphan:    true,
+		isTombstone: n.isTombstone,
+		Hash:        n.Hash,
+		Hashes:      n.Hashes,
+		Data:        n.Data,
+	}
+}
+
+// newParentNode generates a parent node computing in the same pass the hash of each hasher
+func newParentNode(hashers []*Hasher, children ...*Node) (*Node, error) {
 	var (
-		err error
-		b   []byte
+		err            error
+		hashes         = make([][]byte, len(hashers))
+		childrenHashes = make([][]byte, len(children))
 	)
 
-	if b, err = d.Hash(p); err != nil {
-		return nil, fmt.Errorf("d.Hasher(): data<%s>: %w", d, err)
+	for i, h := range hashers {
+		for j, child := range children {
+			childrenHashes[j] = child.hashAt(i)
+		}
+		if hashes[i], err = h.hashChildren(childrenHashes...); err != nil {
+			return nil, fmt.Errorf("h.hashChildren(%s): %w", h.Hash, err)
+		}
 	}
 
 	return &Node{
-		isOrphan: isPadding,
-		Hash:     b,
-		Data:     d,
+		Children: children,
+		Hash:     hashes[0],
+		Hashes:   hashes,
 	}, nil
 }
 
+// hashAt returns the hash computed by the i-th hasher of the tree
+func (n *Node) hashAt(i int) []byte {
+	if i < len(n.Hashes) {
+		return n.Hashes[i]
+	}
+	return n.Hash
+}
+
 func (n *Node) isLeaf() bool {
-	return n.Left == nil && n.Right == nil
+	return len(n.Children) == 0
+}
+
+// isPadding checks if the node is a leaf padding the tree
+func (n *Node) isPadding() bool {
+	return n.isOrphan && !n.isTombstone && n.Data == nil
 }
 
 type NodeSorter struct {
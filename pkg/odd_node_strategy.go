@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OddNodeStrategy is an enum representing how a node that has no sibling to be paired with is handled
+type OddNodeStrategy string
+
+const (
+	// UNKNOWNODDNODESTRATEGY is the default value returned for non-supported strategies
+	UNKNOWNODDNODESTRATEGY OddNodeStrategy = "unknown"
+	// DUPLICATE fills an incomplete group of nodes with its last node, the last leaf being duplicated by NewOrphanLeaf
+	DUPLICATE OddNodeStrategy = "duplicate"
+	// PROMOTE moves the unpaired node up to the next level unchanged as described in RFC 6962
+	// an incomplete group of several nodes is hashed as is
+	PROMOTE OddNodeStrategy = "promote"
+	// PAD pads the leaves up to the next power of arity with empty leaves so that no node is ever unpaired
+	PAD OddNodeStrategy = "pad"
+)
+
+var ErrOddNodeStrategyNotAllowed = errors.New("the odd node strategy is not recognized")
+
+// IsValid checks if a strategy is valid
+func (s OddNodeStrategy) IsValid() bool {
+	switch s {
+	case DUPLICATE, PROMOTE, PAD:
+		return true
+	case UNKNOWNODDNODESTRATEGY:
+		return false
+	}
+	return false
+}
+
+// Validate returns an error if the strategy is not supported
+func (s OddNodeStrategy) Validate() error {
+	if !s.IsValid() {
+		return fmt.Errorf("OddNodeStrategy<%s>: %w", s, ErrOddNodeStrategyNotAllowed)
+	}
+	return nil
+}
+
+// nextPowerOf returns the lowest power of base greater or equal to n
+func nextPowerOf(base, n int) int {
+	p := 1
+	for p < n {
+		p *= base
+	}
+	return p
+}
@@ -0,0 +1,669 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// PatriciaTrie is the hexary Merkle Patricia Trie used by Ethereum to commit to its transactions, receipts and state
+// keys are split in nibbles, a branch node having 16 children and a value, an extension node sharing a path between
+// its child and the leaves below it, and a leaf node holding the end of the path of a key and its value
+// nodes are serialized with RLP, a node whose serialization is shorter than a hash being embedded in its parent
+// instead of being referenced by its hash
+// nodes are never modified once created, an update replacing the nodes of the path of the key
+type PatriciaTrie struct {
+	PatriciaTrieConfig
+	root patriciaNode
+	size int
+}
+
+// PatriciaTrieConfig is the configuration that represents the options used to build the trie
+// IsSecureKeys hashes the keys before inserting them as Ethereum does for its state and storage tries
+type PatriciaTrieConfig struct {
+	Hasher       *Hasher
+	IsSecureKeys bool
+}
+
+// PatriciaTrieBuilder allows use to pass the configuration before building a trie
+type PatriciaTrieBuilder struct {
+	config *PatriciaTrieConfig
+}
+
+// EthereumAccount is an account of the Ethereum state trie
+type EthereumAccount struct {
+	Nonce       uint64
+	Balance     *big.Int
+	StorageRoot []byte
+	CodeHash    []byte
+}
+
+var (
+	ErrPatriciaTrieConfigHasherIsWrong = errors.New("the patricia trie hasher cannot truncate digests nor sort leaves or pairs")
+	ErrPatriciaTrieKeyNotFound         = errors.New("the patricia trie does not contain the key")
+	ErrPatriciaProofIsWrong            = errors.New("the patricia proof is not consistent with the root or the key")
+)
+
+// patriciaNode is a node of the trie, either a leaf, an extension or a branch
+// a node is never modified once created, its serialization and its reference are computed by its constructor so
+// that the nodes shared between versions of the trie can be read concurrently
+type patriciaNode interface {
+	encoding() []byte
+	reference() []byte
+}
+
+// patriciaEncoding is the serialization of a node and how it is referenced by its parent
+type patriciaEncoding struct {
+	encoded []byte
+	ref     []byte
+}
+
+type patriciaLeaf struct {
+	patriciaEncoding
+	path  []byte
+	value []byte
+}
+
+type patriciaExtension struct {
+	patriciaEncoding
+	path  []byte
+	child patriciaNode
+}
+
+type patriciaBranch struct {
+	patriciaEncoding
+	children [16]patriciaNode
+	value    []byte
+}
+
+func NewPatriciaTrieBuilder() *PatriciaTrieBuilder {
+	return &PatriciaTrieBuilder{config: &PatriciaTrieConfig{Hasher: &Hasher{Hash: KECCAK256}}}
+}
+
+// WithHasher sets the hash algorithm of the trie, Keccak-256 being the one of Ethereum
+func (b *PatriciaTrieBuilder) WithHasher(hasher *Hasher) *PatriciaTrieBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+// WithSecureKeys hashes the keys before inserting them
+func (b *PatriciaTrieBuilder) WithSecureKeys(isSecureKeys bool) *PatriciaTrieBuilder {
+	b.config.IsSecureKeys = isSecureKeys
+	return b
+}
+
+// Build creates an empty trie
+func (b *PatriciaTrieBuilder) Build() (*PatriciaTrie, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if b.config.Hasher.DigestLength != 0 || b.config.Hasher.IsSortLeaves || b.config.Hasher.IsSortPairs {
+		return nil, ErrPatriciaTrieConfigHasherIsWrong
+	}
+
+	return &PatriciaTrie{PatriciaTrieConfig: *b.config}, nil
+}
+
+// DeriveListRoot returns the root of the Keccak-256 trie of the items passed in parameter keyed by the RLP of their
+// index, i.e. the transactions or the receipts root of an Ethereum block from their serialization
+func DeriveListRoot(items [][]byte) ([]byte, error) {
+	t, err := NewPatriciaTrieBuilder().Build()
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		if err = t.Put(EncodeRLP(RLPUint(uint64(i))), item); err != nil {
+			return nil, fmt.Errorf("t.Put(%d): %w", i, err)
+		}
+	}
+	return t.Root()
+}
+
+// Size returns the nb of keys of the trie
+func (t *PatriciaTrie) Size() int {
+	return t.size
+}
+
+// Root returns the hash of the serialization of the root node, the root of an empty trie being the hash of an empty
+// string serialization
+func (t *PatriciaTrie) Root() ([]byte, error) {
+	encoded := rlpEncodeBytes(nil)
+	if t.root != nil {
+		encoded = t.root.encoding()
+	}
+	return t.Hasher.hashData(encoded)
+}
+
+// Get returns the value of the key passed in parameter
+func (t *PatriciaTrie) Get(key []byte) ([]byte, error) {
+	path, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	n := t.root
+	for n != nil {
+		switch node := n.(type) {
+		case *patriciaLeaf:
+			if bytes.Equal(node.path, path) {
+				return node.value, nil
+			}
+			n = nil
+		case *patriciaExtension:
+			if !bytes.HasPrefix(path, node.path) {
+				n = nil
+				continue
+			}
+			n, path = node.child, path[len(node.path):]
+		case *patriciaBranch:
+			if len(path) == 0 {
+				if node.value != nil {
+					return node.value, nil
+				}
+				n = nil
+				continue
+			}
+			n, path = node.children[path[0]], path[1:]
+		}
+	}
+	return nil, fmt.Errorf("key<%x>: %w", key, ErrPatriciaTrieKeyNotFound)
+}
+
+// Put sets the value of the key passed in parameter, an empty value deleting the key as Ethereum does
+func (t *PatriciaTrie) Put(key, value []byte) error {
+	if len(value) == 0 {
+		if err := t.Delete(key); err != nil && !errors.Is(err, ErrPatriciaTrieKeyNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	path, err := t.path(key)
+	if err != nil {
+		return err
+	}
+
+	root, isNew, err := t.insert(t.root, path, append([]byte{}, value...))
+	if err != nil {
+		return err
+	}
+	t.root = root
+	if isNew {
+		t.size++
+	}
+	return nil
+}
+
+// Delete removes the key passed in parameter, the nodes left with a single child being merged
+func (t *PatriciaTrie) Delete(key []byte) error {
+	path, err := t.path(key)
+	if err != nil {
+		return err
+	}
+
+	root, isFound, err := t.delete(t.root, path)
+	if err != nil {
+		return err
+	}
+	if !isFound {
+		return fmt.Errorf("key<%x>: %w", key, ErrPatriciaTrieKeyNotFound)
+	}
+	t.root = root
+	t.size--
+	return nil
+}
+
+// Proof returns the serialization of the nodes from the root down to the key passed in parameter, the nodes embedded
+// in their parent being left out as eth_getProof does
+// the proof of a key the trie does not contain proves its absence
+func (t *PatriciaTrie) Proof(key []byte) ([][]byte, error) {
+	path, err := t.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// the empty trie is proven by the serialization of an empty string
+	if t.root == nil {
+		return [][]byte{rlpEncodeBytes(nil)}, nil
+	}
+
+	var proof [][]byte
+	for n, isRoot := t.root, true; n != nil; isRoot = false {
+		encoded := n.encoding()
+		if isRoot || len(encoded) >= t.Hasher.Hash.Size() {
+			proof = append(proof, encoded)
+		}
+
+		switch node := n.(type) {
+		case *patriciaLeaf:
+			n = nil
+		case *patriciaExtension:
+			if !bytes.HasPrefix(path, node.path) {
+				n = nil
+				continue
+			}
+			n, path = node.child, path[len(node.path):]
+		case *patriciaBranch:
+			if len(path) == 0 {
+				n = nil
+				continue
+			}
+			n, path = node.children[path[0]], path[1:]
+		}
+	}
+	return proof, nil
+}
+
+// VerifyPatriciaProof walks the nodes of the proof passed in parameter from the root down to the key and returns its
+// value, nil meaning the proof proves the absence of the key
+// the key is the one of the trie, i.e. the hash of the key for secure tries
+func VerifyPatriciaProof(hash Hash, root, key []byte, proof [][]byte) ([]byte, error) {
+	if !hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), hash)
+	}
+
+	h := &Hasher{Hash: hash}
+	nodes := make(map[string][]byte, len(proof))
+	for _, encoded := range proof {
+		nodeHash, err := h.hashData(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("h.hashData(): %w", err)
+		}
+		nodes[string(nodeHash)] = encoded
+	}
+
+	var (
+		path = keyNibbles(key)
+		ref  = RLPBytes(root)
+	)
+	for {
+		// a reference is either the hash of a node of the proof or a node embedded in its parent
+		node := ref
+		if !ref.IsList {
+			if len(ref.Bytes) == 0 {
+				return nil, nil
+			}
+			encoded, ok := nodes[string(ref.Bytes)]
+			if !ok {
+				return nil, fmt.Errorf("node<%x> is missing: %w", ref.Bytes, ErrPatriciaProofIsWrong)
+			}
+			var err error
+			if node, err = DecodeRLP(encoded); err != nil {
+				return nil, fmt.Errorf("DecodeRLP(node<%x>): %w", ref.Bytes, err)
+			}
+		}
+
+		switch {
+		case node.IsList && len(node.List) == 17:
+			if len(path) == 0 {
+				if len(node.List[16].Bytes) == 0 {
+					return nil, nil
+				}
+				return node.List[16].Bytes, nil
+			}
+			ref, path = node.List[path[0]], path[1:]
+		case node.IsList && len(node.List) == 2 && !node.List[0].IsList:
+			nodePath, isLeaf, err := hexPrefixDecode(node.List[0].Bytes)
+			if err != nil {
+				return nil, err
+			}
+			if isLeaf {
+				if bytes.Equal(nodePath, path) {
+					return node.List[1].Bytes, nil
+				}
+				return nil, nil
+			}
+			if !bytes.HasPrefix(path, nodePath) {
+				return nil, nil
+			}
+			ref, path = node.List[1], path[len(nodePath):]
+		case !node.IsList && len(node.Bytes) == 0:
+			// the empty trie
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("node is neither a branch, an extension nor a leaf: %w", ErrPatriciaProofIsWrong)
+		}
+	}
+}
+
+// EncodeRLP serializes the account as it is stored in the state trie
+func (a EthereumAccount) EncodeRLP() []byte {
+	balance := a.Balance
+	if balance == nil {
+		balance = new(big.Int)
+	}
+	return EncodeRLP(RLPList(RLPUint(a.Nonce), RLPBigInt(balance), RLPBytes(a.StorageRoot), RLPBytes(a.CodeHash)))
+}
+
+// DecodeEthereumAccount deserializes an account as it is stored in the state trie
+func DecodeEthereumAccount(b []byte) (EthereumAccount, error) {
+	item, err := DecodeRLP(b)
+	if err != nil {
+		return EthereumAccount{}, err
+	}
+	if !item.IsList || len(item.List) != 4 || item.List[2].IsList || item.List[3].IsList {
+		return EthereumAccount{}, ErrRLPTypeIsWrong
+	}
+
+	a := EthereumAccount{StorageRoot: item.List[2].Bytes, CodeHash: item.List[3].Bytes}
+	if a.Nonce, err = item.List[0].Uint(); err != nil {
+		return EthereumAccount{}, fmt.Errorf("nonce: %w", err)
+	}
+	if a.Balance, err = item.List[1].BigInt(); err != nil {
+		return EthereumAccount{}, fmt.Errorf("balance: %w", err)
+	}
+	return a, nil
+}
+
+// path returns the nibbles of the key, hashed first for secure tries
+func (t *PatriciaTrie) path(key []byte) ([]byte, error) {
+	if t.IsSecureKeys {
+		var err error
+		if key, err = t.Hasher.hashData(key); err != nil {
+			return nil, fmt.Errorf("t.Hasher.hashData(key): %w", err)
+		}
+	}
+	return keyNibbles(key), nil
+}
+
+// insert returns the node replacing the node passed in parameter once the value has been inserted at the path and
+// whether the key is a new one
+func (t *PatriciaTrie) insert(n patriciaNode, path, value []byte) (patriciaNode, bool, error) {
+	switch node := n.(type) {
+	case nil:
+		leaf, err := t.newLeaf(path, value)
+		return leaf, true, err
+	case *patriciaLeaf:
+		if bytes.Equal(node.path, path) {
+			leaf, err := t.newLeaf(path, value)
+			return leaf, false, err
+		}
+
+		// the leaves are split by a branch at the end of their common path
+		var (
+			cp       = commonPrefixLength(node.path, path)
+			children [16]patriciaNode
+			v        []byte
+		)
+		if err := t.setChild(&children, &v, node.path[cp:], func(rest []byte) (patriciaNode, error) { return t.newLeaf(rest, node.value) }, node.value); err != nil {
+			return nil, false, err
+		}
+		if err := t.setChild(&children, &v, path[cp:], func(rest []byte) (patriciaNode, error) { return t.newLeaf(rest, value) }, value); err != nil {
+			return nil, false, err
+		}
+		branch, err := t.newBranch(children, v)
+		if err != nil {
+			return nil, false, err
+		}
+		n, err := t.withExtension(path[:cp], branch)
+		return n, true, err
+	case *patriciaExtension:
+		cp := commonPrefixLength(node.path, path)
+		if cp == len(node.path) {
+			child, isNew, err := t.insert(node.child, path[cp:], value)
+			if err != nil {
+				return nil, false, err
+			}
+			n, err := t.newExtension(node.path, child)
+			return n, isNew, err
+		}
+
+		// the extension is split by a branch at the end of the common path
+		var (
+			children [16]patriciaNode
+			v        []byte
+		)
+		if err := t.setChild(&children, &v, node.path[cp:], func(rest []byte) (patriciaNode, error) { return t.withExtension(rest, node.child) }, nil); err != nil {
+			return nil, false, err
+		}
+		if err := t.setChild(&children, &v, path[cp:], func(rest []byte) (patriciaNode, error) { return t.newLeaf(rest, value) }, value); err != nil {
+			return nil, false, err
+		}
+		branch, err := t.newBranch(children, v)
+		if err != nil {
+			return nil, false, err
+		}
+		n, err := t.withExtension(path[:cp], branch)
+		return n, true, err
+	case *patriciaBranch:
+		children, v := node.children, node.value
+		if len(path) == 0 {
+			branch, err := t.newBranch(children, value)
+			return branch, node.value == nil, err
+		}
+		child, isNew, err := t.insert(node.children[path[0]], path[1:], value)
+		if err != nil {
+			return nil, false, err
+		}
+		children[path[0]] = child
+		branch, err := t.newBranch(children, v)
+		return branch, isNew, err
+	}
+	panic(fmt.Sprintf("unknown patricia node %T", n))
+}
+
+// delete returns the node replacing the node passed in parameter once the key of the path has been removed and
+// whether the key has been found
+func (t *PatriciaTrie) delete(n patriciaNode, path []byte) (patriciaNode, bool, error) {
+	switch node := n.(type) {
+	case nil:
+		return nil, false, nil
+	case *patriciaLeaf:
+		if !bytes.Equal(node.path, path) {
+			return n, false, nil
+		}
+		return nil, true, nil
+	case *patriciaExtension:
+		if !bytes.HasPrefix(path, node.path) {
+			return n, false, nil
+		}
+		child, isFound, err := t.delete(node.child, path[len(node.path):])
+		if err != nil || !isFound {
+			return n, false, err
+		}
+		merged, err := t.mergePath(node.path, child)
+		return merged, true, err
+	case *patriciaBranch:
+		children, v := node.children, node.value
+		if len(path) == 0 {
+			if node.value == nil {
+				return n, false, nil
+			}
+			v = nil
+		} else {
+			child, isFound, err := t.delete(node.children[path[0]], path[1:])
+			if err != nil || !isFound {
+				return n, false, err
+			}
+			children[path[0]] = child
+		}
+		collapsed, err := t.collapse(children, v)
+		return collapsed, true, err
+	}
+	panic(fmt.Sprintf("unknown patricia node %T", n))
+}
+
+// setChild places a node at the path passed in parameter below a branch, the value being the one of the branch if
+// the path is empty, otherwise the node created from the rest of the path is placed at its first nibble
+func (t *PatriciaTrie) setChild(children *[16]patriciaNode, value *[]byte, path []byte, child func(rest []byte) (patriciaNode, error), v []byte) error {
+	if len(path) == 0 {
+		*value = v
+		return nil
+	}
+	n, err := child(path[1:])
+	if err != nil {
+		return err
+	}
+	children[path[0]] = n
+	return nil
+}
+
+// collapse returns the node replacing a branch of the children and the value passed in parameter, the branch being
+// replaced if it is left with less than two children or values
+func (t *PatriciaTrie) collapse(children [16]patriciaNode, value []byte) (patriciaNode, error) {
+	var (
+		nb    int
+		index int
+	)
+	for i, child := range children {
+		if child != nil {
+			nb++
+			index = i
+		}
+	}
+
+	switch {
+	case nb == 0 && value != nil:
+		return t.newLeaf([]byte{}, value)
+	case nb == 1 && value == nil:
+		return t.mergePath([]byte{byte(index)}, children[index])
+	}
+	return t.newBranch(children, value)
+}
+
+// mergePath returns the node made of the path passed in parameter followed by the node
+// the paths of consecutive extensions and leaves are concatenated
+func (t *PatriciaTrie) mergePath(path []byte, n patriciaNode) (patriciaNode, error) {
+	switch node := n.(type) {
+	case nil:
+		return nil, nil
+	case *patriciaLeaf:
+		return t.newLeaf(concatNibbles(path, node.path), node.value)
+	case *patriciaExtension:
+		return t.newExtension(concatNibbles(path, node.path), node.child)
+	}
+	return t.withExtension(path, n)
+}
+
+// withExtension returns the node preceded by an extension of the path passed in parameter unless the path is empty
+func (t *PatriciaTrie) withExtension(path []byte, n patriciaNode) (patriciaNode, error) {
+	if len(path) == 0 {
+		return n, nil
+	}
+	return t.newExtension(append([]byte{}, path...), n)
+}
+
+func (t *PatriciaTrie) newLeaf(path, value []byte) (patriciaNode, error) {
+	e, err := newPatriciaEncoding(t.Hasher, rlpEncodeList(rlpEncodeBytes(hexPrefixEncode(path, true)), rlpEncodeBytes(value)))
+	if err != nil {
+		return nil, err
+	}
+	return &patriciaLeaf{patriciaEncoding: e, path: path, value: value}, nil
+}
+
+func (t *PatriciaTrie) newExtension(path []byte, child patriciaNode) (patriciaNode, error) {
+	e, err := newPatriciaEncoding(t.Hasher, rlpEncodeList(rlpEncodeBytes(hexPrefixEncode(path, false)), child.reference()))
+	if err != nil {
+		return nil, err
+	}
+	return &patriciaExtension{patriciaEncoding: e, path: path, child: child}, nil
+}
+
+func (t *PatriciaTrie) newBranch(children [16]patriciaNode, value []byte) (patriciaNode, error) {
+	items := make([][]byte, 17)
+	for i, child := range children {
+		items[i] = rlpEncodeBytes(nil)
+		if child != nil {
+			items[i] = child.reference()
+		}
+	}
+	items[16] = rlpEncodeBytes(value)
+
+	e, err := newPatriciaEncoding(t.Hasher, rlpEncodeList(items...))
+	if err != nil {
+		return nil, err
+	}
+	return &patriciaBranch{patriciaEncoding: e, children: children, value: value}, nil
+}
+
+// newPatriciaEncoding returns the serialization of a node along with how it is referenced by its parent: the
+// serialization itself if it is shorter than a hash, the serialization of its hash otherwise
+func newPatriciaEncoding(h *Hasher, encoded []byte) (patriciaEncoding, error) {
+	if len(encoded) < h.Hash.Size() {
+		return patriciaEncoding{encoded: encoded, ref: encoded}, nil
+	}
+
+	nodeHash, err := h.hashData(encoded)
+	if err != nil {
+		return patriciaEncoding{}, fmt.Errorf("h.hashData(): %w", err)
+	}
+	return patriciaEncoding{encoded: encoded, ref: rlpEncodeBytes(nodeHash)}, nil
+}
+
+func (e patriciaEncoding) encoding() []byte {
+	return e.encoded
+}
+
+func (e patriciaEncoding) reference() []byte {
+	return e.ref
+}
+
+// keyNibbles splits each byte of the key in two nibbles, the most significant one first
+func keyNibbles(key []byte) []byte {
+	nibbles := make([]byte, 2*len(key))
+	for i, b := range key {
+		nibbles[2*i], nibbles[2*i+1] = b>>4, b&0x0f
+	}
+	return nibbles
+}
+
+// hexPrefixEncode packs the nibbles of a path in bytes, the first nibble flagging a leaf and an odd nb of nibbles
+func hexPrefixEncode(nibbles []byte, isLeaf bool) []byte {
+	var flag byte
+	if isLeaf {
+		flag = 2
+	}
+
+	b := make([]byte, len(nibbles)/2+1)
+	if len(nibbles)%2 == 1 {
+		b[0] = (flag+1)<<4 | nibbles[0]
+		nibbles = nibbles[1:]
+	} else {
+		b[0] = flag << 4
+	}
+	for i := 0; i < len(nibbles); i += 2 {
+		b[1+i/2] = nibbles[i]<<4 | nibbles[i+1]
+	}
+	return b
+}
+
+// hexPrefixDecode unpacks the nibbles of a path and returns whether it is the one of a leaf
+func hexPrefixDecode(b []byte) ([]byte, bool, error) {
+	if len(b) == 0 || b[0]>>4 > 3 {
+		return nil, false, fmt.Errorf("hex prefix<%x>: %w", b, ErrPatriciaProofIsWrong)
+	}
+
+	var (
+		flag    = b[0] >> 4
+		nibbles = keyNibbles(b[1:])
+	)
+	if flag&1 == 1 {
+		nibbles = append([]byte{b[0] & 0x0f}, nibbles...)
+	}
+	return nibbles, flag&2 == 2, nil
+}
+
+// commonPrefixLength returns the nb of nibbles both paths start with
+func commonPrefixLength(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// concatNibbles returns a new path made of both paths
+func concatNibbles(a, b []byte) []byte {
+	path := make([]byte, 0, len(a)+len(b))
+	return append(append(path, a...), b...)
+}
@@ -0,0 +1,324 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+const emptyPatriciaRoot = "56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421"
+
+type patriciaOperation struct {
+	key   string
+	value string
+}
+
+func TestPatriciaTrieBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		err    error
+	}{
+		{
+			name:   "build a trie without hasher should return error",
+			hasher: nil,
+			err:    ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:   "build a trie truncating its digests should return error",
+			hasher: &Hasher{Hash: KECCAK256, DigestLength: 16},
+			err:    ErrPatriciaTrieConfigHasherIsWrong,
+		},
+		{
+			name:   "build a trie sorting its pairs should return error",
+			hasher: &Hasher{Hash: KECCAK256, IsSortPairs: true},
+			err:    ErrPatriciaTrieConfigHasherIsWrong,
+		},
+		{
+			name:   "build a keccak256 trie should return the empty root",
+			hasher: &Hasher{Hash: KECCAK256, Pool: KECCAK256.NewPool()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trie, err := NewPatriciaTrieBuilder().WithHasher(tt.hasher).Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			root, err := trie.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, emptyPatriciaRoot, hex.EncodeToString(root))
+		})
+	}
+}
+
+func TestPatriciaTrie_Root(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations []patriciaOperation
+		size       int
+		expected   string
+	}{
+		{
+			name:       "an empty trie should return the hash of an empty string",
+			operations: nil,
+			expected:   emptyPatriciaRoot,
+		},
+		{
+			name: "the puppy trie should return the root of the ethereum tests",
+			operations: []patriciaOperation{
+				{key: "do", value: "verb"},
+				{key: "horse", value: "stallion"},
+				{key: "doge", value: "coin"},
+				{key: "dog", value: "puppy"},
+			},
+			size:     4,
+			expected: "5991bb8c6514148a29db676a14ac506cd2cd5775ace63c30a4fe457715e9ac84",
+		},
+		{
+			name: "the dogs trie should return the root of the ethereum tests",
+			operations: []patriciaOperation{
+				{key: "doe", value: "reindeer"},
+				{key: "dog", value: "puppy"},
+				{key: "dogglesworth", value: "cat"},
+			},
+			size:     3,
+			expected: "8aad789dff2f538bca5d8ea56e8abe10f4c7ba3a5dea95fea4cd6e7c3a1168d3",
+		},
+		{
+			name: "the empty values trie should return the root of the ethereum tests",
+			operations: []patriciaOperation{
+				{key: "do", value: "verb"},
+				{key: "ether", value: "wookiedoo"},
+				{key: "horse", value: "stallion"},
+				{key: "shaman", value: "horse"},
+				{key: "doge", value: "coin"},
+				{key: "ether", value: ""},
+				{key: "dog", value: "puppy"},
+				{key: "shaman", value: ""},
+			},
+			size:     4,
+			expected: "5991bb8c6514148a29db676a14ac506cd2cd5775ace63c30a4fe457715e9ac84",
+		},
+		{
+			name: "a trie whose keys are all deleted should return the empty root",
+			operations: []patriciaOperation{
+				{key: "do", value: "verb"},
+				{key: "dog", value: "puppy"},
+				{key: "do", value: ""},
+				{key: "dog", value: ""},
+			},
+			expected: emptyPatriciaRoot,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trie, err := NewPatriciaTrieBuilder().Build()
+			assert.NoError(t, err)
+			for _, op := range tt.operations {
+				assert.NoError(t, trie.Put([]byte(op.key), []byte(op.value)))
+			}
+			root, err := trie.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, hex.EncodeToString(root))
+			assert.Equal(t, tt.size, trie.Size())
+		})
+	}
+}
+
+func TestPatriciaTrie_GetAndDelete(t *testing.T) {
+	trie, err := NewPatriciaTrieBuilder().WithSecureKeys(true).Build()
+	assert.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, trie.Put([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))))
+	}
+	assert.Equal(t, 100, trie.Size())
+
+	value, err := trie.Get([]byte("key42"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value42"), value)
+
+	_, err = trie.Get([]byte("key100"))
+	assert.ErrorIs(t, err, ErrPatriciaTrieKeyNotFound)
+	assert.ErrorIs(t, trie.Delete([]byte("key100")), ErrPatriciaTrieKeyNotFound)
+
+	// deleting every key inserted after the first ten ones should return the root of a trie of the ten first keys
+	for i := 10; i < 100; i++ {
+		assert.NoError(t, trie.Delete([]byte(fmt.Sprintf("key%d", i))))
+	}
+	expected, err := NewPatriciaTrieBuilder().WithSecureKeys(true).Build()
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, expected.Put([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))))
+	}
+
+	root, err := trie.Root()
+	assert.NoError(t, err)
+	expectedRoot, err := expected.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot, root)
+	assert.Equal(t, 10, trie.Size())
+}
+
+func TestPatriciaTrie_Proof(t *testing.T) {
+	trie, err := NewPatriciaTrieBuilder().Build()
+	assert.NoError(t, err)
+
+	// an empty trie should prove the absence of any key
+	root, err := trie.Root()
+	assert.NoError(t, err)
+	proof, err := trie.Proof([]byte("dog"))
+	assert.NoError(t, err)
+	value, err := VerifyPatriciaProof(KECCAK256, root, []byte("dog"), proof)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	for _, op := range []patriciaOperation{
+		{key: "doe", value: "reindeer"},
+		{key: "dog", value: "puppy"},
+		{key: "dogglesworth", value: "cat"},
+		{key: "horse", value: "stallion with a value long enough not to be embedded in its parent"},
+	} {
+		assert.NoError(t, trie.Put([]byte(op.key), []byte(op.value)))
+	}
+	root, err = trie.Root()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		key      string
+		expected []byte
+	}{
+		{
+			name:     "prove an embedded key should return its value",
+			key:      "dog",
+			expected: []byte("puppy"),
+		},
+		{
+			name:     "prove a key referenced by its hash should return its value",
+			key:      "horse",
+			expected: []byte("stallion with a value long enough not to be embedded in its parent"),
+		},
+		{
+			name:     "prove a key below an extension should return its value",
+			key:      "dogglesworth",
+			expected: []byte("cat"),
+		},
+		{
+			name: "prove a missing key ending in a branch should return no value",
+			key:  "do",
+		},
+		{
+			name: "prove a missing key diverging from a leaf should return no value",
+			key:  "horses",
+		},
+		{
+			name: "prove a missing key diverging from the root should return no value",
+			key:  "cat",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof, err := trie.Proof([]byte(tt.key))
+			assert.NoError(t, err)
+
+			value, err := VerifyPatriciaProof(KECCAK256, root, []byte(tt.key), proof)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+
+			// a proof should not be valid against another root
+			_, err = VerifyPatriciaProof(KECCAK256, make([]byte, 32), []byte(tt.key), proof)
+			assert.ErrorIs(t, err, ErrPatriciaProofIsWrong)
+		})
+	}
+}
+
+func TestPatriciaTrie_ConcurrentReads(t *testing.T) {
+	trie, err := NewPatriciaTrieBuilder().Build()
+	assert.NoError(t, err)
+	expected, err := NewPatriciaTrieBuilder().Build()
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, trie.Put([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))))
+		assert.NoError(t, expected.Put([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))))
+	}
+	// the trie is only read by the goroutines so that a serialization computed while reading would race
+	root, err := expected.Root()
+	assert.NoError(t, err)
+
+	// the nodes are serialized once created so that a trie can be read concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got, err := trie.Root()
+			assert.NoError(t, err)
+			assert.Equal(t, root, got)
+
+			key := []byte(fmt.Sprintf("key%d", i))
+			proof, err := trie.Proof(key)
+			assert.NoError(t, err)
+			value, err := VerifyPatriciaProof(KECCAK256, root, key, proof)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(fmt.Sprintf("value%d", i)), value)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDeriveListRoot(t *testing.T) {
+	root, err := DeriveListRoot(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, emptyPatriciaRoot, hex.EncodeToString(root))
+
+	// the transactions root of the block of the ethereum/tests fixtures decoded by the go-ethereum block tests
+	tx, err := hex.DecodeString("f85f800a82c35094095e7baea6a6c7c4c2dfeb977efac326af552d870a801ba09bea4c4daac7c7c52e093e6a4c35dbbcf8856f1af7b059ba20253e70848d094fa08a8fae537ce25ed8cb5af9adac3f141af69bd515bd2ba031522df09b97dd72b1")
+	assert.NoError(t, err)
+	root, err = DeriveListRoot([][]byte{tx})
+	assert.NoError(t, err)
+	assert.Equal(t, "5fe50b260da6308036625b850b5d6ced6d0a9f814c0688bc91ffb7b7a3a54b67", hex.EncodeToString(root))
+
+	// the keys of the items are the rlp of their index so that the item 0 is keyed by 0x80
+	items := make([][]byte, 200)
+	expected, err := NewPatriciaTrieBuilder().Build()
+	assert.NoError(t, err)
+	for i := range items {
+		items[i] = EncodeRLP(RLPList(RLPUint(uint64(i)), RLPBytes([]byte("transaction"))))
+		assert.NoError(t, expected.Put(EncodeRLP(RLPUint(uint64(i))), items[i]))
+	}
+	root, err = DeriveListRoot(items)
+	assert.NoError(t, err)
+	expectedRoot, err := expected.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot, root)
+}
+
+func TestEthereumAccount_EncodeRLP(t *testing.T) {
+	emptyCodeHash, err := hex.DecodeString("c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470")
+	assert.NoError(t, err)
+	emptyRoot, err := hex.DecodeString(emptyPatriciaRoot)
+	assert.NoError(t, err)
+
+	account := EthereumAccount{
+		Nonce:       1,
+		Balance:     new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil),
+		StorageRoot: emptyRoot,
+		CodeHash:    emptyCodeHash,
+	}
+	decoded, err := DecodeEthereumAccount(account.EncodeRLP())
+	assert.NoError(t, err)
+	assert.Equal(t, account, decoded)
+
+	_, err = DecodeEthereumAccount(EncodeRLP(RLPList(RLPUint(1))))
+	assert.ErrorIs(t, err, ErrRLPTypeIsWrong)
+}
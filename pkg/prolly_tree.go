@@ -0,0 +1,583 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ProllyTree is a probabilistic B-tree over sorted key-value pairs whose node boundaries are defined by the content
+// a rolling sum of the hashes of the last WindowSize items of a level decides whether a node ends after an item, so
+// that the shape of the tree only depends on its content and an edit only changes the nodes around it, i.e.
+// O(log n) nodes, two versions sharing all their other nodes
+// the hash of an entry is the hash of its key hash and its value hash, the hash of a node being the hash of its items
+type ProllyTree struct {
+	ProllyTreeConfig
+	levels [][]*prollyNode
+	size   int
+}
+
+// ProllyTreeConfig is the configuration that represents the options used to build the tree
+// TargetChunkSize is the average nb of items of a node and WindowSize the nb of items the rolling hash is computed on
+type ProllyTreeConfig struct {
+	Hasher          *Hasher
+	TargetChunkSize uint32
+	WindowSize      uint32
+}
+
+// ProllyTreeBuilder allows use to pass the configuration before building a prolly tree
+type ProllyTreeBuilder struct {
+	config *ProllyTreeConfig
+}
+
+// ProllyTreeDiff is a key whose value differs between two trees, Old being nil for a created key and New being nil for
+// a deleted key
+type ProllyTreeDiff struct {
+	Key []byte
+	Old Data
+	New Data
+}
+
+// prollyNode is a node of a level, level 0 nodes holding the entries and the others holding their children
+type prollyNode struct {
+	level int
+	items []prollyItem
+	hash  []byte
+}
+
+// prollyItem is either an entry or a child, the key of a child being the last key of its subtree
+type prollyItem struct {
+	key   []byte
+	hash  []byte
+	value Data
+	child *prollyNode
+}
+
+const (
+	// DefaultProllyTreeTargetChunkSize is the default average nb of items of a node
+	DefaultProllyTreeTargetChunkSize = 16
+	// DefaultProllyTreeWindowSize is the default nb of items the rolling hash is computed on
+	DefaultProllyTreeWindowSize = 4
+	// prollyMinChunkSize guarantees that each level has fewer nodes than the level below
+	prollyMinChunkSize = 2
+)
+
+var (
+	ErrProllyTreeConfigHasherIsWrong    = errors.New("the prolly tree hasher cannot sort leaves or pairs as entries are sorted by key")
+	ErrProllyTreeConfigChunkSizeIsWrong = errors.New("the prolly tree target chunk size must be greater or equal to 2")
+	ErrProllyTreeConfigWindowIsWrong    = errors.New("the prolly tree window size cannot be equal to 0")
+	ErrProllyTreeKeyNotFound            = errors.New("the prolly tree does not contain the key")
+	ErrProllyTreeKeyIsEmpty             = errors.New("the prolly tree key cannot be empty")
+	ErrProllyTreeHashIsDifferent        = errors.New("the prolly trees are not built with the same configuration")
+)
+
+func NewProllyTreeBuilder() *ProllyTreeBuilder {
+	return &ProllyTreeBuilder{config: &ProllyTreeConfig{
+		TargetChunkSize: DefaultProllyTreeTargetChunkSize,
+		WindowSize:      DefaultProllyTreeWindowSize,
+	}}
+}
+
+func (b *ProllyTreeBuilder) WithHasher(hasher *Hasher) *ProllyTreeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+// WithTargetChunkSize sets the average nb of items of a node, a node ending after an item with a probability of
+// 1/targetChunkSize
+func (b *ProllyTreeBuilder) WithTargetChunkSize(targetChunkSize uint32) *ProllyTreeBuilder {
+	b.config.TargetChunkSize = targetChunkSize
+	return b
+}
+
+// WithWindowSize sets the nb of items the rolling hash is computed on, an edit changing the boundaries of up to
+// windowSize items
+func (b *ProllyTreeBuilder) WithWindowSize(windowSize uint32) *ProllyTreeBuilder {
+	b.config.WindowSize = windowSize
+	return b
+}
+
+// Build creates an empty tree
+func (b *ProllyTreeBuilder) Build() (*ProllyTree, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if b.config.Hasher.IsSortLeaves || b.config.Hasher.IsSortPairs {
+		return nil, ErrProllyTreeConfigHasherIsWrong
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if err := b.config.Hasher.Hash.ValidateDigestLength(b.config.Hasher.DigestLength); err != nil {
+		return nil, fmt.Errorf("Hash.ValidateDigestLength(): %w", err)
+	}
+
+	if b.config.TargetChunkSize < prollyMinChunkSize {
+		return nil, fmt.Errorf("targetChunkSize<%d>: %w", b.config.TargetChunkSize, ErrProllyTreeConfigChunkSizeIsWrong)
+	}
+
+	if b.config.WindowSize == 0 {
+		return nil, ErrProllyTreeConfigWindowIsWrong
+	}
+
+	return &ProllyTree{ProllyTreeConfig: *b.config}, nil
+}
+
+// Size returns the nb of entries of the tree
+func (t *ProllyTree) Size() int {
+	return t.size
+}
+
+// Height returns the nb of levels of the tree
+func (t *ProllyTree) Height() int {
+	return len(t.levels)
+}
+
+// Root returns the hash of the root node, the root of an empty tree being the hash of no item
+func (t *ProllyTree) Root() ([]byte, error) {
+	if len(t.levels) == 0 {
+		return t.Hasher.hashChildren()
+	}
+	return t.levels[len(t.levels)-1][0].hash, nil
+}
+
+// Clone returns a version of the tree that is not affected by the next edits of the tree, all the nodes being shared
+// only the lists of nodes of each level are copied
+func (t *ProllyTree) Clone() *ProllyTree {
+	levels := make([][]*prollyNode, len(t.levels))
+	for i, level := range t.levels {
+		levels[i] = append([]*prollyNode{}, level...)
+	}
+	return &ProllyTree{ProllyTreeConfig: t.ProllyTreeConfig, levels: levels, size: t.size}
+}
+
+// Get returns the value of the key passed in parameter
+func (t *ProllyTree) Get(key []byte) (Data, error) {
+	if len(t.levels) == 0 {
+		return nil, fmt.Errorf("key<%x>: %w", key, ErrProllyTreeKeyNotFound)
+	}
+
+	n := t.levels[len(t.levels)-1][0]
+	for {
+		i := n.search(key)
+		if i == len(n.items) {
+			return nil, fmt.Errorf("key<%x>: %w", key, ErrProllyTreeKeyNotFound)
+		}
+		if n.level == 0 {
+			if !bytes.Equal(n.items[i].key, key) {
+				return nil, fmt.Errorf("key<%x>: %w", key, ErrProllyTreeKeyNotFound)
+			}
+			return n.items[i].value, nil
+		}
+		n = n.items[i].child
+	}
+}
+
+// Put sets the value of the key passed in parameter
+// the leaf node of the key is chunked again along with the next nodes until the boundaries match the former ones,
+// the nodes replaced at a level being the items replaced at the level above
+func (t *ProllyTree) Put(key []byte, value Data) error {
+	if len(key) == 0 {
+		return ErrProllyTreeKeyIsEmpty
+	}
+
+	item, err := t.newEntry(key, value)
+	if err != nil {
+		return err
+	}
+
+	if len(t.levels) == 0 {
+		node, err := t.newNode(0, []prollyItem{item})
+		if err != nil {
+			return err
+		}
+		t.levels, t.size = [][]*prollyNode{{node}}, 1
+		return nil
+	}
+
+	first := t.leafIndex(key)
+	items := t.levels[0][first].items
+	i := t.levels[0][first].search(key)
+
+	seq := make([]prollyItem, 0, len(items)+1)
+	seq = append(seq, items[:i]...)
+	seq = append(seq, item)
+	if i < len(items) && bytes.Equal(items[i].key, key) {
+		seq = append(seq, items[i+1:]...)
+	} else {
+		seq = append(seq, items[i:]...)
+		t.size++
+	}
+	return t.apply(0, first, seq, first+1, i+1)
+}
+
+// Delete removes the key passed in parameter
+func (t *ProllyTree) Delete(key []byte) error {
+	if len(t.levels) == 0 {
+		return fmt.Errorf("key<%x>: %w", key, ErrProllyTreeKeyNotFound)
+	}
+
+	first := t.leafIndex(key)
+	items := t.levels[0][first].items
+	i := t.levels[0][first].search(key)
+	if i == len(items) || !bytes.Equal(items[i].key, key) {
+		return fmt.Errorf("key<%x>: %w", key, ErrProllyTreeKeyNotFound)
+	}
+
+	seq := append(append(make([]prollyItem, 0, len(items)-1), items[:i]...), items[i+1:]...)
+	t.size--
+	return t.apply(0, first, seq, first+1, i)
+}
+
+// Walk calls the function passed in parameter for each entry of the tree in order
+func (t *ProllyTree) Walk(fn func(key []byte, value Data) error) error {
+	if len(t.levels) == 0 {
+		return nil
+	}
+	for _, n := range t.levels[0] {
+		for _, item := range n.items {
+			if err := fn(item.key, item.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Diff returns the keys whose value differs between the tree and the other tree passed in parameter, in order
+// both trees are walked at once and the nodes of the same level with the same hash are skipped
+func (t *ProllyTree) Diff(other *ProllyTree) ([]ProllyTreeDiff, error) {
+	if t.Hasher.Hash != other.Hasher.Hash || t.Hasher.DigestLength != other.Hasher.DigestLength ||
+		t.TargetChunkSize != other.TargetChunkSize || t.WindowSize != other.WindowSize {
+		return nil, ErrProllyTreeHashIsDifferent
+	}
+
+	var (
+		diffs []ProllyTreeDiff
+		a     = newProllyCursor(t)
+		b     = newProllyCursor(other)
+	)
+	for !a.isDone() || !b.isDone() {
+		switch {
+		case !a.isDone() && a.node() != nil && (b.isDone() || b.node() == nil):
+			a.expand()
+		case !b.isDone() && b.node() != nil && (a.isDone() || a.node() == nil):
+			b.expand()
+		case a.isDone():
+			diffs = append(diffs, ProllyTreeDiff{Key: b.item().key, New: b.item().value})
+			b.next()
+		case b.isDone():
+			diffs = append(diffs, ProllyTreeDiff{Key: a.item().key, Old: a.item().value})
+			a.next()
+		case a.node() != nil && b.node() != nil:
+			levelA, levelB := a.node().level, b.node().level
+			if levelA == levelB && bytes.Equal(a.node().hash, b.node().hash) {
+				a.next()
+				b.next()
+				continue
+			}
+			// the higher node is expanded first so that both cursors may meet nodes of the same level
+			if levelA >= levelB {
+				a.expand()
+			}
+			if levelB >= levelA {
+				b.expand()
+			}
+		default:
+			switch c := bytes.Compare(a.item().key, b.item().key); {
+			case c < 0:
+				diffs = append(diffs, ProllyTreeDiff{Key: a.item().key, Old: a.item().value})
+				a.next()
+			case c > 0:
+				diffs = append(diffs, ProllyTreeDiff{Key: b.item().key, New: b.item().value})
+				b.next()
+			default:
+				if !bytes.Equal(a.item().hash, b.item().hash) {
+					diffs = append(diffs, ProllyTreeDiff{Key: a.item().key, Old: a.item().value, New: b.item().value})
+				}
+				a.next()
+				b.next()
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// apply replaces the nodes of the level from the index first to the index next (excluded) by the nodes chunked from
+// seq, the items of seq from modEnd being the unmodified items of those nodes
+// the next nodes are chunked again as long as the new boundaries do not match the former ones, then the replaced nodes
+// are replaced at the level above
+func (t *ProllyTree) apply(level, first int, seq []prollyItem, next, modEnd int) error {
+	nodes := t.levels[level]
+
+	c := t.newChunker(level)
+	// the rolling hash of the first item depends on the items before it
+	for i, nb := first-1, 0; i >= 0 && nb < int(t.WindowSize)-1; i-- {
+		for j := len(nodes[i].items) - 1; j >= 0 && nb < int(t.WindowSize)-1; j-- {
+			c.prime(nodes[i].items[j].hash, nb)
+			nb++
+		}
+	}
+
+	for _, item := range seq {
+		if err := c.add(item); err != nil {
+			return err
+		}
+	}
+	end, nbUnmodified := next, len(seq)-modEnd
+	for (!c.isAligned() || nbUnmodified < int(t.WindowSize)-1) && end < len(nodes) {
+		for _, item := range nodes[end].items {
+			if err := c.add(item); err != nil {
+				return err
+			}
+			nbUnmodified++
+		}
+		end++
+	}
+	if err := c.flush(); err != nil {
+		return err
+	}
+
+	replaced := nodes[first:end]
+	t.levels[level] = append(append(append(make([]*prollyNode, 0, len(nodes)-len(replaced)+len(c.nodes)),
+		nodes[:first]...), c.nodes...), nodes[end:]...)
+
+	switch {
+	case len(t.levels[level]) == 0:
+		t.levels = nil
+		return nil
+	case level < len(t.levels)-1 && len(replaced) > 0:
+		if err := t.applyAbove(level+1, replaced, c.nodes); err != nil {
+			return err
+		}
+	case level == len(t.levels)-1 && len(t.levels[level]) > 1:
+		if err := t.grow(); err != nil {
+			return err
+		}
+	}
+
+	// the root is the first level with a single node
+	for i, nodes := range t.levels {
+		if len(nodes) == 1 {
+			t.levels = t.levels[:i+1]
+			break
+		}
+	}
+	return nil
+}
+
+// applyAbove replaces the items of the replaced nodes of the level below by the items of the new nodes
+func (t *ProllyTree) applyAbove(level int, replaced, nodes []*prollyNode) error {
+	parents := t.levels[level]
+	key := replaced[0].lastKey()
+	first := sort.Search(len(parents), func(i int) bool {
+		return bytes.Compare(parents[i].lastKey(), key) >= 0
+	})
+	offset := parents[first].search(key)
+
+	seq := append([]prollyItem{}, parents[first].items[:offset]...)
+	for _, n := range nodes {
+		seq = append(seq, n.item())
+	}
+	modEnd := len(seq)
+
+	// skip the items of the replaced nodes that may span several parents
+	p, i := first, offset
+	for remaining := len(replaced); remaining > 0; {
+		if available := len(parents[p].items) - i; remaining < available {
+			i += remaining
+			remaining = 0
+		} else {
+			remaining -= available
+			p, i = p+1, 0
+		}
+	}
+	if p < len(parents) {
+		seq = append(seq, parents[p].items[i:]...)
+		p++
+	}
+	return t.apply(level, first, seq, p, modEnd)
+}
+
+// grow adds levels on top of the tree until a level has a single node
+func (t *ProllyTree) grow() error {
+	for len(t.levels[len(t.levels)-1]) > 1 {
+		level := len(t.levels)
+		c := t.newChunker(level)
+		for _, n := range t.levels[level-1] {
+			if err := c.add(n.item()); err != nil {
+				return err
+			}
+		}
+		if err := c.flush(); err != nil {
+			return err
+		}
+		t.levels = append(t.levels, c.nodes)
+	}
+	return nil
+}
+
+// leafIndex returns the index of the leaf node the key belongs to, the last one if the key is greater than all keys
+func (t *ProllyTree) leafIndex(key []byte) int {
+	leaves := t.levels[0]
+	i := sort.Search(len(leaves), func(i int) bool {
+		return bytes.Compare(leaves[i].lastKey(), key) >= 0
+	})
+	if i == len(leaves) {
+		return i - 1
+	}
+	return i
+}
+
+// newEntry hashes the key and the value of an entry together
+func (t *ProllyTree) newEntry(key []byte, value Data) (prollyItem, error) {
+	keyHash, err := t.Hasher.hashData(key)
+	if err != nil {
+		return prollyItem{}, fmt.Errorf("t.Hasher.hashData(key): %w", err)
+	}
+	valueHash, err := value.Hash(t.Hasher)
+	if err != nil {
+		return prollyItem{}, fmt.Errorf("value.Hash(): data<%s>: %w", value, err)
+	}
+	hash, err := t.Hasher.hashChildren(keyHash, valueHash)
+	if err != nil {
+		return prollyItem{}, fmt.Errorf("t.Hasher.hashChildren(): %w", err)
+	}
+	return prollyItem{key: append([]byte{}, key...), hash: hash, value: value}, nil
+}
+
+func (t *ProllyTree) newNode(level int, items []prollyItem) (*prollyNode, error) {
+	hashes := make([][]byte, len(items))
+	for i, item := range items {
+		hashes[i] = item.hash
+	}
+	hash, err := t.Hasher.hashChildren(hashes...)
+	if err != nil {
+		return nil, fmt.Errorf("t.Hasher.hashChildren(): %w", err)
+	}
+	return &prollyNode{level: level, items: items, hash: hash}, nil
+}
+
+// search returns the index of the first item whose key is greater or equal to the key passed in parameter
+func (n *prollyNode) search(key []byte) int {
+	return sort.Search(len(n.items), func(i int) bool {
+		return bytes.Compare(n.items[i].key, key) >= 0
+	})
+}
+
+func (n *prollyNode) lastKey() []byte {
+	return n.items[len(n.items)-1].key
+}
+
+// item returns the item of the node within its parent
+func (n *prollyNode) item() prollyItem {
+	return prollyItem{key: n.lastKey(), hash: n.hash, child: n}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// prollyChunker splits the items of a level in nodes, a node ending after an item when the rolling sum of the last
+// window items hashes is a multiple of the target chunk size
+type prollyChunker struct {
+	t      *ProllyTree
+	level  int
+	window []uint64
+	pos    int
+	sum    uint64
+	chunk  []prollyItem
+	nodes  []*prollyNode
+}
+
+func (t *ProllyTree) newChunker(level int) *prollyChunker {
+	return &prollyChunker{t: t, level: level, window: make([]uint64, t.WindowSize)}
+}
+
+// prime sets the hash of the item placed i items before the first item added to the chunker
+func (c *prollyChunker) prime(hash []byte, i int) {
+	v := binary.BigEndian.Uint64(hash)
+	c.window[len(c.window)-1-i] = v
+	c.sum += v
+}
+
+func (c *prollyChunker) add(item prollyItem) error {
+	v := binary.BigEndian.Uint64(item.hash)
+	c.sum += v - c.window[c.pos]
+	c.window[c.pos] = v
+	c.pos = (c.pos + 1) % len(c.window)
+
+	c.chunk = append(c.chunk, item)
+	if len(c.chunk) >= prollyMinChunkSize && c.sum%uint64(c.t.TargetChunkSize) == 0 {
+		return c.flush()
+	}
+	return nil
+}
+
+// flush ends the current node
+func (c *prollyChunker) flush() error {
+	if len(c.chunk) == 0 {
+		return nil
+	}
+	node, err := c.t.newNode(c.level, c.chunk)
+	if err != nil {
+		return err
+	}
+	c.nodes = append(c.nodes, node)
+	c.chunk = nil
+	return nil
+}
+
+// isAligned returns true if the last item added ended a node
+func (c *prollyChunker) isAligned() bool {
+	return len(c.chunk) == 0
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// prollyCursor walks a tree in order, a position being either a node that can be expanded or an entry
+type prollyCursor struct {
+	stack [][]prollyItem
+}
+
+func newProllyCursor(t *ProllyTree) *prollyCursor {
+	c := &prollyCursor{}
+	if len(t.levels) > 0 {
+		c.stack = [][]prollyItem{{t.levels[len(t.levels)-1][0].item()}}
+	}
+	return c
+}
+
+func (c *prollyCursor) isDone() bool {
+	return len(c.stack) == 0
+}
+
+func (c *prollyCursor) item() prollyItem {
+	return c.stack[len(c.stack)-1][0]
+}
+
+func (c *prollyCursor) node() *prollyNode {
+	return c.item().child
+}
+
+// next skips the current position
+func (c *prollyCursor) next() {
+	c.stack[len(c.stack)-1] = c.stack[len(c.stack)-1][1:]
+	if len(c.stack[len(c.stack)-1]) == 0 {
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+}
+
+// expand replaces the current node by its items
+func (c *prollyCursor) expand() {
+	n := c.node()
+	c.next()
+	c.stack = append(c.stack, n.items)
+}
@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestProllyTreeBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name            string
+		hasher          *Hasher
+		targetChunkSize uint32
+		windowSize      uint32
+		err             error
+	}{
+		{
+			name:            "build a prolly tree without hasher should return error",
+			hasher:          nil,
+			targetChunkSize: DefaultProllyTreeTargetChunkSize,
+			windowSize:      DefaultProllyTreeWindowSize,
+			err:             ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:            "build a prolly tree sorting its pairs should return error",
+			hasher:          &Hasher{Hash: SHA256, IsSortPairs: true},
+			targetChunkSize: DefaultProllyTreeTargetChunkSize,
+			windowSize:      DefaultProllyTreeWindowSize,
+			err:             ErrProllyTreeConfigHasherIsWrong,
+		},
+		{
+			name:            "build a prolly tree with a chunk size of 1 should return error",
+			hasher:          &Hasher{Hash: SHA256},
+			targetChunkSize: 1,
+			windowSize:      DefaultProllyTreeWindowSize,
+			err:             ErrProllyTreeConfigChunkSizeIsWrong,
+		},
+		{
+			name:            "build a prolly tree without window should return error",
+			hasher:          &Hasher{Hash: SHA256},
+			targetChunkSize: DefaultProllyTreeTargetChunkSize,
+			windowSize:      0,
+			err:             ErrProllyTreeConfigWindowIsWrong,
+		},
+		{
+			name:            "build a prolly tree should return an empty tree",
+			hasher:          &Hasher{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+			targetChunkSize: DefaultProllyTreeTargetChunkSize,
+			windowSize:      DefaultProllyTreeWindowSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewProllyTreeBuilder().
+				WithHasher(tt.hasher).
+				WithTargetChunkSize(tt.targetChunkSize).
+				WithWindowSize(tt.windowSize).
+				Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			root, err := tree.Root()
+			assert.NoError(t, err)
+			expected, err := tt.hasher.hashChildren()
+			assert.NoError(t, err)
+			assert.Equal(t, expected, root)
+			assert.Equal(t, 0, tree.Height())
+		})
+	}
+
+	// an unknown hash algorithm is rejected instead of panicking once the hashes are computed
+	_, err := NewProllyTreeBuilder().WithHasher(&Hasher{Hash: "md5"}).Build()
+	assert.EqualError(t, err, fmt.Sprintf(ErrHashNotAllowed.Error(), "md5"))
+}
+
+func TestProllyTree_HistoryIndependence(t *testing.T) {
+	for _, config := range []struct {
+		hasher          *Hasher
+		targetChunkSize uint32
+		windowSize      uint32
+	}{
+		{hasher: &Hasher{Hash: SHA256}, targetChunkSize: 4, windowSize: 1},
+		{hasher: &Hasher{Hash: SHA256}, targetChunkSize: DefaultProllyTreeTargetChunkSize, windowSize: DefaultProllyTreeWindowSize},
+		{hasher: &Hasher{Hash: SHA512, DigestLength: 32}, targetChunkSize: 3, windowSize: 8},
+	} {
+		t.Run(fmt.Sprintf("%s chunk<%d> window<%d>", config.hasher.Hash, config.targetChunkSize, config.windowSize), func(t *testing.T) {
+			newTree := func() *ProllyTree {
+				tree, err := NewProllyTreeBuilder().
+					WithHasher(config.hasher).
+					WithTargetChunkSize(config.targetChunkSize).
+					WithWindowSize(config.windowSize).
+					Build()
+				assert.NoError(t, err)
+				return tree
+			}
+
+			keys := make([][]byte, 1000)
+			for i := range keys {
+				keys[i] = []byte(fmt.Sprintf("key%05d", i))
+			}
+			expected := buildProllyTree(t, newTree(), keys)
+			expectedRoot, err := expected.Root()
+			assert.NoError(t, err)
+			assert.Greater(t, expected.Height(), 1)
+
+			// putting the keys in another order along with keys deleted afterwards should return the same tree
+			r := rand.New(rand.NewSource(42))
+			for run := 0; run < 3; run++ {
+				tree := newTree()
+				for _, i := range r.Perm(len(keys)) {
+					assert.NoError(t, tree.Put(keys[i], StringData{Value: "old"}))
+					assert.NoError(t, tree.Put(append(keys[i], 'x'), StringData{Value: "deleted"}))
+					assert.NoError(t, tree.Put(keys[i], StringData{Value: string(keys[i])}))
+				}
+				for _, i := range r.Perm(len(keys)) {
+					assert.NoError(t, tree.Delete(append(keys[i], 'x')))
+				}
+
+				root, err := tree.Root()
+				assert.NoError(t, err)
+				assert.Equal(t, expectedRoot, root)
+				assert.Equal(t, expected.Height(), tree.Height())
+				assert.Equal(t, len(keys), tree.Size())
+			}
+
+			// deleting every key should return the empty root
+			for _, i := range r.Perm(len(keys)) {
+				assert.NoError(t, expected.Delete(keys[i]))
+			}
+			root, err := expected.Root()
+			assert.NoError(t, err)
+			emptyRoot, err := config.hasher.hashChildren()
+			assert.NoError(t, err)
+			assert.Equal(t, emptyRoot, root)
+		})
+	}
+}
+
+func TestProllyTree_Put(t *testing.T) {
+	tree, err := NewProllyTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).Build()
+	assert.NoError(t, err)
+
+	keys := make([][]byte, 5000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key%05d", i))
+	}
+	tree = buildProllyTree(t, tree, keys)
+
+	// a small edit should only replace the nodes around the key on each level
+	previous := tree.Clone()
+	assert.NoError(t, tree.Put([]byte("key02500"), StringData{Value: "updated"}))
+
+	shared := make(map[*prollyNode]bool)
+	for _, level := range previous.levels {
+		for _, n := range level {
+			shared[n] = true
+		}
+	}
+	var nbNew int
+	for _, level := range tree.levels {
+		for _, n := range level {
+			if !shared[n] {
+				nbNew++
+			}
+		}
+	}
+	assert.LessOrEqual(t, nbNew, 4*tree.Height())
+
+	value, err := tree.Get([]byte("key02500"))
+	assert.NoError(t, err)
+	assert.Equal(t, StringData{Value: "updated"}, value)
+	value, err = previous.Get([]byte("key02500"))
+	assert.NoError(t, err)
+	assert.Equal(t, StringData{Value: "key02500"}, value)
+
+	_, err = tree.Get([]byte("key99999"))
+	assert.ErrorIs(t, err, ErrProllyTreeKeyNotFound)
+	_, err = tree.Get([]byte("key02500x"))
+	assert.ErrorIs(t, err, ErrProllyTreeKeyNotFound)
+	assert.ErrorIs(t, tree.Delete([]byte("key99999")), ErrProllyTreeKeyNotFound)
+	assert.ErrorIs(t, tree.Put(nil, StringData{Value: "value"}), ErrProllyTreeKeyIsEmpty)
+
+	var nb int
+	assert.NoError(t, tree.Walk(func(key []byte, value Data) error {
+		assert.Equal(t, keys[nb], key)
+		nb++
+		return nil
+	}))
+	assert.Equal(t, len(keys), nb)
+}
+
+func TestProllyTree_Diff(t *testing.T) {
+	a, err := NewProllyTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).Build()
+	assert.NoError(t, err)
+
+	keys := make([][]byte, 2000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key%05d", i))
+	}
+	a = buildProllyTree(t, a, keys)
+	b := a.Clone()
+
+	diffs, err := a.Diff(b)
+	assert.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	assert.NoError(t, b.Delete([]byte("key00007")))
+	assert.NoError(t, b.Put([]byte("key01000"), StringData{Value: "updated"}))
+	assert.NoError(t, b.Put([]byte("key99999"), StringData{Value: "created"}))
+	assert.NoError(t, b.Put([]byte("a"), StringData{Value: "created"}))
+
+	diffs, err = a.Diff(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []ProllyTreeDiff{
+		{Key: []byte("a"), New: StringData{Value: "created"}},
+		{Key: []byte("key00007"), Old: StringData{Value: "key00007"}},
+		{Key: []byte("key01000"), Old: StringData{Value: "key01000"}, New: StringData{Value: "updated"}},
+		{Key: []byte("key99999"), New: StringData{Value: "created"}},
+	}, diffs)
+
+	empty, err := NewProllyTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).Build()
+	assert.NoError(t, err)
+	diffs, err = a.Diff(empty)
+	assert.NoError(t, err)
+	assert.Len(t, diffs, len(keys))
+
+	other, err := NewProllyTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).WithWindowSize(2).Build()
+	assert.NoError(t, err)
+	_, err = a.Diff(other)
+	assert.ErrorIs(t, err, ErrProllyTreeHashIsDifferent)
+}
+
+// buildProllyTree chunks the sorted keys level by level without any edit, each value being its key
+func buildProllyTree(t *testing.T, tree *ProllyTree, keys [][]byte) *ProllyTree {
+	c := tree.newChunker(0)
+	for _, key := range keys {
+		item, err := tree.newEntry(key, StringData{Value: string(key)})
+		assert.NoError(t, err)
+		assert.NoError(t, c.add(item))
+	}
+	assert.NoError(t, c.flush())
+
+	tree.levels, tree.size = [][]*prollyNode{c.nodes}, len(keys)
+	assert.NoError(t, tree.grow())
+	return tree
+}
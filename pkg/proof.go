@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Proof is an inclusion proof of a leaf computed with one of the hash algorithms of the tree
+// Steps are ordered from the leaf up to the root
+// DigestLength is the length parent node hashes have been truncated to, 0 meaning no truncation
+// OddNodeStrategy is the strategy the tree has been built with, unpaired nodes not producing any step with PROMOTE
+// Arity is the number of children per parent node, each step carrying up to arity-1 siblings
+type Proof struct {
+	Hash            Hash
+	IsSortPairs     bool
+	DigestLength    uint32
+	OddNodeStrategy OddNodeStrategy
+	Arity           uint32
+	Index           int
+	Leaf            []byte
+	Steps           []ProofStep
+}
+
+// ProofStep represents the siblings a node is grouped with at a given level of the tree
+// Position is the position of the node among its siblings once hashed together
+type ProofStep struct {
+	Siblings [][]byte
+	Position int
+}
+
+var (
+	ErrProofIsNil       = errors.New("the merkle proof cannot be nil")
+	ErrProofStepIsWrong = errors.New("the merkle proof step position or nb of siblings is not consistent with the arity")
+)
+
+// Proof generates the inclusion proof of the leaf containing the data passed in parameter for the hash algorithm
+// passed in parameter, the tree must have been built with this algorithm
+func (mt *MerkleTree) Proof(data Data, hash Hash) (*Proof, error) {
+	return mt.proof(mt.Root, mt.Leaves, data, hash)
+}
+
+// proof generates the inclusion proof of the leaf containing the data passed in parameter within the leaves of the
+// tree whose root is passed in parameter
+func (c *MerkleTreeConfig) proof(root *Node, leaves []*Node, data Data, hash Hash) (*Proof, error) {
+	if len(leaves) == 0 {
+		return nil, ErrMerkleTreeDataIsNilOrEmpty
+	}
+
+	i, err := c.hasherIndex(hash)
+	if err != nil {
+		return nil, err
+	}
+	h := c.hashers()[i]
+
+	leafHash, err := data.Hash(h)
+	if err != nil {
+		return nil, fmt.Errorf("data.Hasher(%s): %w", hash, err)
+	}
+
+	for index, leaf := range leaves {
+		if !bytes.Equal(leaf.hashAt(i), leafHash) {
+			continue
+		}
+
+		path, err := c.path(root, len(leaves), index)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			arity = c.arity()
+			p     = &Proof{
+				Hash:            hash,
+				IsSortPairs:     h.IsSortPairs,
+				DigestLength:    h.DigestLength,
+				OddNodeStrategy: c.oddNodeStrategy(),
+				Arity:           uint32(arity),
+				Index:           index,
+				Leaf:            leafHash,
+			}
+		)
+		for level, position := 0, index; level < len(path)-1; level, position = level+1, position/arity {
+			current, parent := path[level], path[level+1]
+
+			// a promoted node is grouped with no sibling
+			if current == parent {
+				continue
+			}
+
+			siblings := make([][]byte, 0, len(parent.Children)-1)
+			for j, child := range parent.Children {
+				if j != position%arity {
+					siblings = append(siblings, child.hashAt(i))
+				}
+			}
+			p.Steps = append(p.Steps, ProofStep{Siblings: siblings, Position: position % arity})
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("data<%s>: %w", data, ErrMerkleTreeLeafNotFound)
+}
+
+// Verify recomputes the root from the leaf and its siblings and checks it against the root passed in parameter
+func (p *Proof) Verify(root []byte) (bool, error) {
+	if p == nil {
+		return false, ErrProofIsNil
+	}
+	if !p.Hash.IsValid() {
+		return false, fmt.Errorf(ErrHashNotAllowed.Error(), p.Hash)
+	}
+	if p.OddNodeStrategy != "" {
+		if err := p.OddNodeStrategy.Validate(); err != nil {
+			return false, err
+		}
+	}
+	if err := p.Hash.ValidateDigestLength(p.DigestLength); err != nil {
+		return false, fmt.Errorf("p.Hash.ValidateDigestLength(): %w", err)
+	}
+
+	arity := int(p.Arity)
+	if arity == 0 {
+		arity = DefaultArity
+	}
+	if arity < DefaultArity || arity > MaxArity {
+		return false, fmt.Errorf("arity<%d>: %w", arity, ErrMerkleTreeConfigArityIsWrong)
+	}
+
+	var (
+		h    = &Hasher{Hash: p.Hash, IsSortPairs: p.IsSortPairs, DigestLength: p.DigestLength}
+		hash = p.Leaf
+		err  error
+	)
+	for _, step := range p.Steps {
+		if step.Position < 0 || step.Position > len(step.Siblings) || len(step.Siblings) >= arity {
+			return false, ErrProofStepIsWrong
+		}
+
+		// insert the current hash among its siblings at its position
+		children := make([][]byte, 0, len(step.Siblings)+1)
+		children = append(children, step.Siblings[:step.Position]...)
+		children = append(children, hash)
+		children = append(children, step.Siblings[step.Position:]...)
+
+		if hash, err = h.hashChildren(children...); err != nil {
+			return false, fmt.Errorf("h.hashChildren(): %w", err)
+		}
+	}
+	return bytes.Equal(hash, root), nil
+}
@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMerkleTree_Proof(t *testing.T) {
+	mt, err := NewMerkleTreeBuilder().
+		WithHashers(
+			&Hasher{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+			&Hasher{Hash: SHA384, IsSortPairs: true},
+		).
+		WithMaxGoroutine(1000).
+		Build(ctx, dataUnEvenNbNodes)
+	assert.NoError(t, err)
+
+	type args struct {
+		data Data
+		hash Hash
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+		err  error
+	}{
+		{
+			name: "proof of a leaf with the main algorithm should verify",
+			args: args{data: dataUnEvenNbNodes[0], hash: SHA256},
+			want: true,
+		},
+		{
+			name: "proof of a leaf with the secondary algorithm should verify",
+			args: args{data: dataUnEvenNbNodes[2], hash: SHA384},
+			want: true,
+		},
+		{
+			name: "proof of the orphan leaf should verify",
+			args: args{data: dataUnEvenNbNodes[4], hash: SHA384},
+			want: true,
+		},
+		{
+			name: "proof with an algorithm the tree has not been built with should return error",
+			args: args{data: dataUnEvenNbNodes[0], hash: SHA512},
+			err:  ErrMerkleTreeHashNotBuilt,
+		},
+		{
+			name: "proof of a leaf that is not present in the tree should return error",
+			args: args{data: StringData{Value: "not=present"}, hash: SHA256},
+			err:  ErrMerkleTreeLeafNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := mt.Proof(tt.args.data, tt.args.hash)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Proof() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			root, err := mt.RootHash(tt.args.hash)
+			assert.NoError(t, err)
+			got, err := p.Verify(root)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// RLPItem is an item of the Recursive Length Prefix serialization used by Ethereum
+// it is either a byte string or, if IsList is true, a list of items
+type RLPItem struct {
+	Bytes  []byte
+	List   []RLPItem
+	IsList bool
+}
+
+var (
+	ErrRLPIsWrong      = errors.New("the rlp encoding is not valid")
+	ErrRLPIsNotCanonic = errors.New("the rlp encoding is not canonical")
+	ErrRLPTypeIsWrong  = errors.New("the rlp item is not of the expected type")
+)
+
+// RLPBytes returns the item of a byte string
+func RLPBytes(b []byte) RLPItem {
+	return RLPItem{Bytes: b}
+}
+
+// RLPList returns the item of a list of items
+func RLPList(items ...RLPItem) RLPItem {
+	return RLPItem{List: items, IsList: true}
+}
+
+// RLPUint returns the item of an unsigned integer, i.e. its big endian representation without leading zeros
+func RLPUint(u uint64) RLPItem {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, u)
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	return RLPBytes(b)
+}
+
+// RLPBigInt returns the item of a positive big integer, i.e. its big endian representation without leading zeros
+func RLPBigInt(i *big.Int) RLPItem {
+	return RLPBytes(i.Bytes())
+}
+
+// Uint returns the unsigned integer of a byte string item
+func (i RLPItem) Uint() (uint64, error) {
+	if i.IsList || len(i.Bytes) > 8 {
+		return 0, ErrRLPTypeIsWrong
+	}
+	if len(i.Bytes) > 0 && i.Bytes[0] == 0 {
+		return 0, ErrRLPIsNotCanonic
+	}
+
+	var u uint64
+	for _, b := range i.Bytes {
+		u = u<<8 | uint64(b)
+	}
+	return u, nil
+}
+
+// BigInt returns the big integer of a byte string item
+func (i RLPItem) BigInt() (*big.Int, error) {
+	if i.IsList {
+		return nil, ErrRLPTypeIsWrong
+	}
+	if len(i.Bytes) > 0 && i.Bytes[0] == 0 {
+		return nil, ErrRLPIsNotCanonic
+	}
+	return new(big.Int).SetBytes(i.Bytes), nil
+}
+
+// EncodeRLP serializes the item passed in parameter
+func EncodeRLP(item RLPItem) []byte {
+	if !item.IsList {
+		return rlpEncodeBytes(item.Bytes)
+	}
+
+	encoded := make([][]byte, len(item.List))
+	for i, it := range item.List {
+		encoded[i] = EncodeRLP(it)
+	}
+	return rlpEncodeList(encoded...)
+}
+
+// DecodeRLP deserializes a single item, an error is returned if the encoding is not canonical or if bytes remain
+func DecodeRLP(b []byte) (RLPItem, error) {
+	item, rest, err := rlpDecode(b)
+	if err != nil {
+		return RLPItem{}, err
+	}
+	if len(rest) != 0 {
+		return RLPItem{}, fmt.Errorf("%d trailing bytes: %w", len(rest), ErrRLPIsWrong)
+	}
+	return item, nil
+}
+
+// rlpEncodeBytes serializes a byte string, a single byte below 0x80 being its own encoding
+func rlpEncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+// rlpEncodeList serializes a list made of the items already serialized passed in parameter
+func rlpEncodeList(encoded ...[]byte) []byte {
+	size := 0
+	for _, e := range encoded {
+		size += len(e)
+	}
+
+	b := rlpHeader(0xc0, size)
+	for _, e := range encoded {
+		b = append(b, e...)
+	}
+	return b
+}
+
+// rlpHeader returns the prefix of a string (offset 0x80) or of a list (offset 0xc0) of the size passed in parameter
+// the size is part of the prefix below 56 bytes, otherwise the prefix is followed by the size in big endian
+func rlpHeader(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+
+	length := RLPUint(uint64(size)).Bytes
+	return append([]byte{offset + 55 + byte(len(length))}, length...)
+}
+
+// rlpDecode deserializes the first item of the bytes passed in parameter and returns the remaining bytes
+func rlpDecode(b []byte) (RLPItem, []byte, error) {
+	if len(b) == 0 {
+		return RLPItem{}, nil, fmt.Errorf("empty input: %w", ErrRLPIsWrong)
+	}
+
+	prefix := b[0]
+	switch {
+	case prefix < 0x80:
+		return RLPBytes(b[:1]), b[1:], nil
+	case prefix < 0xc0:
+		content, rest, err := rlpContent(b, 0x80)
+		if err != nil {
+			return RLPItem{}, nil, err
+		}
+		if len(content) == 1 && content[0] < 0x80 {
+			return RLPItem{}, nil, fmt.Errorf("single byte<%x> encoded as a string: %w", content[0], ErrRLPIsNotCanonic)
+		}
+		return RLPBytes(content), rest, nil
+	default:
+		content, rest, err := rlpContent(b, 0xc0)
+		if err != nil {
+			return RLPItem{}, nil, err
+		}
+
+		list := RLPList()
+		for len(content) > 0 {
+			var item RLPItem
+			if item, content, err = rlpDecode(content); err != nil {
+				return RLPItem{}, nil, err
+			}
+			list.List = append(list.List, item)
+		}
+		return list, rest, nil
+	}
+}
+
+// rlpContent returns the content of the string or the list prefixed by the header at the beginning of the bytes
+// passed in parameter and the bytes following it
+func rlpContent(b []byte, offset byte) ([]byte, []byte, error) {
+	var (
+		prefix = int(b[0] - offset)
+		start  = 1
+		size   = prefix
+	)
+
+	// the size does not fit in the prefix, it follows it
+	if prefix > 55 {
+		lengthSize := prefix - 55
+		if len(b) < 1+lengthSize {
+			return nil, nil, fmt.Errorf("size of %d bytes out of bounds: %w", lengthSize, ErrRLPIsWrong)
+		}
+		if b[1] == 0 {
+			return nil, nil, fmt.Errorf("size with leading zeros: %w", ErrRLPIsNotCanonic)
+		}
+		if lengthSize > 8 {
+			return nil, nil, fmt.Errorf("size of %d bytes too large: %w", lengthSize, ErrRLPIsWrong)
+		}
+
+		s := uint64(0)
+		for _, c := range b[1 : 1+lengthSize] {
+			s = s<<8 | uint64(c)
+		}
+		if s < 56 {
+			return nil, nil, fmt.Errorf("size<%d> that could fit in the prefix: %w", s, ErrRLPIsNotCanonic)
+		}
+		if s > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("size<%d> out of bounds: %w", s, ErrRLPIsWrong)
+		}
+		start, size = 1+lengthSize, int(s)
+	}
+
+	if len(b) < start+size {
+		return nil, nil, fmt.Errorf("size<%d> out of bounds: %w", size, ErrRLPIsWrong)
+	}
+	return b[start : start+size], b[start+size:], nil
+}
@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestEncodeRLP(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     RLPItem
+		expected string
+	}{
+		{
+			name:     "encode a string should return its prefixed bytes",
+			item:     RLPBytes([]byte("dog")),
+			expected: "83646f67",
+		},
+		{
+			name:     "encode a list of strings should return the prefixed encoding of the strings",
+			item:     RLPList(RLPBytes([]byte("cat")), RLPBytes([]byte("dog"))),
+			expected: "c88363617483646f67",
+		},
+		{
+			name:     "encode an empty string should return 0x80",
+			item:     RLPBytes(nil),
+			expected: "80",
+		},
+		{
+			name:     "encode an empty list should return 0xc0",
+			item:     RLPList(),
+			expected: "c0",
+		},
+		{
+			name:     "encode the integer 0 should return an empty string",
+			item:     RLPUint(0),
+			expected: "80",
+		},
+		{
+			name:     "encode a single byte lower than 0x80 should return the byte itself",
+			item:     RLPUint(15),
+			expected: "0f",
+		},
+		{
+			name:     "encode the integer 1024 should return its big endian bytes",
+			item:     RLPUint(1024),
+			expected: "820400",
+		},
+		{
+			name:     "encode a big integer should return its big endian bytes",
+			item:     RLPBigInt(big.NewInt(1024)),
+			expected: "820400",
+		},
+		{
+			name:     "encode nested lists should return the set theoretical representation of three",
+			item:     RLPList(RLPList(), RLPList(RLPList()), RLPList(RLPList(), RLPList(RLPList()))),
+			expected: "c7c0c1c0c3c0c1c0",
+		},
+		{
+			name:     "encode a string of 56 bytes should return a long string prefix",
+			item:     RLPBytes(bytes.Repeat([]byte{'a'}, 56)),
+			expected: "b838" + hex.EncodeToString(bytes.Repeat([]byte{'a'}, 56)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := EncodeRLP(tt.item)
+			assert.Equal(t, tt.expected, hex.EncodeToString(encoded))
+
+			decoded, err := DecodeRLP(encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, encoded, EncodeRLP(decoded))
+		})
+	}
+}
+
+func TestDecodeRLP(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		err     error
+	}{
+		{
+			name:    "decode an empty input should return error",
+			encoded: "",
+			err:     ErrRLPIsWrong,
+		},
+		{
+			name:    "decode a string shorter than its prefix should return error",
+			encoded: "83646f",
+			err:     ErrRLPIsWrong,
+		},
+		{
+			name:    "decode an item followed by trailing bytes should return error",
+			encoded: "83646f6700",
+			err:     ErrRLPIsWrong,
+		},
+		{
+			name:    "decode a single byte lower than 0x80 encoded as a string should return error",
+			encoded: "810f",
+			err:     ErrRLPIsNotCanonic,
+		},
+		{
+			name:    "decode a short string encoded with a long prefix should return error",
+			encoded: "b803646f67",
+			err:     ErrRLPIsNotCanonic,
+		},
+		{
+			name:    "decode a long string length with leading zeros should return error",
+			encoded: "b90038" + hex.EncodeToString(bytes.Repeat([]byte{'a'}, 56)),
+			err:     ErrRLPIsNotCanonic,
+		},
+		{
+			name:    "decode a list whose item overflows the list should return error",
+			encoded: "c283646f67",
+			err:     ErrRLPIsWrong,
+		},
+		{
+			name:    "decode a valid list should not return error",
+			encoded: "c88363617483646f67",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := hex.DecodeString(tt.encoded)
+			assert.NoError(t, err)
+			if _, err = DecodeRLP(b); !errors.Is(err, tt.err) {
+				t.Errorf("DecodeRLP() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRLPItem_Uint(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     RLPItem
+		expected uint64
+		err      error
+	}{
+		{
+			name:     "decode an empty string should return 0",
+			item:     RLPBytes(nil),
+			expected: 0,
+		},
+		{
+			name:     "decode an integer should return it",
+			item:     RLPUint(1024),
+			expected: 1024,
+		},
+		{
+			name: "decode an integer with leading zeros should return error",
+			item: RLPBytes([]byte{0, 1}),
+			err:  ErrRLPIsNotCanonic,
+		},
+		{
+			name: "decode a list should return error",
+			item: RLPList(),
+			err:  ErrRLPTypeIsWrong,
+		},
+		{
+			name: "decode an integer longer than 8 bytes should return error",
+			item: RLPBytes(bytes.Repeat([]byte{1}, 9)),
+			err:  ErrRLPTypeIsWrong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := tt.item.Uint()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Uint() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			assert.Equal(t, tt.expected, u)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// MerkleTreeSnapshot is a read-only version of a merkle tree
+// nodes are never modified once created, a snapshot keeps sharing the unchanged subtrees with the later versions of
+// the tree and its leaves are found by walking the tree down from its root
+type MerkleTreeSnapshot struct {
+	Root     *Node
+	NbLeaves int
+	MerkleTreeConfig
+}
+
+// Snapshot returns a read-only version of the tree in O(1), it is not affected by the later updates of the tree
+func (mt *MerkleTree) Snapshot() *MerkleTreeSnapshot {
+	return &MerkleTreeSnapshot{
+		Root:             mt.Root,
+		NbLeaves:         len(mt.Leaves),
+		MerkleTreeConfig: mt.MerkleTreeConfig,
+	}
+}
+
+// RootHash returns the merkle root of the snapshot computed with the hash algorithm passed in parameter
+func (s *MerkleTreeSnapshot) RootHash(hash Hash) ([]byte, error) {
+	return s.rootHash(s.Root, hash)
+}
+
+// Leaf returns the leaf at the index passed in parameter in O(log n)
+func (s *MerkleTreeSnapshot) Leaf(index int) (*Node, error) {
+	path, err := s.path(s.Root, s.NbLeaves, index)
+	if err != nil {
+		return nil, err
+	}
+	return path[0], nil
+}
+
+// Leaves returns all the leaves of the snapshot, orphan and padding leaves included
+func (s *MerkleTreeSnapshot) Leaves() ([]*Node, error) {
+	if s.Root == nil || s.NbLeaves == 0 {
+		return nil, ErrMerkleTreeDataIsNilOrEmpty
+	}
+
+	var (
+		arity = s.arity()
+		sizes = s.levelSizes(s.NbLeaves)
+		nodes = []*Node{s.Root}
+	)
+	for level := len(sizes) - 1; level > 0; level-- {
+		children := make([]*Node, 0, sizes[level-1])
+		for i, n := range nodes {
+			start, end := i*arity, i*arity+arity
+			if end > sizes[level-1] {
+				end = sizes[level-1]
+			}
+
+			// a lone node has been promoted unless it has been duplicated
+			if end-start == 1 && s.oddNodeStrategy() != DUPLICATE {
+				children = append(children, n)
+				continue
+			}
+
+			if end-start > len(n.Children) {
+				return nil, fmt.Errorf("level<%d> position<%d>: %w", level, i, ErrMerkleTreeStructureIsWrong)
+			}
+			children = append(children, n.Children[:end-start]...)
+		}
+		nodes = children
+	}
+	return nodes, nil
+}
+
+// Proof generates the inclusion proof of the leaf containing the data passed in parameter against the snapshot root
+func (s *MerkleTreeSnapshot) Proof(data Data, hash Hash) (*Proof, error) {
+	leaves, err := s.Leaves()
+	if err != nil {
+		return nil, err
+	}
+	return s.proof(s.Root, leaves, data, hash)
+}
+
+// Verify verifies if a leaf containing the data passed in parameter is present in the snapshot
+func (s *MerkleTreeSnapshot) Verify(ctx context.Context, data Data) (bool, error) {
+	leaves, err := s.Leaves()
+	if err != nil {
+		return false, fmt.Errorf("s.Leaves(): %w", err)
+	}
+
+	hash, err := data.Hash(s.Hasher)
+	if err != nil {
+		return false, fmt.Errorf("data.Hasher(): %w", err)
+	}
+
+	for index, leaf := range leaves {
+		if bytes.Equal(leaf.Hash, hash) {
+			return s.verifyPath(s.Root, s.NbLeaves, index)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,151 @@
+package pkg
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestMerkleTree_Snapshot(t *testing.T) {
+	data := make([]Data, 23)
+	for i := range data {
+		data[i] = StringData{Value: fmt.Sprintf("value%d", i)}
+	}
+
+	tests := []struct {
+		name     string
+		arity    uint32
+		strategy OddNodeStrategy
+		mode     DeletionMode
+		update   func(mt *MerkleTree) error
+	}{
+		{
+			name:     "update a leaf should leave the snapshot unchanged",
+			strategy: DUPLICATE,
+			update: func(mt *MerkleTree) error {
+				return mt.Update(22, StringData{Value: "updated"})
+			},
+		},
+		{
+			name:     "apply a batch to a 4-ary tree should leave the snapshot unchanged",
+			arity:    4,
+			strategy: PROMOTE,
+			update: func(mt *MerkleTree) error {
+				return mt.ApplyBatch(ctx, map[int]Data{20: StringData{Value: "u20"}, 22: StringData{Value: "u22"}})
+			},
+		},
+		{
+			name:     "append leaves to a padded tree should leave the snapshot unchanged",
+			strategy: PAD,
+			update: func(mt *MerkleTree) error {
+				return mt.Append(ctx, StringData{Value: "appended1"}, StringData{Value: "appended2"})
+			},
+		},
+		{
+			name:     "tombstone a leaf should leave the snapshot unchanged",
+			strategy: DUPLICATE,
+			mode:     TOMBSTONE,
+			update: func(mt *MerkleTree) error {
+				return mt.Delete(ctx, 21)
+			},
+		},
+		{
+			name:     "remove a leaf of a 3-ary tree should leave the snapshot unchanged",
+			arity:    3,
+			strategy: PROMOTE,
+			mode:     REMOVE,
+			update: func(mt *MerkleTree) error {
+				return mt.Delete(ctx, 19)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := buildTree(t, &Hasher{Hash: SHA256}, tt.arity, tt.strategy, data, func(b *MerkleTreeBuilder) { b.WithDeletionMode(tt.mode) })
+			root := mt.Root.Hash
+			leaves := make([]*Node, len(mt.Leaves))
+			copy(leaves, mt.Leaves)
+
+			snapshot := mt.Snapshot()
+
+			// the leaves found walking the snapshot down from its root should be the ones of the tree
+			got, err := snapshot.Leaves()
+			assert.NoError(t, err)
+			assert.Equal(t, leaves, got)
+
+			assert.NoError(t, tt.update(mt))
+			assert.NotEqual(t, root, mt.Root.Hash)
+
+			// the snapshot should be left unchanged by the update
+			snapshotRoot, err := snapshot.RootHash(SHA256)
+			assert.NoError(t, err)
+			assert.Equal(t, root, snapshotRoot)
+			got, err = snapshot.Leaves()
+			assert.NoError(t, err)
+			assert.Equal(t, leaves, got)
+
+			// the unchanged subtrees should be shared by the snapshot and the tree
+			leaf, err := snapshot.Leaf(0)
+			assert.NoError(t, err)
+			assert.Same(t, mt.Leaves[0], leaf)
+
+			// the proofs of the snapshot should be verified against its root
+			for _, d := range data {
+				isVerified, err := snapshot.Verify(ctx, d)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+
+				p, err := snapshot.Proof(d, SHA256)
+				assert.NoError(t, err)
+				isVerified, err = p.Verify(root)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+
+			// the tree should still be consistent
+			for _, d := range leavesData(mt) {
+				if d == nil {
+					continue
+				}
+				isVerified, err := mt.Verify(ctx, d)
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+
+			// a snapshot whose nodes do not match its nb of leaves should return error instead of not finding the data
+			broken := *snapshot
+			broken.NbLeaves = len(leaves) * broken.arity()
+			_, err = broken.Verify(ctx, data[0])
+			assert.ErrorIs(t, err, ErrMerkleTreeStructureIsWrong)
+		})
+	}
+}
+
+func TestMerkleTree_SnapshotConcurrentReads(t *testing.T) {
+	data := make([]Data, 100)
+	for i := range data {
+		data[i] = StringData{Value: fmt.Sprintf("value%d", i)}
+	}
+	mt := buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, data)
+	root := mt.Root.Hash
+	snapshot := mt.Snapshot()
+
+	// reading a snapshot while the tree is updated should not race
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < len(data); i++ {
+			assert.NoError(t, mt.ApplyBatch(ctx, map[int]Data{i: StringData{Value: fmt.Sprintf("updated%d", i)}}))
+		}
+	}()
+	for _, d := range data {
+		p, err := snapshot.Proof(d, SHA256)
+		assert.NoError(t, err)
+		isVerified, err := p.Verify(root)
+		assert.NoError(t, err)
+		assert.True(t, isVerified)
+	}
+	wg.Wait()
+}
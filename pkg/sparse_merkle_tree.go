@@ -0,0 +1,403 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// SparseMerkleTree is a key-value commitment made of a tree of SparseMerkleTreeDepth levels
+// the position of a value is given by the bits of the hash of its key, the most significant bit first, so that the
+// root does not depend on the order the keys have been set in
+// the subtrees without any key are never stored, their hash being the precomputed hash of the empty subtree of
+// their height, the hash of an empty leaf being a digest full of zeros
+type SparseMerkleTree struct {
+	SparseMerkleTreeConfig
+	root       []byte
+	values     map[string][]byte
+	nodes      map[string][]byte
+	zeroHashes [][]byte
+}
+
+// SparseMerkleTreeConfig is the configuration that represents the options used to build the tree
+type SparseMerkleTreeConfig struct {
+	Hasher *Hasher
+}
+
+// SparseMerkleTreeBuilder allows use to pass the configuration before building a sparse tree
+type SparseMerkleTreeBuilder struct {
+	config *SparseMerkleTreeConfig
+}
+
+// SparseMerkleProof is a membership proof of a key if Value is not nil, a non-membership proof otherwise
+// Siblings contains one hash per level, ordered from the leaf up to the root
+type SparseMerkleProof struct {
+	Hash         Hash
+	DigestLength uint32
+	Key          []byte
+	Value        []byte
+	Siblings     [][]byte
+}
+
+// CompressedSparseMerkleProof is a sparse merkle proof where the hashes of the empty subtrees are left out
+// the i-th bit of Bitmap, the least significant bit first, is set if the sibling of the i-th level is not empty,
+// Siblings containing only those, ordered from the leaf up to the root
+type CompressedSparseMerkleProof struct {
+	Hash         Hash
+	DigestLength uint32
+	Key          []byte
+	Value        []byte
+	Bitmap       []byte
+	Siblings     [][]byte
+}
+
+const (
+	// SparseMerkleTreeDepth is the nb of levels of a sparse tree, i.e. the nb of bits of the key hashes used
+	SparseMerkleTreeDepth = 256
+)
+
+var (
+	ErrSparseMerkleTreeConfigSortNotAllowed = errors.New("the sparse merkle tree cannot sort leaves or pairs as the position of a node is given by its key")
+	ErrSparseMerkleTreeKeyNotFound          = errors.New("the sparse merkle tree does not contain the key")
+	ErrSparseMerkleProofIsWrong             = errors.New("the sparse merkle proof nb of siblings is not consistent with the tree depth or its bitmap")
+	ErrSparseMerkleTreeHashIsTooShort       = errors.New("the sparse merkle tree hash digest cannot have fewer bits than the tree depth")
+)
+
+func NewSparseMerkleTreeBuilder() *SparseMerkleTreeBuilder {
+	return &SparseMerkleTreeBuilder{config: &SparseMerkleTreeConfig{}}
+}
+
+func (b *SparseMerkleTreeBuilder) WithHasher(hasher *Hasher) *SparseMerkleTreeBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+// Build creates an empty tree, its root being the hash of the empty subtree of the tree depth
+func (b *SparseMerkleTreeBuilder) Build() (*SparseMerkleTree, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if b.config.Hasher.IsSortLeaves || b.config.Hasher.IsSortPairs {
+		return nil, ErrSparseMerkleTreeConfigSortNotAllowed
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	// the key hashes give the path of the values, one bit per level
+	if b.config.Hasher.Hash.Size()*8 < SparseMerkleTreeDepth {
+		return nil, fmt.Errorf("hash<%s>: %w", b.config.Hasher.Hash, ErrSparseMerkleTreeHashIsTooShort)
+	}
+
+	if err := b.config.Hasher.Hash.ValidateDigestLength(b.config.Hasher.DigestLength); err != nil {
+		return nil, fmt.Errorf("Hash.ValidateDigestLength(): %w", err)
+	}
+
+	zeroHashes, err := getZeroHashes(b.config.Hasher, SparseMerkleTreeDepth)
+	if err != nil {
+		return nil, fmt.Errorf("getZeroHashes(): %w", err)
+	}
+
+	return &SparseMerkleTree{
+		SparseMerkleTreeConfig: *b.config,
+		root:                   zeroHashes[SparseMerkleTreeDepth],
+		values:                 map[string][]byte{},
+		nodes:                  map[string][]byte{},
+		zeroHashes:             zeroHashes,
+	}, nil
+}
+
+// Root returns the root of the tree
+func (t *SparseMerkleTree) Root() []byte {
+	return t.root
+}
+
+// Size returns the nb of keys of the tree
+func (t *SparseMerkleTree) Size() int {
+	return len(t.values)
+}
+
+// Get returns the value of the key passed in parameter
+func (t *SparseMerkleTree) Get(key []byte) ([]byte, error) {
+	path, err := t.Hasher.hashData(key)
+	if err != nil {
+		return nil, fmt.Errorf("t.Hasher.hashData(key): %w", err)
+	}
+
+	value, ok := t.values[string(path)]
+	if !ok {
+		return nil, fmt.Errorf("key<%x>: %w", key, ErrSparseMerkleTreeKeyNotFound)
+	}
+	return value, nil
+}
+
+// Set sets the value of the key passed in parameter, only the nodes of the path of the key are rehashed
+func (t *SparseMerkleTree) Set(key, value []byte) error {
+	if len(value) == 0 {
+		return ErrMerkleTreeDataIsNilOrEmpty
+	}
+
+	path, err := t.Hasher.hashData(key)
+	if err != nil {
+		return fmt.Errorf("t.Hasher.hashData(key): %w", err)
+	}
+	leaf, err := t.Hasher.hashData(value)
+	if err != nil {
+		return fmt.Errorf("t.Hasher.hashData(value): %w", err)
+	}
+
+	if err = t.updatePath(path, leaf); err != nil {
+		return err
+	}
+	t.values[string(path)] = append([]byte{}, value...)
+	return nil
+}
+
+// Delete removes the key passed in parameter, its leaf becoming empty again
+func (t *SparseMerkleTree) Delete(key []byte) error {
+	path, err := t.Hasher.hashData(key)
+	if err != nil {
+		return fmt.Errorf("t.Hasher.hashData(key): %w", err)
+	}
+
+	if _, ok := t.values[string(path)]; !ok {
+		return fmt.Errorf("key<%x>: %w", key, ErrSparseMerkleTreeKeyNotFound)
+	}
+
+	if err = t.updatePath(path, t.zeroHashes[0]); err != nil {
+		return err
+	}
+	delete(t.values, string(path))
+	return nil
+}
+
+// Proof generates the membership proof of the key passed in parameter if the tree contains it, its non-membership
+// proof otherwise
+func (t *SparseMerkleTree) Proof(key []byte) (*SparseMerkleProof, error) {
+	path, err := t.Hasher.hashData(key)
+	if err != nil {
+		return nil, fmt.Errorf("t.Hasher.hashData(key): %w", err)
+	}
+
+	p := &SparseMerkleProof{
+		Hash:         t.Hasher.Hash,
+		DigestLength: t.Hasher.DigestLength,
+		Key:          key,
+		Siblings:     make([][]byte, SparseMerkleTreeDepth),
+	}
+	if value, ok := t.values[string(path)]; ok {
+		p.Value = value
+	}
+	for h := 0; h < SparseMerkleTreeDepth; h++ {
+		p.Siblings[h] = t.node(h, siblingPath(path, h))
+	}
+	return p, nil
+}
+
+// updatePath replaces the leaf of the path passed in parameter and rehashes its ancestors up to the root
+// the nodes that become empty are removed so that only the non-empty subtrees are stored
+func (t *SparseMerkleTree) updatePath(path, leaf []byte) error {
+	var (
+		err  error
+		node = leaf
+	)
+
+	t.setNode(0, path, node)
+	for h := 0; h < SparseMerkleTreeDepth; h++ {
+		sibling := t.node(h, siblingPath(path, h))
+		if pathBit(path, h) == 0 {
+			node, err = t.Hasher.hashChildren(node, sibling)
+		} else {
+			node, err = t.Hasher.hashChildren(sibling, node)
+		}
+		if err != nil {
+			return fmt.Errorf("t.Hasher.hashChildren(): %w", err)
+		}
+		t.setNode(h+1, path, node)
+	}
+	t.root = node
+	return nil
+}
+
+// node returns the hash of the node of the height and the path passed in parameter
+func (t *SparseMerkleTree) node(height int, path []byte) []byte {
+	if node, ok := t.nodes[nodeKey(height, path)]; ok {
+		return node
+	}
+	return t.zeroHashes[height]
+}
+
+// setNode stores the hash of the node of the height and the path passed in parameter unless its subtree is empty
+func (t *SparseMerkleTree) setNode(height int, path, node []byte) {
+	key := nodeKey(height, path)
+	if bytes.Equal(node, t.zeroHashes[height]) {
+		delete(t.nodes, key)
+		return
+	}
+	t.nodes[key] = node
+}
+
+// Verify recomputes the root from the leaf of the key and its siblings and checks it against the root passed in parameter
+func (p *SparseMerkleProof) Verify(root []byte) (bool, error) {
+	if p == nil {
+		return false, ErrProofIsNil
+	}
+	if !p.Hash.IsValid() {
+		return false, fmt.Errorf(ErrHashNotAllowed.Error(), p.Hash)
+	}
+	if p.Hash.Size()*8 < SparseMerkleTreeDepth {
+		return false, fmt.Errorf("hash<%s>: %w", p.Hash, ErrSparseMerkleTreeHashIsTooShort)
+	}
+	if err := p.Hash.ValidateDigestLength(p.DigestLength); err != nil {
+		return false, fmt.Errorf("p.Hash.ValidateDigestLength(): %w", err)
+	}
+	if len(p.Siblings) != SparseMerkleTreeDepth {
+		return false, ErrSparseMerkleProofIsWrong
+	}
+
+	h := &Hasher{Hash: p.Hash, DigestLength: p.DigestLength}
+	path, err := h.hashData(p.Key)
+	if err != nil {
+		return false, fmt.Errorf("h.hashData(key): %w", err)
+	}
+
+	// the leaf of a key the tree does not contain is empty
+	node := make([]byte, p.Hash.Size())
+	if p.IsMembership() {
+		if node, err = h.hashData(p.Value); err != nil {
+			return false, fmt.Errorf("h.hashData(value): %w", err)
+		}
+	}
+
+	for i, sibling := range p.Siblings {
+		if pathBit(path, i) == 0 {
+			node, err = h.hashChildren(node, sibling)
+		} else {
+			node, err = h.hashChildren(sibling, node)
+		}
+		if err != nil {
+			return false, fmt.Errorf("h.hashChildren(): %w", err)
+		}
+	}
+	return bytes.Equal(node, root), nil
+}
+
+// IsMembership returns true if the proof proves that the tree contains the key, false if it proves that it does not
+func (p *SparseMerkleProof) IsMembership() bool {
+	return p.Value != nil
+}
+
+// Compress leaves out the siblings that are the hash of an empty subtree, a bit of the bitmap being set for the others
+func (p *SparseMerkleProof) Compress() (*CompressedSparseMerkleProof, error) {
+	zeroHashes, err := getZeroHashes(&Hasher{Hash: p.Hash, DigestLength: p.DigestLength}, SparseMerkleTreeDepth)
+	if err != nil {
+		return nil, fmt.Errorf("getZeroHashes(): %w", err)
+	}
+
+	c := &CompressedSparseMerkleProof{
+		Hash:         p.Hash,
+		DigestLength: p.DigestLength,
+		Key:          p.Key,
+		Value:        p.Value,
+		Bitmap:       make([]byte, SparseMerkleTreeDepth/8),
+	}
+	for i, sibling := range p.Siblings {
+		if i < len(zeroHashes) && bytes.Equal(sibling, zeroHashes[i]) {
+			continue
+		}
+		c.Bitmap[i/8] |= 1 << (i % 8)
+		c.Siblings = append(c.Siblings, sibling)
+	}
+	return c, nil
+}
+
+// Decompress restores the siblings that are the hash of an empty subtree
+func (c *CompressedSparseMerkleProof) Decompress() (*SparseMerkleProof, error) {
+	if c == nil {
+		return nil, ErrProofIsNil
+	}
+	if len(c.Bitmap) != SparseMerkleTreeDepth/8 {
+		return nil, ErrSparseMerkleProofIsWrong
+	}
+	if !c.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), c.Hash)
+	}
+
+	zeroHashes, err := getZeroHashes(&Hasher{Hash: c.Hash, DigestLength: c.DigestLength}, SparseMerkleTreeDepth)
+	if err != nil {
+		return nil, fmt.Errorf("getZeroHashes(): %w", err)
+	}
+
+	p := &SparseMerkleProof{
+		Hash:         c.Hash,
+		DigestLength: c.DigestLength,
+		Key:          c.Key,
+		Value:        c.Value,
+		Siblings:     make([][]byte, SparseMerkleTreeDepth),
+	}
+	next := 0
+	for i := range p.Siblings {
+		if c.Bitmap[i/8]&(1<<(i%8)) == 0 {
+			p.Siblings[i] = zeroHashes[i]
+			continue
+		}
+		if next >= len(c.Siblings) {
+			return nil, ErrSparseMerkleProofIsWrong
+		}
+		p.Siblings[i] = c.Siblings[next]
+		next++
+	}
+	if next != len(c.Siblings) {
+		return nil, ErrSparseMerkleProofIsWrong
+	}
+	return p, nil
+}
+
+// Verify decompresses the proof and checks it against the root passed in parameter
+func (c *CompressedSparseMerkleProof) Verify(root []byte) (bool, error) {
+	p, err := c.Decompress()
+	if err != nil {
+		return false, err
+	}
+	return p.Verify(root)
+}
+
+// pathBit returns the bit of the path choosing between the left and the right child above the height passed in
+// parameter, 0 meaning the node is the left child
+func pathBit(path []byte, height int) byte {
+	depth := SparseMerkleTreeDepth - 1 - height
+	return path[depth/8] >> (7 - depth%8) & 1
+}
+
+// siblingPath returns the path of the sibling of the node of the height and the path passed in parameter
+func siblingPath(path []byte, height int) []byte {
+	depth := SparseMerkleTreeDepth - 1 - height
+	sibling := make([]byte, SparseMerkleTreeDepth/8)
+	copy(sibling, path)
+	sibling[depth/8] ^= 1 << (7 - depth%8)
+	return sibling
+}
+
+// nodeKey returns the key a node is stored with, made of its height and of the bits of the path above it
+func nodeKey(height int, path []byte) string {
+	key := make([]byte, 2+SparseMerkleTreeDepth/8)
+	key[0], key[1] = byte(height>>8), byte(height)
+	copy(key[2:], path)
+
+	// the bits below the node are cleared
+	depth := SparseMerkleTreeDepth - height
+	if depth%8 != 0 {
+		key[2+depth/8] &= 0xff << (8 - depth%8)
+		depth += 8 - depth%8
+	}
+	for i := 2 + depth/8; i < len(key); i++ {
+		key[i] = 0
+	}
+	return string(key)
+}
@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSparseMerkleTreeBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		err    error
+	}{
+		{
+			name:   "build a sparse tree without hasher should return error",
+			hasher: nil,
+			err:    ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:   "build a sparse tree sorting its pairs should return error",
+			hasher: &Hasher{Hash: SHA256, IsSortPairs: true},
+			err:    ErrSparseMerkleTreeConfigSortNotAllowed,
+		},
+		{
+			name:   "build a sparse tree with a too short digest length should return error",
+			hasher: &Hasher{Hash: SHA256, DigestLength: 8},
+			err:    ErrHashDigestLengthTooShort,
+		},
+		{
+			name:   "build a sparse tree with a digest shorter than the tree depth should return error",
+			hasher: &Hasher{Hash: SHA1},
+			err:    ErrSparseMerkleTreeHashIsTooShort,
+		},
+		{
+			name:   "build a sparse tree should return the empty root",
+			hasher: &Hasher{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewSparseMerkleTreeBuilder().WithHasher(tt.hasher).Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			zeroHashes, err := getZeroHashes(tt.hasher, SparseMerkleTreeDepth)
+			assert.NoError(t, err)
+			assert.Equal(t, zeroHashes[SparseMerkleTreeDepth], tree.Root())
+			assert.Equal(t, 0, tree.Size())
+		})
+	}
+
+	// an unknown hash algorithm is rejected instead of panicking once the hashes are computed
+	_, err := NewSparseMerkleTreeBuilder().WithHasher(&Hasher{Hash: "md5"}).Build()
+	assert.EqualError(t, err, fmt.Sprintf(ErrHashNotAllowed.Error(), "md5"))
+}
+
+func TestSparseMerkleTree(t *testing.T) {
+	for _, h := range []*Hasher{
+		{Hash: SHA256, Pool: NewHashPool(SHA256.Hash())},
+		{Hash: SHA512, DigestLength: 32},
+	} {
+		t.Run(string(h.Hash), func(t *testing.T) {
+			tree, err := NewSparseMerkleTreeBuilder().WithHasher(h).Build()
+			assert.NoError(t, err)
+			emptyRoot := tree.Root()
+
+			keys := make([][]byte, 20)
+			for i := range keys {
+				keys[i] = []byte(fmt.Sprintf("account%d", i))
+				assert.NoError(t, tree.Set(keys[i], []byte(fmt.Sprintf("balance%d", i))))
+			}
+			assert.Equal(t, len(keys), tree.Size())
+			assert.ErrorIs(t, tree.Set(keys[0], nil), ErrMerkleTreeDataIsNilOrEmpty)
+
+			// the root should not depend on the order the keys have been set in
+			reversed, err := NewSparseMerkleTreeBuilder().WithHasher(h).Build()
+			assert.NoError(t, err)
+			for i := len(keys) - 1; i >= 0; i-- {
+				assert.NoError(t, reversed.Set(keys[i], []byte(fmt.Sprintf("balance%d", i))))
+			}
+			assert.Equal(t, tree.Root(), reversed.Root())
+
+			value, err := tree.Get(keys[3])
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("balance3"), value)
+			_, err = tree.Get([]byte("unknown"))
+			assert.ErrorIs(t, err, ErrSparseMerkleTreeKeyNotFound)
+
+			// membership proofs
+			for i, key := range keys {
+				p, err := tree.Proof(key)
+				assert.NoError(t, err)
+				assert.True(t, p.IsMembership())
+				assert.Equal(t, []byte(fmt.Sprintf("balance%d", i)), p.Value)
+				isVerified, err := p.Verify(tree.Root())
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+
+				// most of the siblings are empty subtrees and are left out once compressed
+				c, err := p.Compress()
+				assert.NoError(t, err)
+				assert.Less(t, len(c.Siblings), 2*len(keys))
+				isVerified, err = c.Verify(tree.Root())
+				assert.NoError(t, err)
+				assert.True(t, isVerified)
+			}
+
+			// non-membership proof
+			p, err := tree.Proof([]byte("unknown"))
+			assert.NoError(t, err)
+			assert.False(t, p.IsMembership())
+			isVerified, err := p.Verify(tree.Root())
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+
+			// a non-membership proof for a key of the tree should not be verified
+			p, err = tree.Proof(keys[0])
+			assert.NoError(t, err)
+			p.Value = nil
+			isVerified, err = p.Verify(tree.Root())
+			assert.NoError(t, err)
+			assert.False(t, isVerified)
+
+			// a proof with a wrong value should not be verified
+			p.Value = []byte("wrong")
+			isVerified, err = p.Verify(tree.Root())
+			assert.NoError(t, err)
+			assert.False(t, isVerified)
+
+			// updating a value should change the root
+			root := tree.Root()
+			assert.NoError(t, tree.Set(keys[0], []byte("updated")))
+			assert.NotEqual(t, root, tree.Root())
+
+			// deleting all the keys should restore the empty root and remove all the stored nodes
+			assert.ErrorIs(t, tree.Delete([]byte("unknown")), ErrSparseMerkleTreeKeyNotFound)
+			for _, key := range keys {
+				assert.NoError(t, tree.Delete(key))
+			}
+			assert.Equal(t, emptyRoot, tree.Root())
+			assert.Equal(t, 0, tree.Size())
+			assert.Empty(t, tree.nodes)
+		})
+	}
+}
+
+func TestCompressedSparseMerkleProof_Decompress(t *testing.T) {
+	tree, err := NewSparseMerkleTreeBuilder().WithHasher(&Hasher{Hash: SHA256}).Build()
+	assert.NoError(t, err)
+	assert.NoError(t, tree.Set([]byte("key1"), []byte("value1")))
+	assert.NoError(t, tree.Set([]byte("key2"), []byte("value2")))
+
+	p, err := tree.Proof([]byte("key1"))
+	assert.NoError(t, err)
+	c, err := p.Compress()
+	assert.NoError(t, err)
+
+	decompressed, err := c.Decompress()
+	assert.NoError(t, err)
+	assert.Equal(t, p, decompressed)
+
+	// a bitmap inconsistent with the nb of siblings should return error
+	c.Siblings = append(c.Siblings, c.Siblings[0])
+	_, err = c.Decompress()
+	assert.ErrorIs(t, err, ErrSparseMerkleProofIsWrong)
+
+	c.Bitmap = c.Bitmap[1:]
+	_, err = c.Verify(tree.Root())
+	assert.ErrorIs(t, err, ErrSparseMerkleProofIsWrong)
+
+	// a proof whose digest is shorter than the tree depth should return error instead of reading out of its path
+	p.Hash = SHA1
+	_, err = p.Verify(tree.Root())
+	assert.ErrorIs(t, err, ErrSparseMerkleTreeHashIsTooShort)
+}
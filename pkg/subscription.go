@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"bytes"
+	"sync"
+)
+
+// RootChange describes a change of the root of a tree
+// OldRoot and NewRoot are the roots computed with the main hasher, Size is the nb of leaves of the tree once changed,
+// orphan and padding leaves included, and Indices are the sorted indices of the leaves that have been changed, added
+// or removed, it must not be modified as it is shared between the subscribers
+type RootChange struct {
+	OldRoot []byte
+	NewRoot []byte
+	Size    int
+	Indices []int
+}
+
+// subscribers contains the callbacks to call whenever the root of a tree changes
+type subscribers struct {
+	mu        sync.Mutex
+	nextID    uint64
+	callbacks map[uint64]func(RootChange)
+	order     []uint64
+}
+
+// Subscribe registers a callback called after each change of the root of the tree, in the order of registration
+// callbacks are called synchronously by the goroutine updating the tree so they must not update or read the tree
+// the function returned removes the callback
+func (mt *MerkleTree) Subscribe(callback func(RootChange)) (unsubscribe func()) {
+	s := &mt.subscribers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.callbacks == nil {
+		s.callbacks = map[uint64]func(RootChange){}
+	}
+	id := s.nextID
+	s.nextID++
+	s.callbacks[id] = callback
+	s.order = append(s.order, id)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			delete(s.callbacks, id)
+			for i, _id := range s.order {
+				if _id == id {
+					s.order = append(s.order[:i:i], s.order[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Watch returns a channel receiving the changes of the root of the tree, size being the size of its buffer
+// no change is dropped, the changes being queued by the goroutine updating the tree and sent by a goroutine of the
+// watch so that the update is never blocked by the receiver, which can then read the tree on each change
+// the function returned stops the watch and its goroutine, the channel is never closed so that no send can panic
+func (mt *MerkleTree) Watch(size int) (<-chan RootChange, func()) {
+	var (
+		changes = make(chan RootChange, size)
+		done    = make(chan struct{})
+		once    sync.Once
+		queue   = &rootChangeQueue{signal: make(chan struct{}, 1)}
+	)
+
+	unsubscribe := mt.Subscribe(queue.push)
+	go queue.forward(changes, done)
+
+	return changes, func() {
+		once.Do(func() {
+			unsubscribe()
+			close(done)
+		})
+	}
+}
+
+// rootChangeQueue is the unbounded queue of the changes of a watch waiting to be sent
+type rootChangeQueue struct {
+	mu      sync.Mutex
+	pending []RootChange
+	signal  chan struct{}
+}
+
+// push queues the change and wakes the goroutine forwarding the changes up without blocking
+func (q *rootChangeQueue) push(change RootChange) {
+	q.mu.Lock()
+	q.pending = append(q.pending, change)
+	q.mu.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// forward sends the queued changes to the channel in order until done is closed
+func (q *rootChangeQueue) forward(changes chan<- RootChange, done <-chan struct{}) {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			select {
+			case <-q.signal:
+				continue
+			case <-done:
+				return
+			}
+		}
+		change := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		select {
+		case changes <- change:
+		case <-done:
+			return
+		}
+	}
+}
+
+// setRoot replaces the root of the tree and notifies the subscribers if its hash has changed
+// indices are the indices of the leaves that have been changed, added or removed
+func (mt *MerkleTree) setRoot(root *Node, indices []int) {
+	old := mt.Root
+	mt.Root = root
+
+	s := &mt.subscribers
+	s.mu.Lock()
+	callbacks := make([]func(RootChange), 0, len(s.order))
+	for _, id := range s.order {
+		callbacks = append(callbacks, s.callbacks[id])
+	}
+	s.mu.Unlock()
+
+	if len(callbacks) == 0 || (old != nil && bytes.Equal(old.Hash, root.Hash)) {
+		return
+	}
+
+	change := RootChange{
+		NewRoot: root.Hash,
+		Size:    len(mt.Leaves),
+		Indices: indices,
+	}
+	if old != nil {
+		change.OldRoot = old.Hash
+	}
+	for _, callback := range callbacks {
+		callback(change)
+	}
+}
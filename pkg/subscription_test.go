@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMerkleTree_Subscribe(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy OddNodeStrategy
+		mode     DeletionMode
+		update   func(mt *MerkleTree) error
+		size     int
+		indices  []int
+	}{
+		{
+			name:     "update a leaf should notify its index",
+			strategy: DUPLICATE,
+			update: func(mt *MerkleTree) error {
+				return mt.Update(1, StringData{Value: "updated"})
+			},
+			size:    6,
+			indices: []int{1},
+		},
+		{
+			name:     "update a duplicated leaf should notify the indices of its orphan copies",
+			strategy: DUPLICATE,
+			update: func(mt *MerkleTree) error {
+				return mt.Update(4, StringData{Value: "updated"})
+			},
+			size:    6,
+			indices: []int{4, 5},
+		},
+		{
+			name:     "apply a batch should notify the sorted indices",
+			strategy: PROMOTE,
+			update: func(mt *MerkleTree) error {
+				return mt.ApplyBatch(ctx, map[int]Data{3: StringData{Value: "u3"}, 0: StringData{Value: "u0"}})
+			},
+			size:    5,
+			indices: []int{0, 3},
+		},
+		{
+			name:     "append leaves should notify the indices of the leaves replacing the padding leaves",
+			strategy: PAD,
+			update: func(mt *MerkleTree) error {
+				return mt.Append(ctx, StringData{Value: "appended"})
+			},
+			size:    8,
+			indices: []int{5, 6, 7},
+		},
+		{
+			name:     "tombstone a leaf should notify its index",
+			strategy: PROMOTE,
+			mode:     TOMBSTONE,
+			update: func(mt *MerkleTree) error {
+				return mt.Delete(ctx, 2)
+			},
+			size:    5,
+			indices: []int{2},
+		},
+		{
+			name:     "remove a leaf should notify the indices of the shifted leaves",
+			strategy: PROMOTE,
+			mode:     REMOVE,
+			update: func(mt *MerkleTree) error {
+				return mt.Delete(ctx, 2)
+			},
+			size:    4,
+			indices: []int{2, 3, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := buildTree(t, configWithHashPool.Hasher, 0, tt.strategy, dataUnEvenNbNodes, func(b *MerkleTreeBuilder) { b.WithDeletionMode(tt.mode) })
+			oldRoot := mt.Root.Hash
+
+			var changes []RootChange
+			unsubscribe := mt.Subscribe(func(change RootChange) {
+				changes = append(changes, change)
+			})
+
+			assert.NoError(t, tt.update(mt))
+			assert.Equal(t, []RootChange{{
+				OldRoot: oldRoot,
+				NewRoot: mt.Root.Hash,
+				Size:    tt.size,
+				Indices: tt.indices,
+			}}, changes)
+
+			// an unsubscribed callback should not be called anymore
+			unsubscribe()
+			assert.NoError(t, mt.Update(0, StringData{Value: "updated again"}))
+			assert.Len(t, changes, 1)
+		})
+	}
+}
+
+func TestMerkleTree_SubscribeRootUnchanged(t *testing.T) {
+	mt := buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, dataUnEvenNbNodes)
+
+	nbCalls := 0
+	mt.Subscribe(func(change RootChange) {
+		nbCalls++
+	})
+
+	// replacing a leaf by the same data should not change the root
+	assert.NoError(t, mt.Update(0, dataUnEvenNbNodes[0]))
+	assert.Equal(t, 0, nbCalls)
+}
+
+func TestMerkleTree_Watch(t *testing.T) {
+	mt := buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, dataUnEvenNbNodes)
+	changes, stop := mt.Watch(0)
+	defer stop()
+
+	// the updates are not blocked by a receiver that does not read the changes yet
+	updated := make(chan struct{})
+	go func() {
+		defer close(updated)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, mt.Update(i, StringData{Value: fmt.Sprintf("updated%d", i)}))
+		}
+	}()
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("the update is blocked by the watch")
+	}
+
+	// no change is dropped and they are received in order
+	for i := 0; i < 3; i++ {
+		select {
+		case change := <-changes:
+			assert.Equal(t, []int{i}, change.Indices)
+		case <-time.After(time.Second):
+			t.Fatal("the root change has not been received")
+		}
+	}
+
+	// no change is received once the watch is stopped
+	stop()
+	assert.NoError(t, mt.Update(3, StringData{Value: "updated3"}))
+	select {
+	case <-changes:
+		t.Fatal("a root change has been received once the watch is stopped")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,154 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrMerkleTreeTxIsDone   = errors.New("the merkle tree transaction has already been committed or rolled back")
+	ErrMerkleTreeTxConflict = errors.New("the merkle tree has been changed since the transaction began")
+)
+
+// MerkleTreeTx stages changes of a tree that only become visible once committed
+// the changes are applied to a working copy of the tree sharing its nodes, producing a provisional root that can be
+// read, e.g. to be signed, before being published
+type MerkleTreeTx struct {
+	tree    *MerkleTree
+	working *MerkleTree
+	base    *Node
+	indices map[int]struct{}
+	done    bool
+
+	// locker is locked while the transaction is committed, if the tree is shared between goroutines
+	locker sync.Locker
+}
+
+// Begin starts a transaction on the tree
+// the leaves and the levels of the tree are copied but not its nodes, no hash is computed
+func (mt *MerkleTree) Begin() *MerkleTreeTx {
+	tx := &MerkleTreeTx{
+		tree:    mt,
+		working: mt.clone(),
+		base:    mt.Root,
+		indices: map[int]struct{}{},
+	}
+	tx.working.Subscribe(func(change RootChange) {
+		for _, i := range change.Indices {
+			tx.indices[i] = struct{}{}
+		}
+	})
+	return tx
+}
+
+// Begin starts a transaction on the tree, the transaction being committed under the write lock
+func (c *ConcurrentMerkleTree) Begin() *MerkleTreeTx {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tx := c.tree.Begin()
+	tx.locker = &c.mu
+	return tx
+}
+
+// Update stages the replacement of the data of the leaf at the index passed in parameter
+func (tx *MerkleTreeTx) Update(index int, data Data) error {
+	if tx.done {
+		return ErrMerkleTreeTxIsDone
+	}
+	return tx.working.Update(index, data)
+}
+
+// ApplyBatch stages the replacement of the data of the leaves at the indices passed in parameter
+func (tx *MerkleTreeTx) ApplyBatch(ctx context.Context, updates map[int]Data) error {
+	if tx.done {
+		return ErrMerkleTreeTxIsDone
+	}
+	return tx.working.ApplyBatch(ctx, updates)
+}
+
+// Append stages new leaves at the end of the tree
+func (tx *MerkleTreeTx) Append(ctx context.Context, data ...Data) error {
+	if tx.done {
+		return ErrMerkleTreeTxIsDone
+	}
+	return tx.working.Append(ctx, data...)
+}
+
+// Delete stages the deletion of the leaves at the indices passed in parameter
+func (tx *MerkleTreeTx) Delete(ctx context.Context, indices ...int) error {
+	if tx.done {
+		return ErrMerkleTreeTxIsDone
+	}
+	return tx.working.Delete(ctx, indices...)
+}
+
+// RootHash returns the provisional root computed with the hash algorithm passed in parameter
+func (tx *MerkleTreeTx) RootHash(hash Hash) ([]byte, error) {
+	return tx.working.RootHash(hash)
+}
+
+// Proof generates the inclusion proof of the leaf containing the data passed in parameter against the provisional root
+func (tx *MerkleTreeTx) Proof(data Data, hash Hash) (*Proof, error) {
+	return tx.working.Proof(data, hash)
+}
+
+// Commit publishes the staged changes at once, the subscribers of the tree being notified of a single root change
+// an error is returned if the tree has been changed since the transaction began
+func (tx *MerkleTreeTx) Commit() error {
+	if tx.done {
+		return ErrMerkleTreeTxIsDone
+	}
+	tx.done = true
+
+	if tx.locker != nil {
+		tx.locker.Lock()
+		defer tx.locker.Unlock()
+	}
+
+	if tx.tree.Root != tx.base {
+		return ErrMerkleTreeTxConflict
+	}
+
+	indices := make([]int, 0, len(tx.indices))
+	for i := range tx.indices {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	tx.tree.Leaves, tx.tree.levels = tx.working.Leaves, tx.working.levels
+	tx.tree.setRoot(tx.working.Root, indices)
+	return nil
+}
+
+// Rollback discards the staged changes, the tree is left untouched
+func (tx *MerkleTreeTx) Rollback() error {
+	if tx.done {
+		return ErrMerkleTreeTxIsDone
+	}
+	tx.done = true
+	return nil
+}
+
+// clone returns a copy of the tree sharing its nodes, the leaves and the levels being copied as they are updated in
+// place, the subscribers are not copied
+func (mt *MerkleTree) clone() *MerkleTree {
+	c := &MerkleTree{
+		Root:             mt.Root,
+		Leaves:           make([]*Node, len(mt.Leaves)),
+		DroppedLeaves:    mt.DroppedLeaves,
+		MerkleTreeConfig: mt.MerkleTreeConfig,
+		levels:           make([][]*Node, len(mt.levels)),
+	}
+	copy(c.Leaves, mt.Leaves)
+	for i, level := range mt.levels {
+		c.levels[i] = make([]*Node, len(level))
+		copy(c.levels[i], level)
+	}
+	if len(c.levels) > 0 {
+		c.levels[0] = c.Leaves
+	}
+	return c
+}
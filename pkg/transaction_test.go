@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMerkleTreeTx(t *testing.T) {
+	tests := []struct {
+		name     string
+		isCommit bool
+		conflict func(mt *MerkleTree) error
+		err      error
+	}{
+		{
+			name:     "commit a transaction should publish the provisional root",
+			isCommit: true,
+		},
+		{
+			name: "rollback a transaction should leave the tree untouched",
+		},
+		{
+			name:     "commit a transaction on a tree changed since it began should return error",
+			isCommit: true,
+			conflict: func(mt *MerkleTree) error {
+				return mt.Update(0, StringData{Value: "conflict"})
+			},
+			err: ErrMerkleTreeTxConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, dataUnEvenNbNodes)
+			root := mt.Root.Hash
+
+			var changes []RootChange
+			mt.Subscribe(func(change RootChange) {
+				changes = append(changes, change)
+			})
+
+			tx := mt.Begin()
+			assert.NoError(t, tx.Update(1, StringData{Value: "updated"}))
+			assert.NoError(t, tx.Append(ctx, StringData{Value: "appended"}))
+
+			// the staged changes should not be visible before being committed
+			provisionalRoot, err := tx.RootHash(SHA256)
+			assert.NoError(t, err)
+			assert.NotEqual(t, root, provisionalRoot)
+			assert.Equal(t, root, mt.Root.Hash)
+			assert.Empty(t, changes)
+
+			p, err := tx.Proof(StringData{Value: "appended"}, SHA256)
+			assert.NoError(t, err)
+			isVerified, err := p.Verify(provisionalRoot)
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+
+			if tt.conflict != nil {
+				assert.NoError(t, tt.conflict(mt))
+				root = mt.Root.Hash
+				changes = nil
+			}
+
+			if !tt.isCommit {
+				assert.NoError(t, tx.Rollback())
+				assert.Equal(t, root, mt.Root.Hash)
+				assert.Empty(t, changes)
+				assert.ErrorIs(t, tx.Commit(), ErrMerkleTreeTxIsDone)
+				return
+			}
+
+			err = tx.Commit()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Commit() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			assert.ErrorIs(t, tx.Update(0, StringData{Value: "updated"}), ErrMerkleTreeTxIsDone)
+			if err != nil {
+				assert.Equal(t, root, mt.Root.Hash)
+				assert.Empty(t, changes)
+				return
+			}
+
+			// the tree should be the one built with the staged changes and a single change should be notified
+			data := append(leavesData(buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, dataUnEvenNbNodes)), StringData{Value: "appended"})
+			data[1] = StringData{Value: "updated"}
+			expected := buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, data)
+			assert.Equal(t, expected.Root.Hash, mt.Root.Hash)
+			assert.Equal(t, provisionalRoot, mt.Root.Hash)
+			assert.Equal(t, []RootChange{{
+				OldRoot: root,
+				NewRoot: provisionalRoot,
+				Size:    6,
+				Indices: []int{1, 5},
+			}}, changes)
+
+			// the tree should still be updatable once the transaction is committed
+			assert.NoError(t, mt.Update(5, StringData{Value: "updated again"}))
+			isVerified, err = mt.Verify(ctx, StringData{Value: "updated again"})
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+		})
+	}
+}
+
+func TestConcurrentMerkleTree_Begin(t *testing.T) {
+	tree := NewConcurrentMerkleTree(buildTree(t, configWithHashPool.Hasher, 0, DUPLICATE, dataUnEvenNbNodes))
+
+	tx := tree.Begin()
+	assert.NoError(t, tx.ApplyBatch(ctx, map[int]Data{0: StringData{Value: "u0"}, 2: StringData{Value: "u2"}}))
+	provisionalRoot, err := tx.RootHash(SHA256)
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p, root, err := tree.Proof(dataUnEvenNbNodes[4], SHA256)
+		assert.NoError(t, err)
+		isVerified, err := p.Verify(root)
+		assert.NoError(t, err)
+		assert.True(t, isVerified)
+	}()
+	assert.NoError(t, tx.Commit())
+	<-done
+
+	root, err := tree.RootHash(SHA256)
+	assert.NoError(t, err)
+	assert.Equal(t, provisionalRoot, root)
+}
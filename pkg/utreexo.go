@@ -0,0 +1,489 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+)
+
+// Utreexo is the dynamic hash based accumulator of Utreexo: a forest of perfect binary trees, one per bit set in the nb
+// of leaves, of which only the roots are kept so that a stateless node validates the leaves it is given the proofs of
+// adding a leaf merges the roots of the same height as a binary counter, the root already in the forest being on the
+// left, deleting a leaf removes the root of its tree and adds back the siblings of its proof, which are the perfect
+// trees of the remaining leaves of the tree, the same way
+// each change of the forest is recorded in an update from which the holders of proofs bring them up to date
+type Utreexo struct {
+	UtreexoConfig
+	roots    []utreexoRoot
+	nbLeaves uint64
+}
+
+// UtreexoConfig is the configuration that represents the options used to build the accumulator
+type UtreexoConfig struct {
+	Hasher *Hasher
+}
+
+// UtreexoBuilder allows use to pass the configuration before building an accumulator
+type UtreexoBuilder struct {
+	config *UtreexoConfig
+}
+
+// UtreexoProof is the inclusion proof of a leaf in the tree of the forest whose height is the nb of steps
+type UtreexoProof struct {
+	Hash         Hash
+	IsSortPairs  bool
+	DigestLength uint32
+	Leaf         []byte
+	Steps        []ProofStep
+}
+
+// UtreexoUpdate is the ordered list of the changes applied to the forest by an addition or a deletion
+type UtreexoUpdate struct {
+	Changes []UtreexoChange
+}
+
+// UtreexoChange is either the destruction of the node, if IsDestroyed is true, or the creation of the node as the
+// parent of Left and Right
+type UtreexoChange struct {
+	IsDestroyed bool
+	Node        []byte
+	Left        []byte
+	Right       []byte
+}
+
+// utreexoRoot is a root of the forest along with the height of its tree
+type utreexoRoot struct {
+	hash   []byte
+	height int
+}
+
+var (
+	ErrUtreexoProofIsWrong  = errors.New("the utreexo proof does not lead to any root of the forest")
+	ErrUtreexoLeafIsDeleted = errors.New("the utreexo leaf has been deleted")
+	ErrUtreexoLeafExists    = errors.New("the utreexo forest already contains the leaf")
+	ErrUtreexoLeafNotFound  = errors.New("the utreexo forest does not contain the leaf")
+)
+
+func NewUtreexoBuilder() *UtreexoBuilder {
+	return &UtreexoBuilder{config: &UtreexoConfig{}}
+}
+
+func (b *UtreexoBuilder) WithHasher(hasher *Hasher) *UtreexoBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+// Build creates an empty accumulator
+func (b *UtreexoBuilder) Build() (*Utreexo, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.Hasher == nil {
+		return nil, ErrMerkleTreeConfigHasherIsNil
+	}
+
+	if !b.config.Hasher.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), b.config.Hasher.Hash)
+	}
+
+	if err := b.config.Hasher.Hash.ValidateDigestLength(b.config.Hasher.DigestLength); err != nil {
+		return nil, fmt.Errorf("Hash.ValidateDigestLength(): %w", err)
+	}
+
+	return &Utreexo{UtreexoConfig: *b.config}, nil
+}
+
+// NbLeaves returns the nb of leaves of the forest
+func (t *Utreexo) NbLeaves() uint64 {
+	return t.nbLeaves
+}
+
+// Roots returns the roots of the forest from the highest tree to the lowest
+func (t *Utreexo) Roots() [][]byte {
+	roots := make([][]byte, len(t.roots))
+	for i, r := range t.roots {
+		roots[i] = r.hash
+	}
+	return roots
+}
+
+// Add adds the leaf hashes passed in parameter one after the other
+func (t *Utreexo) Add(leaves ...[]byte) (*UtreexoUpdate, error) {
+	u := &UtreexoUpdate{}
+	for _, leaf := range leaves {
+		if err := t.addTree(u, leaf, 0); err != nil {
+			return nil, err
+		}
+		t.nbLeaves++
+	}
+	return u, nil
+}
+
+// Delete deletes the leaves of the proofs passed in parameter one after the other, the proofs being the ones of the
+// forest before the deletion, each of them is verified then the next ones are updated with the changes of the deletion
+// the proofs passed in parameter are left unchanged, the forest as well if any of them is wrong
+func (t *Utreexo) Delete(proofs ...*UtreexoProof) (*UtreexoUpdate, error) {
+	// all the proofs are verified before the forest is changed so that a wrong batch leaves it untouched
+	pending := make([]*UtreexoProof, len(proofs))
+	deleted := make(map[string]bool, len(proofs))
+	for i, p := range proofs {
+		isVerified, err := t.Verify(p)
+		if err != nil {
+			return nil, err
+		}
+		if !isVerified {
+			return nil, fmt.Errorf("leaf<%x>: %w", p.Leaf, ErrUtreexoProofIsWrong)
+		}
+		if deleted[string(p.Leaf)] {
+			return nil, fmt.Errorf("leaf<%x>: %w", p.Leaf, ErrUtreexoLeafIsDeleted)
+		}
+		deleted[string(p.Leaf)] = true
+		pending[i] = p.clone()
+	}
+
+	u := &UtreexoUpdate{}
+	for i, p := range pending {
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		root := t.rootIndex(path[len(path)-1], len(p.Steps))
+		if root == -1 {
+			return nil, fmt.Errorf("leaf<%x>: %w", p.Leaf, ErrUtreexoProofIsWrong)
+		}
+
+		// the nodes of the path are destroyed, the tree being split into the subtrees of the siblings
+		deletion := &UtreexoUpdate{}
+		for _, n := range path {
+			deletion.Changes = append(deletion.Changes, UtreexoChange{IsDestroyed: true, Node: n})
+		}
+		t.roots = append(t.roots[:root], t.roots[root+1:]...)
+		for h, step := range p.Steps {
+			if err = t.addTree(deletion, step.Siblings[0], h); err != nil {
+				return nil, err
+			}
+		}
+		t.nbLeaves--
+
+		for _, next := range pending[i+1:] {
+			if err = next.Update(deletion); err != nil {
+				return nil, fmt.Errorf("next.Update(): leaf<%x>: %w", next.Leaf, err)
+			}
+		}
+		u.Changes = append(u.Changes, deletion.Changes...)
+	}
+	return u, nil
+}
+
+// Verify checks that the proof leads to a root of the forest
+func (t *Utreexo) Verify(p *UtreexoProof) (bool, error) {
+	if p == nil {
+		return false, ErrProofIsNil
+	}
+	path, err := p.path()
+	if err != nil {
+		return false, err
+	}
+	return t.rootIndex(path[len(path)-1], len(p.Steps)) != -1, nil
+}
+
+// Update brings the proof up to date with the changes of the forest passed in parameter
+// the proof is cut below the lowest node of its path that has been destroyed then extended with the parents created
+// above its highest node, an error is returned if the leaf itself has been deleted
+func (p *UtreexoProof) Update(u *UtreexoUpdate) error {
+	if p == nil {
+		return ErrProofIsNil
+	}
+	path, err := p.path()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range u.Changes {
+		if c.IsDestroyed {
+			for i, n := range path {
+				if !bytes.Equal(n, c.Node) {
+					continue
+				}
+				if i == 0 {
+					return fmt.Errorf("leaf<%x>: %w", p.Leaf, ErrUtreexoLeafIsDeleted)
+				}
+				path, p.Steps = path[:i], append([]ProofStep(nil), p.Steps[:i-1]...)
+				break
+			}
+			continue
+		}
+
+		top := path[len(path)-1]
+		switch {
+		case bytes.Equal(top, c.Left):
+			p.Steps = append(p.Steps, ProofStep{Siblings: [][]byte{c.Right}, Position: 0})
+		case bytes.Equal(top, c.Right):
+			p.Steps = append(p.Steps, ProofStep{Siblings: [][]byte{c.Left}, Position: 1})
+		default:
+			continue
+		}
+		path = append(path, c.Node)
+	}
+	return nil
+}
+
+// addTree adds the perfect tree of the height passed in parameter, merging it with the root of the same height, if
+// any, and so on with the parent
+func (t *Utreexo) addTree(u *UtreexoUpdate, node []byte, height int) error {
+	for {
+		i := 0
+		for i < len(t.roots) && t.roots[i].height > height {
+			i++
+		}
+		if i == len(t.roots) || t.roots[i].height < height {
+			t.roots = append(t.roots, utreexoRoot{})
+			copy(t.roots[i+1:], t.roots[i:])
+			t.roots[i] = utreexoRoot{hash: node, height: height}
+			return nil
+		}
+
+		parent, err := t.Hasher.hashChildren(t.roots[i].hash, node)
+		if err != nil {
+			return fmt.Errorf("t.Hasher.hashChildren(): %w", err)
+		}
+		u.Changes = append(u.Changes, UtreexoChange{Node: parent, Left: t.roots[i].hash, Right: node})
+		t.roots = append(t.roots[:i], t.roots[i+1:]...)
+		node, height = parent, height+1
+	}
+}
+
+// rootIndex returns the index of the root of the height passed in parameter if it is equal to the node, -1 otherwise
+func (t *Utreexo) rootIndex(node []byte, height int) int {
+	for i, r := range t.roots {
+		if r.height == height && bytes.Equal(r.hash, node) {
+			return i
+		}
+	}
+	return -1
+}
+
+// path returns the nodes from the leaf up to the root of its tree
+func (p *UtreexoProof) path() ([][]byte, error) {
+	if !p.Hash.IsValid() {
+		return nil, fmt.Errorf(ErrHashNotAllowed.Error(), p.Hash)
+	}
+	h := &Hasher{Hash: p.Hash, IsSortPairs: p.IsSortPairs, DigestLength: p.DigestLength}
+
+	path := [][]byte{p.Leaf}
+	for _, step := range p.Steps {
+		if len(step.Siblings) != 1 {
+			return nil, ErrProofStepIsWrong
+		}
+		left, right := path[len(path)-1], step.Siblings[0]
+		switch step.Position {
+		case 0:
+		case 1:
+			left, right = right, left
+		default:
+			return nil, ErrProofStepIsWrong
+		}
+		node, err := h.hashChildren(left, right)
+		if err != nil {
+			return nil, fmt.Errorf("h.hashChildren(): %w", err)
+		}
+		path = append(path, node)
+	}
+	return path, nil
+}
+
+// clone returns a copy of the proof whose steps can be updated without changing the proof
+func (p *UtreexoProof) clone() *UtreexoProof {
+	c := *p
+	c.Steps = append([]ProofStep(nil), p.Steps...)
+	return &c
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// UtreexoForest is the accumulator along with all the nodes of its forest, as kept by a bridge node, so that it
+// generates the proofs of the leaves and deletes leaves by their hash
+// its nodes are maintained from the changes recorded by the accumulator
+type UtreexoForest struct {
+	UtreexoForestConfig
+	acc   *Utreexo
+	nodes map[string]*utreexoNode
+}
+
+// UtreexoForestConfig is the configuration that represents the options used to build the forest
+type UtreexoForestConfig struct {
+	Hasher       *Hasher
+	MaxGoroutine uint32
+}
+
+// UtreexoForestBuilder allows use to pass the configuration before building a forest
+type UtreexoForestBuilder struct {
+	config *UtreexoForestConfig
+}
+
+// utreexoNode is a node of the forest, the leaves having no children and the roots no parent
+type utreexoNode struct {
+	hash   []byte
+	parent *utreexoNode
+	left   *utreexoNode
+	right  *utreexoNode
+}
+
+func NewUtreexoForestBuilder() *UtreexoForestBuilder {
+	return &UtreexoForestBuilder{config: &UtreexoForestConfig{}}
+}
+
+func (b *UtreexoForestBuilder) WithHasher(hasher *Hasher) *UtreexoForestBuilder {
+	b.config.Hasher = hasher
+	return b
+}
+
+func (b *UtreexoForestBuilder) WithMaxGoroutine(maxGoroutine uint32) *UtreexoForestBuilder {
+	b.config.MaxGoroutine = maxGoroutine
+	return b
+}
+
+// Build creates an empty forest
+func (b *UtreexoForestBuilder) Build() (*UtreexoForest, error) {
+	if b.config == nil {
+		return nil, ErrMerkleTreeConfigIsNil
+	}
+
+	if b.config.MaxGoroutine == 0 {
+		return nil, ErrMerkleTreeConfigMaxGoroutineIsEqZero
+	}
+
+	acc, err := NewUtreexoBuilder().WithHasher(b.config.Hasher).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UtreexoForest{
+		UtreexoForestConfig: *b.config,
+		acc:                 acc,
+		nodes:               map[string]*utreexoNode{},
+	}, nil
+}
+
+// NbLeaves returns the nb of leaves of the forest
+func (f *UtreexoForest) NbLeaves() uint64 {
+	return f.acc.NbLeaves()
+}
+
+// Roots returns the roots of the forest from the highest tree to the lowest
+func (f *UtreexoForest) Roots() [][]byte {
+	return f.acc.Roots()
+}
+
+// Verify checks that the proof leads to a root of the forest
+func (f *UtreexoForest) Verify(p *UtreexoProof) (bool, error) {
+	return f.acc.Verify(p)
+}
+
+// Add hashes the data passed in parameter in parallel then adds the leaves one after the other
+// a leaf cannot be added twice as the leaves are identified by their hash
+func (f *UtreexoForest) Add(ctx context.Context, data ...Data) (*UtreexoUpdate, error) {
+	leaves := make([][]byte, len(data))
+
+	errs, _ := errgroup.WithContext(ctx)
+	errs.SetLimit(int(f.MaxGoroutine))
+	for _i := 0; _i < len(data); _i++ {
+		// i can change in the below go routine, allocates a local scope via i
+		i := _i
+
+		errs.Go(func() error {
+			leaf, err := data[i].Hash(f.Hasher)
+			if err != nil {
+				return fmt.Errorf("data[%d].Hash(): data<%s>: %w", i, data[i], err)
+			}
+			leaves[i] = leaf
+			return nil
+		})
+	}
+
+	// wait for all the go routines to be done
+	if err := errs.Wait(); err != nil {
+		return nil, err
+	}
+
+	added := make(map[string]bool, len(leaves))
+	for _, leaf := range leaves {
+		if _, ok := f.nodes[string(leaf)]; ok || added[string(leaf)] {
+			return nil, fmt.Errorf("leaf<%x>: %w", leaf, ErrUtreexoLeafExists)
+		}
+		added[string(leaf)] = true
+	}
+
+	u, err := f.acc.Add(leaves...)
+	if err != nil {
+		return nil, err
+	}
+	for _, leaf := range leaves {
+		f.nodes[string(leaf)] = &utreexoNode{hash: leaf}
+	}
+	f.apply(u)
+	return u, nil
+}
+
+// Delete deletes the leaves passed in parameter one after the other
+func (f *UtreexoForest) Delete(leaves ...[]byte) (*UtreexoUpdate, error) {
+	proofs := make([]*UtreexoProof, len(leaves))
+	for i, leaf := range leaves {
+		p, err := f.Prove(leaf)
+		if err != nil {
+			return nil, err
+		}
+		proofs[i] = p
+	}
+
+	u, err := f.acc.Delete(proofs...)
+	if err != nil {
+		return nil, err
+	}
+	f.apply(u)
+	return u, nil
+}
+
+// Prove generates the inclusion proof of the leaf passed in parameter
+func (f *UtreexoForest) Prove(leaf []byte) (*UtreexoProof, error) {
+	n, ok := f.nodes[string(leaf)]
+	if !ok || n.left != nil {
+		return nil, fmt.Errorf("leaf<%x>: %w", leaf, ErrUtreexoLeafNotFound)
+	}
+
+	p := &UtreexoProof{
+		Hash:         f.Hasher.Hash,
+		IsSortPairs:  f.Hasher.IsSortPairs,
+		DigestLength: f.Hasher.DigestLength,
+		Leaf:         n.hash,
+	}
+	for ; n.parent != nil; n = n.parent {
+		if n.parent.left == n {
+			p.Steps = append(p.Steps, ProofStep{Siblings: [][]byte{n.parent.right.hash}, Position: 0})
+		} else {
+			p.Steps = append(p.Steps, ProofStep{Siblings: [][]byte{n.parent.left.hash}, Position: 1})
+		}
+	}
+	return p, nil
+}
+
+// apply applies the changes of the accumulator to the nodes of the forest
+func (f *UtreexoForest) apply(u *UtreexoUpdate) {
+	for _, c := range u.Changes {
+		if c.IsDestroyed {
+			// the children of a destroyed node become roots until they are merged again
+			if n := f.nodes[string(c.Node)]; n != nil && n.left != nil {
+				n.left.parent, n.right.parent = nil, nil
+			}
+			delete(f.nodes, string(c.Node))
+			continue
+		}
+
+		n := &utreexoNode{hash: c.Node, left: f.nodes[string(c.Left)], right: f.nodes[string(c.Right)]}
+		n.left.parent, n.right.parent = n, n
+		f.nodes[string(c.Node)] = n
+	}
+}
@@ -0,0 +1,195 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUtreexoForestBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name         string
+		hasher       *Hasher
+		maxGoroutine uint32
+		err          error
+	}{
+		{
+			name:         "build a forest without hasher should return error",
+			hasher:       nil,
+			maxGoroutine: 1,
+			err:          ErrMerkleTreeConfigHasherIsNil,
+		},
+		{
+			name:         "build a forest without goroutine should return error",
+			hasher:       configWithHashPool.Hasher,
+			maxGoroutine: 0,
+			err:          ErrMerkleTreeConfigMaxGoroutineIsEqZero,
+		},
+		{
+			name:         "build a forest with a too short digest length should return error",
+			hasher:       &Hasher{Hash: SHA256, DigestLength: 8},
+			maxGoroutine: 1,
+			err:          ErrHashDigestLengthTooShort,
+		},
+		{
+			name:         "build a forest should return an empty forest",
+			hasher:       configWithHashPool.Hasher,
+			maxGoroutine: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewUtreexoForestBuilder().WithHasher(tt.hasher).WithMaxGoroutine(tt.maxGoroutine).Build()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, uint64(0), f.NbLeaves())
+			assert.Empty(t, f.Roots())
+		})
+	}
+
+	// an unknown hash algorithm is rejected instead of panicking once the hashes are computed
+	_, err := NewUtreexoBuilder().WithHasher(&Hasher{Hash: "md5"}).Build()
+	assert.EqualError(t, err, fmt.Sprintf(ErrHashNotAllowed.Error(), "md5"))
+}
+
+func TestUtreexoForest_Add(t *testing.T) {
+	data := make([]Data, 7)
+	for i := range data {
+		data[i] = &StringData{Value: fmt.Sprintf("leaf-%d", i)}
+	}
+	f, err := NewUtreexoForestBuilder().WithHasher(configWithHashPool.Hasher).WithMaxGoroutine(4).Build()
+	assert.NoError(t, err)
+	_, err = f.Add(ctx, data...)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), f.NbLeaves())
+
+	// the forest of 7 leaves is made of the trees of the 4 first leaves, of the 2 next ones and of the last one
+	roots := f.Roots()
+	assert.Len(t, roots, 3)
+	for i, r := range [][]Data{data[:4], data[4:6]} {
+		mt, err := NewMerkleTreeBuilder().
+			WithHasher(configWithHashPool.Hasher).
+			WithMaxGoroutine(configWithHashPool.MaxGoroutine).
+			Build(ctx, r)
+		assert.NoError(t, err)
+		assert.Equal(t, mt.Root.Hash, roots[i])
+	}
+	leaf, err := data[6].Hash(configWithHashPool.Hasher)
+	assert.NoError(t, err)
+	assert.Equal(t, leaf, roots[2])
+
+	_, err = f.Add(ctx, data[0])
+	assert.ErrorIs(t, err, ErrUtreexoLeafExists)
+	_, err = f.Add(ctx, &StringData{Value: "new"}, &StringData{Value: "new"})
+	assert.ErrorIs(t, err, ErrUtreexoLeafExists)
+	assert.Equal(t, uint64(7), f.NbLeaves())
+}
+
+func TestUtreexoForest_Delete(t *testing.T) {
+	f, err := NewUtreexoForestBuilder().WithHasher(configWithHashPool.Hasher).WithMaxGoroutine(4).Build()
+	assert.NoError(t, err)
+	acc, err := NewUtreexoBuilder().WithHasher(configWithHashPool.Hasher).Build()
+	assert.NoError(t, err)
+
+	// the stateless accumulator is fed with the same changes and the proofs it holds are updated from the updates
+	proofs := map[string]*UtreexoProof{}
+	update := func(u *UtreexoUpdate) {
+		for leaf, p := range proofs {
+			if err := p.Update(u); err != nil {
+				assert.ErrorIs(t, err, ErrUtreexoLeafIsDeleted)
+				delete(proofs, leaf)
+			}
+		}
+	}
+	check := func() {
+		assert.Equal(t, f.Roots(), acc.Roots())
+		assert.Equal(t, f.NbLeaves(), acc.NbLeaves())
+		assert.Len(t, proofs, int(f.NbLeaves()))
+		for leaf, p := range proofs {
+			expected, err := f.Prove([]byte(leaf))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, p)
+			isVerified, err := acc.Verify(p)
+			assert.NoError(t, err)
+			assert.True(t, isVerified)
+		}
+	}
+
+	for round := 0; round < 4; round++ {
+		data := make([]Data, 5+3*round)
+		for i := range data {
+			data[i] = &StringData{Value: fmt.Sprintf("round-%d-leaf-%d", round, i)}
+		}
+		u, err := f.Add(ctx, data...)
+		assert.NoError(t, err)
+		update(u)
+
+		leaves := make([][]byte, len(data))
+		for i, d := range data {
+			if leaves[i], err = d.Hash(configWithHashPool.Hasher); err != nil {
+				t.Fatal(err)
+			}
+			if proofs[string(leaves[i])], err = f.Prove(leaves[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		accUpdate, err := acc.Add(leaves...)
+		assert.NoError(t, err)
+		assert.Equal(t, u, accUpdate)
+		check()
+
+		// delete a batch made of leaves of this round and of the previous ones, the proofs of the batch being the
+		// ones of the forest before the deletion
+		var deleted [][]byte
+		var deletedProofs []*UtreexoProof
+		for leaf, p := range proofs {
+			if len(deleted) == 2+round {
+				break
+			}
+			deleted = append(deleted, []byte(leaf))
+			deletedProofs = append(deletedProofs, p.clone())
+		}
+		u, err = f.Delete(deleted...)
+		assert.NoError(t, err)
+		accUpdate, err = acc.Delete(deletedProofs...)
+		assert.NoError(t, err)
+		assert.Equal(t, u, accUpdate)
+		update(u)
+		check()
+	}
+
+	// deleting an unknown leaf, the same leaf twice or a leaf with a wrong proof leaves the forest untouched
+	roots := f.Roots()
+	_, err = f.Delete([]byte("unknown"))
+	assert.ErrorIs(t, err, ErrUtreexoLeafNotFound)
+
+	var p *UtreexoProof
+	for _, p = range proofs {
+		break
+	}
+	_, err = acc.Delete(p, p)
+	assert.ErrorIs(t, err, ErrUtreexoLeafIsDeleted)
+
+	wrong := p.clone()
+	wrong.Leaf = []byte("unknown")
+	_, err = acc.Delete(wrong)
+	assert.ErrorIs(t, err, ErrUtreexoProofIsWrong)
+	assert.Equal(t, roots, acc.Roots())
+	assert.Equal(t, roots, f.Roots())
+
+	// deleting all the leaves empties the forest
+	var leaves [][]byte
+	for leaf := range proofs {
+		leaves = append(leaves, []byte(leaf))
+	}
+	_, err = f.Delete(leaves...)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), f.NbLeaves())
+	assert.Empty(t, f.Roots())
+}